@@ -0,0 +1,26 @@
+// Command images-plugin-conformance verifies that a plugin executable
+// implements the images plugin protocol. Usage:
+//
+//	$ images-plugin-conformance /path/to/images-provider-name
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"provider/plugin"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: images-plugin-conformance <plugin executable>")
+		os.Exit(2)
+	}
+
+	if err := plugin.Verify(os.Args[1]); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	fmt.Println("ok")
+}
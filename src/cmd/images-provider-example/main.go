@@ -0,0 +1,196 @@
+// Command images-provider-example is a reference plugin for images. It
+// manages images stored in a JSON file, which is defined with the
+// IMAGES_EXAMPLE_STORE environment variable. If it's not set, a static
+// read-only set of images is served. Put it on PATH and use it with:
+//
+//	$ images list --providers example
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"provider"
+	"provider/plugin"
+	"provider/utils"
+
+	"github.com/fatih/flags"
+)
+
+var staticImages = provider.Images{
+	{
+		ID:        "1",
+		Name:      "base-ubuntu",
+		Region:    "local",
+		State:     "available",
+		CreatedAt: time.Date(2015, 9, 1, 12, 0, 0, 0, time.UTC),
+		Size:      10,
+		Tags:      map[string]string{"env": "prod"},
+	},
+	{
+		ID:        "2",
+		Name:      "base-debian",
+		Region:    "local",
+		State:     "available",
+		CreatedAt: time.Date(2015, 10, 1, 12, 0, 0, 0, time.UTC),
+		Size:      10,
+	},
+}
+
+type example struct {
+	path string
+}
+
+func main() {
+	e := &example{path: os.Getenv("IMAGES_EXAMPLE_STORE")}
+	if err := plugin.Serve(e); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+}
+
+func (e *example) Help(command string) string {
+	switch command {
+	case "list":
+		return `Usage: images list --providers example [options]
+
+   List images
+
+Options:
+
+  -output  "json"              Output mode of images. (default: "simplified")
+                               Available options: ` + utils.OutputsHelp + `
+`
+	case "delete":
+		return `Usage: images delete --providers example [options]
+
+  Delete images
+
+Options:
+
+  -ids         "1,..."         Images to be deleted with the given ids
+`
+	case "modify":
+		return `Usage: images modify --providers example [options]
+
+  Rename images
+
+Options:
+
+  -ids         "1,..."         Images to be renamed
+  -name        "example"       New name for the images
+`
+	default:
+		return "no help found for command " + command
+	}
+}
+
+func (e *example) List(args []string) (provider.Images, error) {
+	return e.load()
+}
+
+func (e *example) Delete(args []string) error {
+	var ids []string
+	flagSet := flag.NewFlagSet("delete", flag.ContinueOnError)
+	flagSet.SetOutput(ioutil.Discard)
+	flagSet.Var(flags.NewStringSlice(nil, &ids), "ids", "Images to be deleted")
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+
+	if len(ids) == 0 {
+		return errors.New("no images are passed with [--ids]")
+	}
+
+	return e.update(ids, func(images provider.Images, i int) provider.Images {
+		return append(images[:i], images[i+1:]...)
+	})
+}
+
+func (e *example) Modify(args []string) error {
+	var (
+		ids  []string
+		name string
+	)
+
+	flagSet := flag.NewFlagSet("modify", flag.ContinueOnError)
+	flagSet.SetOutput(ioutil.Discard)
+	flagSet.Var(flags.NewStringSlice(nil, &ids), "ids", "Images to be renamed")
+	flagSet.StringVar(&name, "name", "", "New name for the images")
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+
+	if len(ids) == 0 {
+		return errors.New("no images are passed with [--ids]")
+	}
+
+	if name == "" {
+		return errors.New("no name is passed with [--name]")
+	}
+
+	return e.update(ids, func(images provider.Images, i int) provider.Images {
+		images[i].Name = name
+		return images
+	})
+}
+
+// update calls fn for every image with the given ids and saves the result.
+func (e *example) update(ids []string, fn func(images provider.Images, i int) provider.Images) error {
+	if e.path == "" {
+		return errors.New("images are read-only, set IMAGES_EXAMPLE_STORE")
+	}
+
+	images, err := e.load()
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		found := false
+		for i, image := range images {
+			if image.ID == id {
+				images = fn(images, i)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("no image found for id '%s'", id)
+		}
+	}
+
+	out, err := json.MarshalIndent(images, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(e.path, out, 0644)
+}
+
+func (e *example) load() (provider.Images, error) {
+	if e.path == "" {
+		return staticImages, nil
+	}
+
+	data, err := ioutil.ReadFile(e.path)
+	if os.IsNotExist(err) {
+		return provider.Images{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var images provider.Images
+	if err := json.Unmarshal(data, &images); err != nil {
+		return nil, err
+	}
+
+	return images, nil
+}
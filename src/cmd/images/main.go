@@ -3,11 +3,23 @@ package main
 import (
 	"fmt"
 	"os"
+	"os/signal"
 
 	"command"
+	"provider"
+
+	// providers register themselves, add new providers here
+	_ "provider/aws"
+	_ "provider/do"
+	_ "provider/gce"
+	_ "provider/sl"
+
+	"provider/plugin"
+	"provider/utils"
 
 	"github.com/fatih/color"
 	"github.com/mitchellh/cli"
+	"golang.org/x/net/context"
 )
 
 // The main version number that is being run at the moment. This will be filled
@@ -15,19 +27,23 @@ import (
 var Version = "dev"
 
 func main() {
-	if err := run(); err != nil {
+	exitCode, err := run()
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
 		os.Exit(1)
 	}
+
+	os.Exit(exitCode)
 }
 
-func run() error {
+// run runs the command given by the arguments and returns its exit status.
+func run() (int, error) {
 	// Create our global configuration and pre-process the argument list to
 	// return anything except our global flags. The global flags are passed
 	// into the config struct
 	config, remainingArgs, err := command.Load(os.Args[1:])
 	if err != nil {
-		return fmt.Errorf("Error loading global config : %s\n", err)
+		return 0, fmt.Errorf("Error loading global config : %s\n", err)
 	}
 
 	// completely shutdown colors
@@ -35,24 +51,72 @@ func run() error {
 		color.NoColor = true
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if config.Timeout != "" {
+		timeout, err := utils.ParseDuration(config.Timeout)
+		if err != nil {
+			return 0, fmt.Errorf("Error loading global config : %s\n", err)
+		}
+
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, timeout)
+		defer cancelTimeout()
+	}
+
+	if err := utils.ValidateParallelism(config.Parallelism); err != nil {
+		return 0, fmt.Errorf("Error loading global config : %s\n", err)
+	}
+
+	config.Context = utils.WithParallelism(ctx, config.Parallelism)
+
+	// The first interrupt cancels all in-flight requests, so the commands can
+	// report what is finished. The second one exits immediately.
+	interrupts := make(chan os.Signal, 2)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		<-interrupts
+		fmt.Fprintln(os.Stderr, "Interrupted, canceling in-flight requests. Press Ctrl-C again to exit immediately.")
+		cancel()
+
+		<-interrupts
+		os.Exit(130)
+	}()
+
+	// external providers are registered after the builtin ones, so they can't
+	// override them. They are only discovered once a command needs a provider
+	// which isn't builtin.
+	provider.SetDiscovery(func() {
+		if err := plugin.Discover(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: some plugins couldn't be loaded: %s\n", err)
+		}
+	})
+
 	c := &cli.CLI{
 		Name:     "images",
 		Version:  Version,
 		Args:     remainingArgs,
 		HelpFunc: command.HelpFunc,
 		Commands: map[string]cli.CommandFactory{
-			"list":    command.NewList(config),
-			"modify":  command.NewModify(config),
-			"delete":  command.NewDelete(config),
-			"copy":    command.NewCopy(config),
-			"version": command.NewVersion(Version),
+			"list":      command.NewList(config),
+			"modify":    command.NewModify(config),
+			"delete":    command.NewDelete(config),
+			"gc":        command.NewGC(config),
+			"gce":       command.NewGce(config),
+			"copy":      command.NewCopy(config),
+			"prune":     command.NewPrune(config),
+			"show":      command.NewShow(config),
+			"snapshots": command.NewSnapshots(config),
+			"wait":      command.NewWait(config),
+			"version":   command.NewVersion(Version),
 		},
 	}
 
-	_, err = c.Run()
+	exitCode, err := c.Run()
 	if err != nil {
-		return fmt.Errorf("Error executing CLI: %s\n", err)
+		return 0, fmt.Errorf("Error executing CLI: %s\n", err)
 	}
 
-	return nil
+	return exitCode, nil
 }
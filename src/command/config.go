@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/mitchellh/cli"
+	"golang.org/x/net/context"
 )
 
 // Config defines the global flag set of images
@@ -20,15 +21,31 @@ type Config struct {
 	// "delete"
 	Force bool `toml:"force" json:"force"`
 
+	// Timeout is the maximum duration of a command, i.e: "10m". Commands
+	// don't time out by default. Single requests are limited by the
+	// request_timeout setting of each provider instead.
+	Timeout string `toml:"timeout" json:"timeout"`
+
+	// Parallelism is the maximum number of concurrent requests of a provider,
+	// such as deleting multiple images. It can be overridden for each
+	// provider.
+	Parallelism int `toml:"parallelism" json:"parallelism" default:"10"`
+
 	Ui cli.Ui `toml:"-" json:"-"`
+
+	// Context is passed to all provider calls. It's canceled once the command
+	// is interrupted or the timeout is exceeded.
+	Context context.Context `toml:"-" json:"-"`
 }
 
 // Help returns the help messages of the respective commands
 func (c *Config) Help() map[string]string {
 	return map[string]string{
-		"providers": "Providers to be used",
-		"no-color":  "Disables color output",
-		"force":     "Disables user prompt",
+		"providers":   "Providers to be used",
+		"no-color":    "Disables color output",
+		"force":       "Disables user prompt",
+		"timeout":     "Maximum duration of the whole command, i.e: \"10m\"",
+		"parallelism": "Maximum number of concurrent requests of a provider",
 	}
 }
 
@@ -47,5 +64,7 @@ func Load(args []string) (*Config, []string, error) {
 		ErrorWriter: os.Stderr,
 	}
 
+	conf.Context = context.Background()
+
 	return conf, remainingArgs, nil
 }
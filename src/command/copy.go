@@ -3,6 +3,7 @@ package command
 import (
 	"fmt"
 	"os"
+	"provider"
 
 	"github.com/fatih/flags"
 	"github.com/mitchellh/cli"
@@ -29,7 +30,8 @@ func (c *Copy) Help() string {
 Options:
 
   -providers "name"    Provider to be used to copy images
-`
+
+` + providersHelp(provider.CanCopy)
 	}
 
 	return Help("copy", c.Providers[0])
@@ -46,26 +48,27 @@ func (c *Copy) Run(args []string) int {
 		return 1
 	}
 
-	provider := c.Providers[0]
-	if provider == "all" {
+	name := c.Providers[0]
+	if name == "all" {
 		fmt.Fprintln(os.Stderr, "Copy doesn't support multiple providers")
 		return 1
 	}
 
-	p, remArgs, err := Provider(provider, args)
+	p, remArgs, err := capableProvider(name, provider.CanCopy, args)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
 		return 1
 	}
+	defer printRetries(p)
 
 	copier, ok := p.(Copier)
 	if !ok {
-		err := fmt.Errorf("'%s' doesn't support copying images", provider)
+		err := fmt.Errorf("'%s' doesn't support copying images", name)
 		fmt.Fprintln(os.Stderr, err.Error())
 		return 1
 	}
 
-	if err := copier.Copy(remArgs); err != nil {
+	if err := copier.Copy(c.Context, remArgs); err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
 		return 1
 	}
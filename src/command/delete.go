@@ -3,6 +3,8 @@ package command
 import (
 	"fmt"
 	"os"
+	"provider"
+	"provider/utils"
 
 	"github.com/fatih/flags"
 	"github.com/mitchellh/cli"
@@ -28,11 +30,12 @@ func (d *Delete) Help() string {
 
 Options:
 
-  -providers [name]    Provider to be used to modify images
-`
+  -providers [name]    Provider to be used to delete images
+
+` + providersHelp(provider.CanDelete)
 	}
 
-	return Help("delete", d.Providers[0])
+	return Help("delete", d.Providers[0]) + filterHelp
 }
 
 func (d *Delete) Run(args []string) int {
@@ -46,25 +49,48 @@ func (d *Delete) Run(args []string) int {
 		return 1
 	}
 
-	provider := d.Providers[0]
-	if provider == "all" {
+	name := d.Providers[0]
+	if name == "all" {
 		fmt.Fprintln(os.Stderr, "Delete doesn't support multiple providers")
 		return 1
 	}
 
-	p, remArgs, err := Provider(provider, args)
+	f, args, err := parseFilter(args)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
 		return 1
 	}
 
+	p, remArgs, err := capableProvider(name, provider.CanDelete, args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	defer printRetries(p)
+
 	deleter, ok := p.(Deleter)
 	if !ok {
-		err := fmt.Errorf("'%s' doesn't support deleting images", provider)
+		err := fmt.Errorf("'%s' doesn't support deleting images", name)
 		fmt.Fprintln(os.Stderr, err.Error())
 		return 1
 	}
 
+	if f != nil {
+		images, selArgs, err := selectImages(d.Context, p, f, remArgs)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return 1
+		}
+
+		fmt.Println("The following images are matching the filter:")
+		if err := images.Print(utils.Simplified); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return 1
+		}
+
+		remArgs = selArgs
+	}
+
 	// Don't ask for question if --force is enabled
 	if !d.Force {
 		response, err := d.Ui.Ask("Do you really want to delete? (Type 'yes' to continue):")
@@ -79,7 +105,7 @@ func (d *Delete) Run(args []string) int {
 		}
 	}
 
-	if err := deleter.Delete(remArgs); err != nil {
+	if err := deleter.Delete(d.Context, remArgs); err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
 		return 1
 	}
@@ -0,0 +1,114 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"provider"
+	"provider/filter"
+	"strings"
+
+	"github.com/fatih/flags"
+	"golang.org/x/net/context"
+)
+
+// filterHelp is appended to the help messages of the commands which support
+// the --filter flag.
+const filterHelp = `
+Filter:
+
+  -filter "..."      Select images with an expression, i.e:
+                     'tag:env=prod and name~"^base-" and age>30d'. Fields:
+                     provider, region, id, name, state, account, family,
+                     encryption, tag:<key>, age, created, size. Operators:
+                     =, !=, ~, !~, <, <=, >, >= combined with and, or, not
+                     and parentheses.
+`
+
+// parseFilter parses the --filter flag and returns the remaining arguments.
+// The returned filter is nil if the flag is not passed.
+func parseFilter(args []string) (*filter.Filter, []string, error) {
+	val, remArgs, ok := filterValue(args)
+	if !ok {
+		return nil, args, nil
+	}
+
+	if val == "" {
+		return nil, nil, errors.New("no expression is passed with [--filter]")
+	}
+
+	f, err := filter.Parse(val)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return f, remArgs, nil
+}
+
+// filterValue returns the value of the --filter flag and the remaining
+// arguments. The flags package splits "--filter=a=b" on the last '=', which
+// is part of most expressions, so the joined form is handled here.
+func filterValue(args []string) (string, []string, bool) {
+	for i, arg := range args {
+		for _, prefix := range []string{"--filter=", "-filter="} {
+			if !strings.HasPrefix(arg, prefix) {
+				continue
+			}
+
+			remArgs := make([]string, 0, len(args)-1)
+			remArgs = append(remArgs, args[:i]...)
+			remArgs = append(remArgs, args[i+1:]...)
+			return strings.TrimPrefix(arg, prefix), remArgs, true
+		}
+	}
+
+	if !flags.Has("filter", args) {
+		return "", args, false
+	}
+
+	val, err := flags.Value("filter", args)
+	if err != nil {
+		return "", nil, true
+	}
+
+	return val, flags.Exclude("filter", args), true
+}
+
+// selectImages fetches the images of the given provider which match the
+// filter and returns them together with args, extended with the arguments
+// which select the images for the Delete or Modify methods of the provider.
+// The images are fetched with args, unless the provider splits off its list
+// arguments.
+func selectImages(ctx context.Context, p provider.Provider, f *filter.Filter, args []string) (provider.Images, []string, error) {
+	for _, name := range []string{"ids", "names"} {
+		if flags.Has(name, args) {
+			return nil, nil, fmt.Errorf("not allowed to be used together: [--filter,--%s]", name)
+		}
+	}
+
+	s, ok := p.(Selector)
+	if !ok {
+		return nil, nil, fmt.Errorf("'%s' doesn't support selecting images with [--filter]", p.Name())
+	}
+
+	listArgs := args
+	if l, ok := p.(ListArgsSplitter); ok {
+		listArgs, args = l.SplitListArgs(args)
+	}
+
+	images, err := p.Fetch(ctx, listArgs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, image := range images {
+		image.Provider = p.Name()
+	}
+
+	images = f.Apply(images)
+	if len(images) == 0 {
+		return nil, nil, fmt.Errorf("no images are matching the filter '%s'", f)
+	}
+
+	images.Sort()
+	return images, append(args, s.SelectArgs(images)...), nil
+}
@@ -0,0 +1,178 @@
+package command
+
+import (
+	"provider"
+	"provider/filter"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		args    []string
+		filter  string
+		remArgs []string
+		err     bool
+	}{
+		{
+			args:    []string{"--output", "json"},
+			remArgs: []string{"--output", "json"},
+		},
+		{
+			args:    []string{"--filter", "tag:env=prod", "--output", "json"},
+			filter:  "tag:env=prod",
+			remArgs: []string{"--output", "json"},
+		},
+		{
+			args:    []string{"--filter=tag:env=prod", "--output", "json"},
+			filter:  "tag:env=prod",
+			remArgs: []string{"--output", "json"},
+		},
+		{
+			args:    []string{"--output", "json", "-filter=name~\"^base-\" and age>30d"},
+			filter:  `name~"^base-" and age>30d`,
+			remArgs: []string{"--output", "json"},
+		},
+		{
+			args: []string{"--filter="},
+			err:  true,
+		},
+		{
+			args: []string{"--filter"},
+			err:  true,
+		},
+		{
+			args: []string{"--filter=tag:env=="},
+			err:  true,
+		},
+	}
+
+	for _, test := range tests {
+		f, remArgs, err := parseFilter(test.args)
+		if test.err {
+			if err == nil {
+				t.Errorf("parseFilter(%q): expected an error", test.args)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("parseFilter(%q): %s", test.args, err)
+			continue
+		}
+
+		var filter string
+		if f != nil {
+			filter = f.String()
+		}
+
+		if filter != test.filter {
+			t.Errorf("parseFilter(%q): filter is %q, want %q", test.args, filter, test.filter)
+		}
+
+		if !reflect.DeepEqual(remArgs, test.remArgs) {
+			t.Errorf("parseFilter(%q): remaining args are %q, want %q", test.args, remArgs, test.remArgs)
+		}
+	}
+}
+
+// fakeSelector records the arguments of Fetch
+type fakeSelector struct {
+	fetchArgs []string
+}
+
+func (f *fakeSelector) Name() string { return "fake" }
+
+func (f *fakeSelector) Fetch(ctx context.Context, args []string) (provider.Images, error) {
+	f.fetchArgs = args
+	return provider.Images{
+		{ID: "1", Name: "base"},
+		{ID: "2", Name: "web"},
+	}, nil
+}
+
+func (f *fakeSelector) SelectArgs(images provider.Images) []string {
+	return []string{"-ids", strings.Join(images.IDs(), ",")}
+}
+
+// fakeSplitter passes the first two arguments to Fetch
+type fakeSplitter struct{ fakeSelector }
+
+func (f *fakeSplitter) SplitListArgs(args []string) (list, rest []string) {
+	return args[:2], args[2:]
+}
+
+func TestSelectImages(t *testing.T) {
+	f, err := filter.Parse("name=web")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"-family", "web", "-dry-run"}
+
+	p := &fakeSelector{}
+	images, selArgs, err := selectImages(context.Background(), p, f, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(images) != 1 || images[0].ID != "2" || images[0].Provider != "fake" {
+		t.Errorf("selected %v, want image 2 of fake", images)
+	}
+
+	if !reflect.DeepEqual(p.fetchArgs, args) {
+		t.Errorf("fetched with %q, want %q", p.fetchArgs, args)
+	}
+
+	if want := []string{"-family", "web", "-dry-run", "-ids", "2"}; !reflect.DeepEqual(selArgs, want) {
+		t.Errorf("selected with %q, want %q", selArgs, want)
+	}
+
+	s := &fakeSplitter{}
+	_, selArgs, err = selectImages(context.Background(), s, f, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"-family", "web"}; !reflect.DeepEqual(s.fetchArgs, want) {
+		t.Errorf("splitter fetched with %q, want %q", s.fetchArgs, want)
+	}
+
+	if want := []string{"-dry-run", "-ids", "2"}; !reflect.DeepEqual(selArgs, want) {
+		t.Errorf("splitter selected with %q, want %q", selArgs, want)
+	}
+
+	if _, _, err := selectImages(context.Background(), p, f, []string{"-ids", "1"}); err == nil {
+		t.Error("expected an error for --filter together with --ids")
+	}
+}
+
+func TestFilterHelp(t *testing.T) {
+	comparisons := map[string]string{
+		"provider":   "provider=aws",
+		"region":     "region=eu",
+		"id":         "id=1",
+		"name":       "name=base",
+		"state":      "state=available",
+		"account":    "account=prod",
+		"family":     "family=web",
+		"encryption": "encryption=encrypted",
+		"tag:<key>":  "tag:env",
+		"age":        "age>30d",
+		"created":    "created<2016-01-02",
+		"size":       "size>=10",
+	}
+
+	for field, comparison := range comparisons {
+		if _, err := filter.Parse(comparison); err != nil {
+			t.Errorf("%s: %s", field, err)
+		}
+
+		if !strings.Contains(filterHelp, field) {
+			t.Errorf("field %s is missing in the help", field)
+		}
+	}
+}
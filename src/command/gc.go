@@ -0,0 +1,281 @@
+package command
+
+import (
+	"command/loader"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"provider"
+	"provider/utils"
+	"strings"
+	"time"
+
+	"github.com/fatih/flags"
+	"github.com/mitchellh/cli"
+)
+
+// RetentionRule defines which images of a provider are kept by the gc
+// command. Rules are declared in the configuration file, i.e:
+//
+//	[[retention]]
+//	provider = "aws"
+//	match = "base-*"
+//	keep = 5
+//	max_age = "90d"
+//	exclude_tags = ["keep=true"]
+type RetentionRule struct {
+	// Provider is the name of the provider the rule applies to. An empty
+	// provider applies to all providers passed with --providers.
+	Provider string `toml:"provider" json:"provider"`
+
+	// Match is a glob pattern matched against the image names, i.e: "base-*"
+	Match string `toml:"match" json:"match"`
+
+	// Keep is the number of the newest matching images to be kept per
+	// account and region
+	Keep int `toml:"keep" json:"keep"`
+
+	// MaxAge keeps all images which are younger than it, i.e: "90d"
+	MaxAge string `toml:"max_age" json:"max_age"`
+
+	// ExcludeTags excludes images with any of the given tags from the rule.
+	// A tag is in the form of "key=value" or "key", the latter matches any
+	// value.
+	ExcludeTags []string `toml:"exclude_tags" json:"exclude_tags"`
+
+	maxAge time.Duration
+}
+
+// validate checks the rule and parses its fields
+func (r *RetentionRule) validate() error {
+	if r.Match == "" {
+		return errors.New("match is not set")
+	}
+
+	if _, err := path.Match(r.Match, ""); err != nil {
+		return fmt.Errorf("invalid match '%s': %s", r.Match, err)
+	}
+
+	if r.Keep < 0 {
+		return fmt.Errorf("invalid keep %d", r.Keep)
+	}
+
+	if r.MaxAge != "" {
+		d, err := utils.ParseDuration(r.MaxAge)
+		if err != nil {
+			return err
+		}
+		r.maxAge = d
+	}
+
+	if r.Keep == 0 && r.maxAge == 0 {
+		return errors.New("no images are kept, set keep or max_age")
+	}
+
+	return nil
+}
+
+// matches returns true if the rule applies to the given image.
+func (r *RetentionRule) matches(image *provider.Image) bool {
+	if r.Provider != "" && r.Provider != image.Provider {
+		return false
+	}
+
+	if ok, _ := path.Match(r.Match, image.Name); !ok {
+		return false
+	}
+
+	for _, tag := range r.ExcludeTags {
+		kv := strings.SplitN(tag, "=", 2)
+		val, ok := image.Tags[kv[0]]
+		if ok && (len(kv) == 1 || kv[1] == val) {
+			return false
+		}
+	}
+
+	return true
+}
+
+type GC struct {
+	*Config
+}
+
+func NewGC(config *Config) cli.CommandFactory {
+	return func() (cli.Command, error) {
+		return &GC{
+			Config: config,
+		}, nil
+	}
+}
+
+type gcFlags struct {
+	// dryRun only prints the plan
+	dryRun bool
+
+	helpMsg string
+	flagSet *flag.FlagSet
+}
+
+func newGCFlags() *gcFlags {
+	g := &gcFlags{}
+
+	flagSet := flag.NewFlagSet("gc", flag.ContinueOnError)
+	flagSet.BoolVar(&g.dryRun, "dry-run", false, "Don't delete images, only show the plan")
+	g.helpMsg = `Usage: images gc [options]
+
+  Deletes images according to the retention rules of the configuration file.
+  Each image is evaluated by the first rule it matches. For each rule, account
+  and region the newest images are kept, all others are deleted. Images which
+  don't match any rule are never deleted. A plan is printed before anything
+  is deleted.
+
+Options:
+
+  -providers   "name,..."      Providers to be used. Defaults to the providers
+                               of the rules, required if a rule applies to
+                               all providers
+  -dry-run                     Don't delete images, only show the plan
+
+Rules are declared in .imagesrc:
+
+  [[retention]]
+  provider = "aws"              # optional, defaults to all of --providers
+  match = "base-*"              # glob matched against the image names
+  keep = 5                      # newest images to keep per account and region
+  max_age = "90d"               # keep images younger than it
+  exclude_tags = ["keep=true"]  # never touch images with any of the tags
+
+`
+	flagSet.Usage = func() {
+		fmt.Fprint(os.Stderr, g.helpMsg)
+	}
+
+	flagSet.SetOutput(ioutil.Discard) // don't print anything without my permission
+	g.flagSet = flagSet
+	return g
+}
+
+func (g *GC) Help() string {
+	return newGCFlags().helpMsg + providersHelp(provider.CanDelete)
+}
+
+func (g *GC) Run(args []string) int {
+	if flags.Has("help", args) {
+		fmt.Print(g.Help())
+		return 1
+	}
+
+	f := newGCFlags()
+	args, err := parseOwnFlags(f.flagSet, args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	rules, err := loadRetention(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	names, err := g.providers(rules)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	providers, images, err := fetchDeletable(g.Context, names, args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		fmt.Fprintln(os.Stderr, "GC cancelled, no images are deleted.")
+		return 1
+	}
+
+	// every image is evaluated by the first matching rule only, so rules
+	// don't compete for the same images
+	matched := make(map[*provider.Image]bool)
+	plan := make([]*pruneItem, 0)
+	for _, rule := range rules {
+		var ruleImages provider.Images
+		for _, image := range images {
+			if !matched[image] && rule.matches(image) {
+				matched[image] = true
+				ruleImages = append(ruleImages, image)
+			}
+		}
+
+		group := func(*provider.Image) (string, bool) { return rule.Match, true }
+		r := retention{keep: rule.Keep, maxAge: rule.maxAge}
+		plan = append(plan, newPlan(ruleImages, group, r)...)
+	}
+
+	if len(plan) == 0 {
+		g.Ui.Output("No images found matching the retention rules.")
+		return 0
+	}
+
+	return g.apply(plan, providers, f.dryRun)
+}
+
+func (g *GC) Synopsis() string {
+	return "Delete images according to the retention rules"
+}
+
+// providers returns the providers to be used. These are the providers passed
+// with --providers, otherwise the providers of the rules. Rules without a
+// provider require --providers, expanding them to every registered provider
+// would fail for the ones which are not configured.
+func (g *GC) providers(rules []*RetentionRule) ([]string, error) {
+	if len(g.Providers) == 1 && g.Providers[0] == "all" {
+		return provider.Names(provider.CanDelete), nil
+	}
+
+	if len(g.Providers) != 0 {
+		return g.Providers, nil
+	}
+
+	seen := make(map[string]bool)
+	names := make([]string, 0)
+	for _, rule := range rules {
+		if rule.Provider == "" {
+			return nil, fmt.Errorf("retention rule '%s' applies to all providers, pass the providers to be used with [--providers]", rule.Match)
+		}
+
+		if !seen[rule.Provider] {
+			seen[rule.Provider] = true
+			names = append(names, rule.Provider)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, errors.New("no providers are defined with [--providers] or the retention rules")
+	}
+
+	return names, nil
+}
+
+// loadRetention loads and validates the retention rules of the configuration.
+func loadRetention(args []string) ([]*RetentionRule, error) {
+	var conf struct {
+		Retention []*RetentionRule `toml:"retention" json:"retention"`
+	}
+
+	if err := loader.Load(&conf, args); err != nil {
+		return nil, err
+	}
+
+	if len(conf.Retention) == 0 {
+		return nil, errors.New("no retention rules are defined in the configuration")
+	}
+
+	for i, rule := range conf.Retention {
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("retention rule #%d: %s", i+1, err)
+		}
+	}
+
+	return conf.Retention, nil
+}
@@ -0,0 +1,45 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/cli"
+)
+
+// Gce runs the commands which are specific to GCE, such as rollover.
+type Gce struct {
+	*Config
+}
+
+func NewGce(config *Config) cli.CommandFactory {
+	return func() (cli.Command, error) {
+		return &Gce{
+			Config: config,
+		}, nil
+	}
+}
+
+func (g *Gce) Help() string {
+	return `Usage: images gce <subcommand> [options]
+
+  Runs commands which are specific to GCE.
+
+Subcommands:
+
+  rollover    Deprecate the older images of a family in favor of the newest one
+
+`
+}
+
+func (g *Gce) Run(args []string) int {
+	if len(args) == 0 || args[0] != "rollover" {
+		fmt.Print(g.Help())
+		return 1
+	}
+
+	return g.rollover("gce", args[1:])
+}
+
+func (g *Gce) Synopsis() string {
+	return "Run GCE specific commands, such as rollover"
+}
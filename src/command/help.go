@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"log"
+	"provider"
+	"provider/plugin"
 	"sort"
 	"text/tabwriter"
 
@@ -58,6 +60,15 @@ func HelpFunc(commands map[string]cli.CommandFactory) string {
 		fmt.Fprintf(w, "   -%s\t%s\n", flag, helps[flag])
 	}
 
+	// plugins are not discovered for the help message, it has to respond
+	// right away
+	fmt.Fprintf(w, "\nAvailable providers are:\n")
+	for _, name := range provider.Registered(0) {
+		r, _ := provider.Lookup(name)
+		fmt.Fprintf(w, "    %s\t%s\n", name, r.Capabilities)
+	}
+	fmt.Fprintf(w, "    <plugin>\tfrom %s<plugin> on PATH, loaded on demand\n", plugin.Prefix)
+
 	w.Flush()
 	return buf.String()
 }
@@ -3,6 +3,8 @@ package command
 import (
 	"fmt"
 	"os"
+	"provider"
+	"provider/utils"
 	"sync"
 
 	"github.com/fatih/flags"
@@ -22,6 +24,14 @@ func NewList(config *Config) cli.CommandFactory {
 	}
 }
 
+// formatHelp is appended to the help messages of the list command
+const formatHelp = `
+Format:
+
+  -format "..."      Print each image with the given Go template instead of
+                     an output mode, i.e: '{{.ID}} {{.Region}}'
+`
+
 func (l *List) Help() string {
 	if len(l.Providers) == 0 {
 		return `Usage: images list [options]
@@ -31,14 +41,16 @@ func (l *List) Help() string {
 Options:
 
   -providers "name,..."    Providers to be used to list images
-`
+  -output    "table"       Output mode of images. (default: "simplified")
+                           Available options: ` + utils.OutputsHelp + `
+` + formatHelp + filterHelp + "\n" + providersHelp(provider.CanList)
 	}
 
 	if len(l.Providers) == 1 && l.Providers[0] == "all" {
 		return "images: list images for all available providers"
 	}
 
-	return Help("list", l.Providers[0])
+	return Help("list", l.Providers[0]) + formatHelp + filterHelp
 }
 
 func (l *List) Run(args []string) int {
@@ -53,52 +65,97 @@ func (l *List) Run(args []string) int {
 	}
 
 	if len(l.Providers) == 1 && l.Providers[0] == "all" {
-		l.Providers = providerList
+		l.Providers = provider.Names(provider.CanList)
 	}
 
-	var (
-		wg          sync.WaitGroup
-		mu          sync.Mutex // protects multiErrors
-		multiErrors error
-	)
-
-	printProvider := func(provider string) error {
-		p, remArgs, err := Provider(provider, args)
-		if err != nil {
-			return err
+	output := utils.Simplified
+	if val, err := flags.Value("output", args); err == nil {
+		if err := utils.NewOutputValue(utils.Simplified, &output).Set(val); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return 1
 		}
+	}
 
-		lister, ok := p.(Lister)
-		if !ok {
-			return fmt.Errorf("Provider '%s' doesn't support listing images", provider)
+	var format string
+	if flags.Has("format", args) {
+		format, _ = flags.Value("format", args)
+		if format == "" {
+			fmt.Fprintln(os.Stderr, "no template is passed with [--format]")
+			return 1
 		}
+		args = flags.Exclude("format", args)
+	}
+
+	f, args, err := parseFilter(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	var (
+		wg        sync.WaitGroup
+		images    provider.Images
+		providers = make([]provider.Provider, len(l.Providers))
+		results   = make([]provider.Images, len(l.Providers))
+		errs      = make([]error, len(l.Providers))
+	)
 
-		if err := lister.List(remArgs); err != nil {
-			// we don't return here, because Print might display at least
-			// successfull results.
-			return err
+	fetchProvider := func(i int, name string) (provider.Images, error) {
+		p, remArgs, err := capableProvider(name, provider.CanList, args)
+		if err != nil {
+			return nil, err
 		}
 
-		fmt.Println("")
-		return nil
+		providers[i] = p
+		return p.Fetch(l.Context, remArgs)
 	}
 
-	for _, provider := range l.Providers {
+	// fetch all images first, so the output of multiple providers doesn't
+	// interleave and can be rendered as a single document. Each goroutine
+	// writes only to its own index.
+	for i, name := range l.Providers {
 		wg.Add(1)
-		go func(provider string) {
-			err := printProvider(provider)
-			if err != nil {
-				err = fmt.Errorf("%s: %s", provider, err)
-				mu.Lock()
-				multiErrors = multierror.Append(multiErrors, err)
-				mu.Unlock()
-			}
+		go func(i int, name string) {
+			results[i], errs[i] = fetchProvider(i, name)
 			wg.Done()
-		}(provider)
+		}(i, name)
 	}
 
 	wg.Wait()
 
+	var multiErrors error
+	for i, name := range l.Providers {
+		if errs[i] != nil {
+			multiErrors = multierror.Append(multiErrors, fmt.Errorf("%s: %s", name, errs[i]))
+			continue
+		}
+
+		images = append(images, results[i]...)
+	}
+
+	if f != nil {
+		images = f.Apply(images)
+	}
+
+	images.Sort()
+
+	// we don't return here in case of multiErrors, because Print might
+	// display at least the successful results.
+	if len(images) != 0 || multiErrors == nil {
+		var err error
+		if format != "" {
+			err = images.PrintTemplate(format)
+		} else {
+			err = images.Print(output)
+		}
+
+		if err != nil {
+			multiErrors = multierror.Append(multiErrors, err)
+		}
+	}
+
+	printRetries(providers...)
+
 	if multiErrors != nil {
 		fmt.Fprintln(os.Stderr, multiErrors.Error())
 		return 1
@@ -42,6 +42,13 @@ func FilterArgs(conf interface{}, args []string) []string {
 				continue
 			}
 
+			// boolean flags are passed without a value, i.e: "--force". An
+			// empty value would stop parsing the remaining flags.
+			if val == "" && field.Kind() == reflect.Bool {
+				configArgs = append(configArgs, "--"+fName)
+				continue
+			}
+
 			configArgs = append(configArgs, "--"+fName, val)
 		}
 	}
@@ -77,7 +84,9 @@ func ExcludeArgs(conf interface{}, args []string) []string {
 func Load(conf interface{}, args []string) error {
 	configArgs := FilterArgs(conf, args)
 
-	loaders := []multiconfig.Loader{}
+	// defaults are defined with the "default" struct tag and overridden by
+	// all other loaders
+	loaders := []multiconfig.Loader{&multiconfig.TagLoader{}}
 
 	// check for any files
 	path, ext, err := discoverConfigPath(DefaultConfigName)
@@ -3,6 +3,7 @@ package command
 import (
 	"fmt"
 	"os"
+	"provider"
 
 	"github.com/fatih/flags"
 	"github.com/mitchellh/cli"
@@ -29,11 +30,12 @@ func (m *Modify) Help() string {
 Options:
 
   -providers                  Provider to be used to modify images
-`
+
+` + providersHelp(provider.CanModify)
 		return defaultHelp
 	}
 
-	return Help("modify", m.Providers[0])
+	return Help("modify", m.Providers[0]) + filterHelp
 }
 
 func (m *Modify) Run(args []string) int {
@@ -47,26 +49,44 @@ func (m *Modify) Run(args []string) int {
 		return 1
 	}
 
-	provider := m.Providers[0]
-	if provider == "all" {
+	name := m.Providers[0]
+	if name == "all" {
 		fmt.Fprintln(os.Stderr, "Modify doesn't support multiple providers")
 		return 1
 	}
 
-	p, remArgs, err := Provider(provider, args)
+	f, args, err := parseFilter(args)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
 		return 1
 	}
 
+	p, remArgs, err := capableProvider(name, provider.CanModify, args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	defer printRetries(p)
+
 	mr, ok := p.(Modifier)
 	if !ok {
-		err := fmt.Errorf("'%s' doesn't support listing images", provider)
+		err := fmt.Errorf("'%s' doesn't support modifying images", name)
 		fmt.Fprintln(os.Stderr, err.Error())
 		return 1
 	}
 
-	if err := mr.Modify(remArgs); err != nil {
+	if f != nil {
+		images, selArgs, err := selectImages(m.Context, p, f, remArgs)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return 1
+		}
+
+		fmt.Printf("Modifying %d image(s) matching the filter\n", len(images))
+		remArgs = selArgs
+	}
+
+	if err := mr.Modify(m.Context, remArgs); err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
 		return 1
 	}
@@ -3,82 +3,151 @@ package command
 import (
 	"errors"
 	"fmt"
-	"provider/aws"
-	"provider/do"
-	"provider/gce"
-	"provider/sl"
-)
-
-var (
-	errNoProvider = errors.New("no such provider available")
+	"os"
+	"provider"
+	"strings"
 
-	// providerList is used when the provider name is set as "all"
-	providerList = []string{
-		"aws",
-		"do",
-		"gce",
-		"sl",
-	}
+	"golang.org/x/net/context"
 )
 
+var errNoProvider = errors.New("no such provider available")
+
 // Provider returns the provider with the given name and the filtered remaining
 // arguments. Each provider is responsible of how the remaining arguments are
-// returned.
-func Provider(name string, args []string) (interface{}, []string, error) {
-	switch name {
-	case "aws":
-		return aws.NewCommand(args)
-	case "do":
-		return do.NewCommand(args)
-	case "gce":
-		return gce.NewCommand(args)
-	case "sl":
-		return sl.NewCommand(args)
-	default:
+// returned. Additional capabilities, such as Deleter or Copier, are checked
+// with a type assertion on the returned provider.
+func Provider(name string, args []string) (provider.Provider, []string, error) {
+	r, ok := provider.Lookup(name)
+	if !ok {
 		return nil, nil, errNoProvider
 	}
+
+	return r.New(args)
 }
 
-// Lister lists and prints the images
-type Lister interface {
-	List(args []string) error
+// capableProvider returns the provider with the given name only if it's
+// registered with the given capability.
+func capableProvider(name string, c provider.Capability, args []string) (provider.Provider, []string, error) {
+	r, ok := provider.Lookup(name)
+	if !ok {
+		return nil, nil, fmt.Errorf("provider '%s' doesn't exists", name)
+	}
+
+	if !r.Can(c) {
+		return nil, nil, fmt.Errorf("'%s' doesn't support the %s command", name, c)
+	}
+
+	return r.New(args)
+}
+
+// providersHelp returns a help line with the names of the builtin providers
+// which support the given capability. Plugins are not discovered for help
+// messages.
+func providersHelp(c provider.Capability) string {
+	return "Available providers: " + strings.Join(provider.Registered(c), ", ") + "\n"
 }
 
-// Copier copyies the image.
+// Copier copyies the image. Like all other capabilities it stops once ctx
+// is done and reports which items are finished via an
+// *utils.InterruptedError if possible.
 type Copier interface {
-	Copy(args []string) error
+	Copy(ctx context.Context, args []string) error
 }
 
 // Deleter delets images
 type Deleter interface {
-	Delete(args []string) error
+	Delete(ctx context.Context, args []string) error
 }
 
 // Modifier modifies image attributes
 type Modifier interface {
-	Modify(args []string) error
+	Modify(ctx context.Context, args []string) error
 }
 
-// Helper returns the help message
-type Helper interface {
-	Help(command string) string
+// Waiter waits until images reach a state, i.e. until they are ready to be
+// used. It fails if an image fails or ctx is done before.
+type Waiter interface {
+	Wait(ctx context.Context, args []string) error
 }
 
-func Help(command, name string) string {
-	p, _, err := Provider(name, nil)
-	if err != nil {
-		if err == errNoProvider {
-			return "Provider '" + name + "' doesn't exists."
+// Roller deprecates the older images of a family in favor of the newest
+// one.
+type Roller interface {
+	Rollover(ctx context.Context, args []string) error
+}
+
+// SnapshotCleaner finds and deletes orphaned snapshots, whose images or disks
+// don't exist anymore.
+type SnapshotCleaner interface {
+	// OrphanedSnapshots returns the orphaned snapshots. args are the
+	// arguments which are not consumed by the provider configuration.
+	OrphanedSnapshots(ctx context.Context, args []string) (provider.Snapshots, error)
+
+	// DeleteSnapshots deletes the given snapshots, which are returned by
+	// OrphanedSnapshots.
+	DeleteSnapshots(ctx context.Context, snapshots provider.Snapshots) error
+}
+
+// Selector returns the arguments which select the given images for the Delete
+// and Modify methods of a provider. The images are the ones returned by the
+// Fetch method of the same provider. It's used by commands which select the
+// images themselves, such as prune.
+type Selector interface {
+	SelectArgs(images provider.Images) []string
+}
+
+// ListArgsSplitter is implemented by providers whose list command accepts
+// other flags than their delete and modify commands. SplitListArgs splits
+// args into the flags which are passed to Fetch when images are selected with
+// --filter, i.e. flags which narrow down the listed images, and the remaining
+// ones. Providers which don't implement it get all arguments for Fetch.
+type ListArgsSplitter interface {
+	SplitListArgs(args []string) (list, rest []string)
+}
+
+// Describer returns all attributes of a single image, which is returned by
+// the Fetch method of the same provider.
+type Describer interface {
+	Describe(ctx context.Context, image *provider.Image) (*provider.Details, error)
+}
+
+// Retrier is implemented by providers which retry failed and rate limited
+// requests. The retries are reported once a command is finished.
+type Retrier interface {
+	// Retries returns the number of retried requests and how many of them
+	// were rate limited.
+	Retries() (total, throttled int)
+}
+
+// printRetries prints the retried requests of the given providers to stderr,
+// so rate limits don't go unnoticed. Nil providers are ignored.
+func printRetries(providers ...provider.Provider) {
+	for _, p := range providers {
+		r, ok := p.(Retrier)
+		if !ok {
+			continue
+		}
+
+		total, throttled := r.Retries()
+		if total == 0 {
+			continue
 		}
 
-		// provider exists but failed, print the help for it
-		fmt.Println(err, "\n")
+		fmt.Fprintf(os.Stderr, "%s: retried %d request(s), %d of them rate limited\n",
+			p.Name(), total, throttled)
 	}
+}
 
-	h, ok := p.(Helper)
+// Help returns the help message of the given provider for the given command.
+func Help(command, name string) string {
+	r, ok := provider.Lookup(name)
 	if !ok {
+		return "Provider '" + name + "' doesn't exists."
+	}
+
+	if r.Help == nil {
 		return "No help context available for " + name
 	}
 
-	return h.Help(command)
+	return r.Help(command)
 }
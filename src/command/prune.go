@@ -0,0 +1,528 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"provider"
+	"provider/utils"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/fatih/flags"
+	"github.com/hashicorp/go-multierror"
+	"github.com/mitchellh/cli"
+	"github.com/shiena/ansicolor"
+	"golang.org/x/net/context"
+)
+
+type Prune struct {
+	*Config
+}
+
+func NewPrune(config *Config) cli.CommandFactory {
+	return func() (cli.Command, error) {
+		return &Prune{
+			Config: config,
+		}, nil
+	}
+}
+
+type pruneFlags struct {
+	// prefixes groups the images by the longest matching name prefix
+	prefixes []string
+
+	// tag groups the images by the value of the given tag key
+	tag string
+
+	// keep is the number of the newest images to be kept per group
+	keep int
+
+	// keepWithin keeps all images which are younger than the duration
+	keepWithin time.Duration
+
+	// dryRun only prints the plan
+	dryRun bool
+
+	helpMsg string
+	flagSet *flag.FlagSet
+}
+
+func newPruneFlags() *pruneFlags {
+	p := &pruneFlags{}
+
+	flagSet := flag.NewFlagSet("prune", flag.ContinueOnError)
+	flagSet.Var(flags.NewStringSlice(nil, &p.prefixes), "prefix", "Group images by the given name prefixes")
+	flagSet.StringVar(&p.tag, "tag", "", "Group images by the value of the given tag")
+	flagSet.IntVar(&p.keep, "keep", 0, "Number of the newest images to be kept per group")
+	flagSet.Var(utils.NewDurationValue(0, &p.keepWithin), "keep-within", "Keep images younger than the duration")
+	flagSet.BoolVar(&p.dryRun, "dry-run", false, "Don't delete images, only show the plan")
+	p.helpMsg = `Usage: images prune [options]
+
+  Deletes old images. Images are grouped by a name prefix and/or a tag and
+  the newest images of each group, account and region are kept, all others
+  are deleted. Images which don't belong to any group are never deleted. A plan is
+  printed before anything is deleted.
+
+Options:
+
+  -providers   "name,..."      Providers to be used to prune images
+  -prefix      "base-,..."     Group images by the longest matching name prefix
+  -tag         "role"          Group images by the value of the given tag
+  -keep        3               Number of the newest images to be kept per group
+  -keep-within "30d"           Keep images younger than the duration. Supports
+                               the units "d" and "w" in addition to "h", "m", "s"
+  -dry-run                     Don't delete images, only show the plan
+
+At least one of -prefix or -tag and one of -keep or -keep-within is required.
+If both -keep and -keep-within are given, an image is kept if it matches any
+of them. Provider specific options, such as -regions, are passed to the
+providers.
+
+`
+	flagSet.Usage = func() {
+		fmt.Fprint(os.Stderr, p.helpMsg)
+	}
+
+	flagSet.SetOutput(ioutil.Discard) // don't print anything without my permission
+	p.flagSet = flagSet
+	return p
+}
+
+// parse parses the prune flags from args and returns the remaining arguments,
+// which belong to the providers.
+func (p *pruneFlags) parse(args []string) ([]string, error) {
+	args, err := parseOwnFlags(p.flagSet, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(p.prefixes) == 0 && p.tag == "" {
+		return nil, errors.New("no groups are defined with [--prefix] or [--tag]")
+	}
+
+	if p.keep <= 0 && p.keepWithin <= 0 {
+		return nil, errors.New("no images are kept, use [--keep] or [--keep-within]")
+	}
+
+	return args, nil
+}
+
+// parseOwnFlags parses the flags of the given flag set from args and returns
+// the remaining arguments. Unlike flagSet.Parse it doesn't fail on unknown
+// flags, so commands can pass the remaining arguments to the providers.
+func parseOwnFlags(flagSet *flag.FlagSet, args []string) ([]string, error) {
+	var names []string
+	flagSet.VisitAll(func(f *flag.Flag) {
+		names = append(names, f.Name)
+	})
+
+	own, rest := utils.SplitFlags(flagSet, args, names...)
+	if err := flagSet.Parse(own); err != nil {
+		return nil, err
+	}
+
+	return rest, nil
+}
+
+// group returns the group name of the given image. It returns false if the
+// image doesn't belong to any group.
+func (p *pruneFlags) group(image *provider.Image) (string, bool) {
+	var groups []string
+
+	if len(p.prefixes) != 0 {
+		prefix := ""
+		for _, pre := range p.prefixes {
+			if strings.HasPrefix(image.Name, pre) && len(pre) > len(prefix) {
+				prefix = pre
+			}
+		}
+
+		if prefix == "" {
+			return "", false
+		}
+
+		groups = append(groups, prefix+"*")
+	}
+
+	if p.tag != "" {
+		val, ok := image.Tags[p.tag]
+		if !ok {
+			return "", false
+		}
+
+		groups = append(groups, p.tag+"="+val)
+	}
+
+	return strings.Join(groups, " "), true
+}
+
+// pruneItem is a single image of a prune plan
+type pruneItem struct {
+	image *provider.Image
+	group string
+
+	// reason is the reason why the image is kept. An empty reason means
+	// the image is deleted.
+	reason string
+}
+
+func (p *pruneItem) deleted() bool { return p.reason == "" }
+
+// retention defines which images of a group are kept. An image is kept if
+// it's one of the newest keep images or if it's younger than maxAge.
+type retention struct {
+	keep   int
+	maxAge time.Duration
+}
+
+// plan returns the plan for the images of a single group and region. The
+// images must be sorted by their creation time, newest first.
+func (r retention) plan(group string, images provider.Images, now time.Time) []*pruneItem {
+	items := make([]*pruneItem, len(images))
+	for i, image := range images {
+		item := &pruneItem{image: image, group: group}
+
+		switch {
+		case image.CreatedAt.IsZero():
+			// never delete images we don't know anything about
+			item.reason = "unknown age"
+		case i < r.keep:
+			item.reason = fmt.Sprintf("newest %d", r.keep)
+		case r.maxAge > 0 && now.Sub(image.CreatedAt) < r.maxAge:
+			item.reason = "younger than " + r.maxAge.String()
+		}
+
+		items[i] = item
+	}
+
+	return items
+}
+
+// planKey identifies the images which are pruned together
+type planKey struct {
+	provider, account, region, group string
+}
+
+// newPlan groups the images with the given group func and applies the
+// retention to each group, account and region. Images without a group are
+// left out.
+func newPlan(images provider.Images, group func(*provider.Image) (string, bool), r retention) []*pruneItem {
+	groups := make(map[planKey]provider.Images)
+	keys := make([]planKey, 0)
+
+	for _, image := range images {
+		name, ok := group(image)
+		if !ok {
+			continue
+		}
+
+		key := planKey{
+			provider: image.Provider,
+			account:  image.Account,
+			region:   image.Region,
+			group:    name,
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+
+		groups[key] = append(groups[key], image)
+	}
+
+	sort.Sort(byPlanKey(keys))
+
+	now := time.Now()
+	plan := make([]*pruneItem, 0)
+	for _, key := range keys {
+		g := groups[key]
+		sort.Sort(byNewest(g))
+		plan = append(plan, r.plan(key.group, g, now)...)
+	}
+
+	return plan
+}
+
+func (p *Prune) Help() string {
+	return newPruneFlags().helpMsg + providersHelp(provider.CanDelete)
+}
+
+func (p *Prune) Run(args []string) int {
+	if len(p.Providers) == 0 || flags.Has("help", args) {
+		fmt.Print(p.Help())
+		return 1
+	}
+
+	f := newPruneFlags()
+	args, err := f.parse(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	if len(p.Providers) == 1 && p.Providers[0] == "all" {
+		p.Providers = provider.Names(provider.CanDelete)
+	}
+
+	providers, images, err := fetchDeletable(p.Context, p.Providers, args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		fmt.Fprintln(os.Stderr, "Prune cancelled, no images are deleted.")
+		return 1
+	}
+
+	plan := newPlan(images, f.group, retention{keep: f.keep, maxAge: f.keepWithin})
+	if len(plan) == 0 {
+		p.Ui.Output("No images found matching the given groups.")
+		return 0
+	}
+
+	return p.apply(plan, providers, f.dryRun)
+}
+
+func (p *Prune) Synopsis() string {
+	return "Delete old images, keeping the newest of each group"
+}
+
+// apply prints the plan and deletes the images of the plan which are not
+// kept. It asks for confirmation unless --force is enabled. It returns the exit
+// status of the command.
+func (c *Config) apply(plan []*pruneItem, providers map[string]provider.Provider, dryRun bool) int {
+	// retries of fetching and deleting the images are reported last
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	defer func() {
+		for _, name := range names {
+			printRetries(providers[name])
+		}
+	}()
+
+	printPlan(plan)
+
+	deleted := make(map[string]provider.Images)
+	total := 0
+	for _, item := range plan {
+		if item.deleted() {
+			deleted[item.image.Provider] = append(deleted[item.image.Provider], item.image)
+			total++
+		}
+	}
+
+	if total == 0 {
+		c.Ui.Output("Nothing to prune.")
+		return 0
+	}
+
+	if dryRun {
+		c.Ui.Output(fmt.Sprintf("Dry run: %d image(s) would be deleted.", total))
+		return 0
+	}
+
+	// Don't ask for question if --force is enabled
+	if !c.Force {
+		response, err := c.Ui.Ask(fmt.Sprintf("Do you really want to delete %d image(s)? (Type 'yes' to continue):", total))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return 1
+		}
+
+		if response != "yes" {
+			c.Ui.Output("Prune cancelled.")
+			return 0
+		}
+	}
+
+	if err := deleteImages(c.Context, providers, deleted); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	c.Ui.Output(fmt.Sprintf("%d image(s) deleted.", total))
+	return 0
+}
+
+// fetchDeletable fetches the images of the given providers concurrently. It
+// fails if any of the providers can't fetch its images or doesn't support
+// deleting the fetched images. The returned images are sorted.
+func fetchDeletable(ctx context.Context, names []string, args []string) (map[string]provider.Provider, provider.Images, error) {
+	var (
+		wg      sync.WaitGroup
+		results = make([]provider.Images, len(names))
+		ps      = make([]provider.Provider, len(names))
+		errs    = make([]error, len(names))
+	)
+
+	fetchProvider := func(name string) (provider.Provider, provider.Images, error) {
+		p, remArgs, err := capableProvider(name, provider.CanDelete, args)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if _, ok := p.(Deleter); !ok {
+			return nil, nil, fmt.Errorf("'%s' doesn't support deleting images", name)
+		}
+
+		if _, ok := p.(Selector); !ok {
+			return nil, nil, fmt.Errorf("'%s' doesn't support selecting images", name)
+		}
+
+		images, err := p.Fetch(ctx, remArgs)
+		return p, images, err
+	}
+
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			ps[i], results[i], errs[i] = fetchProvider(name)
+			wg.Done()
+		}(i, name)
+	}
+
+	wg.Wait()
+
+	var (
+		multiErrors error
+		images      provider.Images
+		providers   = make(map[string]provider.Provider, len(names))
+	)
+
+	for i, name := range names {
+		if errs[i] != nil {
+			multiErrors = multierror.Append(multiErrors, fmt.Errorf("%s: %s", name, errs[i]))
+			continue
+		}
+
+		providers[name] = ps[i]
+		for _, image := range results[i] {
+			// plugins might not set it, but we depend on it
+			image.Provider = name
+		}
+		images = append(images, results[i]...)
+	}
+
+	if multiErrors != nil {
+		return nil, nil, multiErrors
+	}
+
+	images.Sort()
+	return providers, images, nil
+}
+
+// deleteImages deletes the given images, grouped by their provider names, via
+// the Delete method of each provider.
+func deleteImages(ctx context.Context, providers map[string]provider.Provider, images map[string]provider.Images) error {
+	var (
+		wg          sync.WaitGroup
+		mu          sync.Mutex // protects multiErrors
+		multiErrors error
+	)
+
+	for name, imgs := range images {
+		wg.Add(1)
+		go func(name string, imgs provider.Images) {
+			defer wg.Done()
+
+			p := providers[name]
+			args := p.(Selector).SelectArgs(imgs)
+			if err := p.(Deleter).Delete(ctx, args); err != nil {
+				mu.Lock()
+				multiErrors = multierror.Append(multiErrors, fmt.Errorf("%s: %s", name, err))
+				mu.Unlock()
+			}
+		}(name, imgs)
+	}
+
+	wg.Wait()
+	return multiErrors
+}
+
+// printPlan prints the given plan as a table to stdout.
+func printPlan(plan []*pruneItem) {
+	green := color.New(color.FgGreen).SprintFunc()
+	red := color.New(color.FgRed).SprintFunc()
+
+	w := utils.NewImagesTabWriter(ansicolor.NewAnsiColorWriter(os.Stdout))
+	defer w.Flush()
+
+	account := false
+	for _, item := range plan {
+		if item.image.Account != "" {
+			account = true
+			break
+		}
+	}
+
+	if account {
+		fmt.Fprint(w, "Provider\tAccount\t")
+	} else {
+		fmt.Fprint(w, "Provider\t")
+	}
+	fmt.Fprintln(w, "Region\tGroup\tName\tID\tCreated\tAction")
+
+	for _, item := range plan {
+		action := red("delete")
+		if !item.deleted() {
+			action = green("keep") + " (" + item.reason + ")"
+		}
+
+		created := "-"
+		if !item.image.CreatedAt.IsZero() {
+			created = item.image.CreatedAt.Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(w, "%s\t", item.image.Provider)
+		if account {
+			fmt.Fprintf(w, "%s\t", item.image.Account)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			item.image.Region, item.group, item.image.Name,
+			item.image.ID, created, action)
+	}
+
+	fmt.Fprintln(w, "")
+}
+
+// byPlanKey implements sort.Interface for planKey based on the provider,
+// account, region and group fields.
+type byPlanKey []planKey
+
+func (a byPlanKey) Len() int      { return len(a) }
+func (a byPlanKey) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byPlanKey) Less(i, j int) bool {
+	if a[i].provider != a[j].provider {
+		return a[i].provider < a[j].provider
+	}
+
+	if a[i].account != a[j].account {
+		return a[i].account < a[j].account
+	}
+
+	if a[i].region != a[j].region {
+		return a[i].region < a[j].region
+	}
+
+	return a[i].group < a[j].group
+}
+
+// byNewest implements sort.Interface for Images based on the CreatedAt field,
+// newest first.
+type byNewest provider.Images
+
+func (a byNewest) Len() int      { return len(a) }
+func (a byNewest) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byNewest) Less(i, j int) bool {
+	if !a[i].CreatedAt.Equal(a[j].CreatedAt) {
+		return a[i].CreatedAt.After(a[j].CreatedAt)
+	}
+
+	return a[i].ID > a[j].ID
+}
@@ -0,0 +1,189 @@
+package command
+
+import (
+	"provider"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewPlan(t *testing.T) {
+	now := time.Now()
+	image := func(id, account, region, name string, age time.Duration) *provider.Image {
+		img := &provider.Image{
+			Provider: "aws",
+			Account:  account,
+			Region:   region,
+			ID:       id,
+			Name:     name,
+		}
+
+		if age != 0 {
+			img.CreatedAt = now.Add(-age)
+		}
+		return img
+	}
+
+	day := 24 * time.Hour
+	p := &pruneFlags{prefixes: []string{"base-", "base-web-"}}
+
+	tests := []struct {
+		name   string
+		images provider.Images
+		r      retention
+
+		// plan is the expected plan in the form of "id action"
+		plan []string
+	}{
+		{
+			name: "keep newest",
+			images: provider.Images{
+				image("1", "", "us-east-1", "base-1", 3*day),
+				image("2", "", "us-east-1", "base-2", 2*day),
+				image("3", "", "us-east-1", "base-3", 1*day),
+			},
+			r:    retention{keep: 2},
+			plan: []string{"3 newest 2", "2 newest 2", "1 delete"},
+		},
+		{
+			name: "keep within",
+			images: provider.Images{
+				image("1", "", "us-east-1", "base-1", 40*day),
+				image("2", "", "us-east-1", "base-2", 20*day),
+				image("3", "", "us-east-1", "base-3", 10*day),
+			},
+			r:    retention{keep: 1, maxAge: 30 * day},
+			plan: []string{"3 newest 1", "2 younger than 720h0m0s", "1 delete"},
+		},
+		{
+			name: "unknown age",
+			images: provider.Images{
+				image("1", "", "us-east-1", "base-1", 0),
+				image("2", "", "us-east-1", "base-2", 2*day),
+				image("3", "", "us-east-1", "base-3", 3*day),
+			},
+			r:    retention{keep: 1},
+			plan: []string{"2 newest 1", "3 delete", "1 unknown age"},
+		},
+		{
+			name: "ungrouped images",
+			images: provider.Images{
+				image("1", "", "us-east-1", "web-1", 3*day),
+				image("2", "", "us-east-1", "base-2", 2*day),
+			},
+			r:    retention{keep: 1},
+			plan: []string{"2 newest 1"},
+		},
+		{
+			name: "longest prefix",
+			images: provider.Images{
+				image("1", "", "us-east-1", "base-web-1", 3*day),
+				image("2", "", "us-east-1", "base-2", 2*day),
+				image("3", "", "us-east-1", "base-web-3", 1*day),
+			},
+			r:    retention{keep: 1},
+			plan: []string{"2 newest 1", "3 newest 1", "1 delete"},
+		},
+		{
+			name: "per region",
+			images: provider.Images{
+				image("1", "", "us-east-1", "base-1", 3*day),
+				image("2", "", "eu-west-1", "base-2", 2*day),
+				image("3", "", "us-east-1", "base-3", 1*day),
+			},
+			r:    retention{keep: 1},
+			plan: []string{"2 newest 1", "3 newest 1", "1 delete"},
+		},
+		{
+			name: "per account",
+			images: provider.Images{
+				image("1", "prod", "us-east-1", "base-1", 4*day),
+				image("2", "prod", "us-east-1", "base-2", 3*day),
+				image("3", "dev", "us-east-1", "base-3", 2*day),
+				image("4", "dev", "us-east-1", "base-4", 1*day),
+			},
+			r:    retention{keep: 1},
+			plan: []string{"4 newest 1", "3 delete", "2 newest 1", "1 delete"},
+		},
+	}
+
+	for _, test := range tests {
+		plan := make([]string, 0)
+		for _, item := range newPlan(test.images, p.group, test.r) {
+			action := "delete"
+			if !item.deleted() {
+				action = item.reason
+			}
+			plan = append(plan, item.image.ID+" "+action)
+		}
+
+		if !reflect.DeepEqual(plan, test.plan) {
+			t.Errorf("%s: plan is %q, want %q", test.name, plan, test.plan)
+		}
+	}
+}
+
+func TestPruneFlagsParse(t *testing.T) {
+	tests := []struct {
+		args    []string
+		remArgs []string
+		keep    int
+		prefix  []string
+		dryRun  bool
+		err     bool
+	}{
+		{
+			args:    []string{"-regions=us-east-1", "-keep=3", "-prefix=base-"},
+			remArgs: []string{"-regions=us-east-1"},
+			keep:    3,
+			prefix:  []string{"base-"},
+		},
+		{
+			args:    []string{"-keep", "3", "--regions", "us-east-1", "-prefix", "base-,web-", "-dry-run"},
+			remArgs: []string{"--regions", "us-east-1"},
+			keep:    3,
+			prefix:  []string{"base-", "web-"},
+			dryRun:  true,
+		},
+		{
+			args:    []string{"-dry-run", "-prefix", "base-", "-regions", "eu-west-1", "--keep=2"},
+			remArgs: []string{"-regions", "eu-west-1"},
+			keep:    2,
+			prefix:  []string{"base-"},
+			dryRun:  true,
+		},
+		{
+			args: []string{"-regions=us-east-1", "-keep=3"},
+			err:  true,
+		},
+		{
+			args: []string{"-prefix=base-", "-keep=three"},
+			err:  true,
+		},
+	}
+
+	for _, test := range tests {
+		p := newPruneFlags()
+		remArgs, err := p.parse(test.args)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: expected an error", test.args)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q: %s", test.args, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(remArgs, test.remArgs) {
+			t.Errorf("%q: remaining args are %q, want %q", test.args, remArgs, test.remArgs)
+		}
+
+		if p.keep != test.keep || p.dryRun != test.dryRun || !reflect.DeepEqual(p.prefixes, test.prefix) {
+			t.Errorf("%q: keep=%d dry-run=%t prefixes=%q, want keep=%d dry-run=%t prefixes=%q",
+				test.args, p.keep, p.dryRun, p.prefixes, test.keep, test.dryRun, test.prefix)
+		}
+	}
+}
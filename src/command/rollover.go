@@ -0,0 +1,40 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"provider"
+
+	"github.com/fatih/flags"
+)
+
+// rollover deprecates the older images of a family on the given provider in
+// favor of the newest one. It's a subcommand of the provider commands, i.e:
+// "images gce rollover". It returns the exit status of the command.
+func (c *Config) rollover(name string, args []string) int {
+	if flags.Has("help", args) {
+		fmt.Print(Help("rollover", name))
+		return 1
+	}
+
+	p, remArgs, err := capableProvider(name, provider.CanRollover, args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	defer printRetries(p)
+
+	roller, ok := p.(Roller)
+	if !ok {
+		err := fmt.Errorf("'%s' doesn't support rolling over images", name)
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	if err := roller.Rollover(c.Context, remArgs); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,204 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"provider"
+	"provider/utils"
+	"strings"
+	"sync"
+
+	"github.com/fatih/flags"
+	"github.com/hashicorp/go-multierror"
+	"github.com/mitchellh/cli"
+	"golang.org/x/net/context"
+)
+
+type Show struct {
+	*Config
+}
+
+func NewShow(config *Config) cli.CommandFactory {
+	return func() (cli.Command, error) {
+		return &Show{
+			Config: config,
+		}, nil
+	}
+}
+
+func (s *Show) Help() string {
+	return `Usage: images show <ref> [options]
+
+  Shows all attributes of a single image. The reference is the id or the name
+  of the image, optionally prefixed with the provider, i.e: "aws:ami-530ay345"
+  or "gce:base-ubuntu". Images of named accounts are also looked up by the
+  account qualified id or name, i.e: "aws:prod:ami-530ay345". Without a
+  provider prefix the image is looked up on all given providers (by default
+  all providers) and must be unique.
+
+Options:
+
+  -providers "name,..."    Providers to look up the image
+  -output    "json"        Output mode of the image. (default: "simplified")
+                           Available options: "simplified" or "json"
+
+Provider specific options, such as -regions, are passed to the providers.
+
+` + providersHelp(provider.CanList)
+}
+
+func (s *Show) Run(args []string) int {
+	if len(args) == 0 || flags.Valid(args[0]) || flags.Has("help", args) {
+		fmt.Print(s.Help())
+		return 1
+	}
+
+	ref, args := args[0], args[1:]
+
+	output := utils.Simplified
+	if flags.Has("output", args) {
+		val, _ := flags.Value("output", args)
+		if err := utils.NewOutputValue(utils.Simplified, &output).Set(val); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return 1
+		}
+
+		if output != utils.Simplified && output != utils.JSON {
+			fmt.Fprintf(os.Stderr, "output mode '%s' is not supported, use \"simplified\" or \"json\"\n", output)
+			return 1
+		}
+		args = flags.Exclude("output", args)
+	}
+
+	names := s.Providers
+	if len(names) == 0 || (len(names) == 1 && names[0] == "all") {
+		names = provider.Names(provider.CanList)
+	}
+
+	// "aws:ami-123" looks up the image only on the given provider
+	if i := strings.Index(ref, ":"); i > 0 {
+		if _, ok := provider.Lookup(ref[:i]); ok {
+			names, ref = []string{ref[:i]}, ref[i+1:]
+		}
+	}
+
+	p, image, err := resolveImage(s.Context, names, ref, args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	defer printRetries(p)
+
+	details := provider.NewDetails(image)
+	if d, ok := p.(Describer); ok {
+		details, err = d.Describe(s.Context, image)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return 1
+		}
+	}
+
+	if err := details.Print(output); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	return 0
+}
+
+func (s *Show) Synopsis() string {
+	return "Show all attributes of an image"
+}
+
+// resolveImage looks up the image with the given id or name on the given
+// providers concurrently. The image must be unique across all providers.
+// Errors of providers are only reported if the image isn't found, so
+// providers which are not configured don't prevent looking up an image.
+func resolveImage(ctx context.Context, names []string, ref string, args []string) (provider.Provider, *provider.Image, error) {
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex // protects the fields below
+		providers = make(map[string]provider.Provider)
+		found     provider.Images
+		errs      error
+	)
+
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+
+			p, remArgs, err := capableProvider(name, provider.CanList, args)
+			if err == nil {
+				var images provider.Images
+				images, err = p.Fetch(ctx, remArgs)
+
+				mu.Lock()
+				providers[name] = p
+				for _, image := range images {
+					if matchesRef(image, ref) {
+						image.Provider = name
+						found = append(found, image)
+					}
+				}
+				mu.Unlock()
+			}
+
+			if err != nil {
+				mu.Lock()
+				errs = multierror.Append(errs, fmt.Errorf("%s: %s", name, err))
+				mu.Unlock()
+			}
+		}(name)
+	}
+
+	wg.Wait()
+
+	switch len(found) {
+	case 0:
+		msg := fmt.Sprintf("no image found for '%s'", ref)
+		if errs != nil {
+			msg += "\n" + errs.Error()
+		}
+		return nil, nil, errors.New(msg)
+	case 1:
+		return providers[found[0].Provider], found[0], nil
+	}
+
+	found.Sort()
+
+	refs := make([]string, len(found))
+	for i, image := range found {
+		refs[i] = fmt.Sprintf("  %s:%s (%s)", image.Provider, qualifiedID(image),
+			strings.TrimSpace(image.Name+" "+image.Region))
+	}
+
+	return nil, nil, fmt.Errorf("multiple images found for '%s', use one of:\n%s",
+		ref, strings.Join(refs, "\n"))
+}
+
+// matchesRef returns true if the image has the given id or name. Images of
+// named accounts also match the account qualified id or name, i.e:
+// "prod:ami-123".
+func matchesRef(image *provider.Image, ref string) bool {
+	if image.ID == ref || image.Name == ref {
+		return true
+	}
+
+	if image.Account == "" || !strings.HasPrefix(ref, image.Account+":") {
+		return false
+	}
+
+	ref = strings.TrimPrefix(ref, image.Account+":")
+	return image.ID == ref || image.Name == ref
+}
+
+// qualifiedID returns the id of the image, qualified with the account for
+// images of named accounts.
+func qualifiedID(image *provider.Image) string {
+	if image.Account == "" {
+		return image.ID
+	}
+	return image.Account + ":" + image.ID
+}
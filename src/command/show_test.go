@@ -0,0 +1,33 @@
+package command
+
+import (
+	"provider"
+	"testing"
+)
+
+func TestMatchesRef(t *testing.T) {
+	image := &provider.Image{ID: "ami-1", Name: "base"}
+	prod := &provider.Image{ID: "ami-1", Name: "base", Account: "prod"}
+
+	tests := []struct {
+		image *provider.Image
+		ref   string
+		match bool
+	}{
+		{image, "ami-1", true},
+		{image, "base", true},
+		{image, "ami-2", false},
+		{image, "prod:ami-1", false},
+		{prod, "ami-1", true},
+		{prod, "prod:ami-1", true},
+		{prod, "prod:base", true},
+		{prod, "dev:ami-1", false},
+		{prod, "prod:", false},
+	}
+
+	for _, test := range tests {
+		if match := matchesRef(test.image, test.ref); match != test.match {
+			t.Errorf("matchesRef(%q, %q) = %t, want %t", test.image.Account, test.ref, match, test.match)
+		}
+	}
+}
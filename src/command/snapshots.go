@@ -0,0 +1,270 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"provider"
+	"provider/utils"
+	"sort"
+	"sync"
+
+	"github.com/fatih/flags"
+	"github.com/hashicorp/go-multierror"
+	"github.com/mitchellh/cli"
+	"golang.org/x/net/context"
+)
+
+type Snapshots struct {
+	*Config
+}
+
+func NewSnapshots(config *Config) cli.CommandFactory {
+	return func() (cli.Command, error) {
+		return &Snapshots{
+			Config: config,
+		}, nil
+	}
+}
+
+type orphansFlags struct {
+	// delete deletes the orphaned snapshots after a confirmation
+	delete bool
+
+	output utils.OutputMode
+
+	helpMsg string
+	flagSet *flag.FlagSet
+}
+
+func newOrphansFlags() *orphansFlags {
+	o := &orphansFlags{}
+
+	flagSet := flag.NewFlagSet("orphans", flag.ContinueOnError)
+	flagSet.BoolVar(&o.delete, "delete", false, "Delete the orphaned snapshots")
+	flagSet.Var(utils.NewOutputValue(utils.Simplified, &o.output), "output", "Output mode")
+	o.helpMsg = `Usage: images snapshots orphans [options]
+
+  Lists the orphaned snapshots of all regions with their total size. A
+  snapshot is orphaned if it was created for an image or from a disk, which
+  doesn't exist anymore:
+
+    aws  EBS snapshots of deregistered AMI's, which aren't used by other AMI's
+    gce  Snapshots of deleted disks, which aren't used by any disk
+    do   Volume snapshots of deleted volumes
+
+Options:
+
+  -providers "name,..."    Providers to be used to find orphaned snapshots
+  -delete                  Delete the orphaned snapshots after a confirmation
+  -output    "json"        Output mode of the snapshots. (default: "simplified")
+                           Available options: "simplified" or "json"
+
+Provider specific options, such as -regions, are passed to the providers.
+
+`
+	flagSet.Usage = func() {
+		fmt.Fprint(os.Stderr, o.helpMsg)
+	}
+
+	flagSet.SetOutput(ioutil.Discard) // don't print anything without my permission
+	o.flagSet = flagSet
+	return o
+}
+
+func (s *Snapshots) Help() string {
+	return `Usage: images snapshots <subcommand> [options]
+
+  Manages the snapshots of images.
+
+Subcommands:
+
+  orphans    List or delete snapshots whose images or disks don't exist anymore
+
+` + providersHelp(provider.CanSnapshots)
+}
+
+func (s *Snapshots) Run(args []string) int {
+	if len(args) == 0 || args[0] != "orphans" {
+		fmt.Print(s.Help())
+		return 1
+	}
+
+	o := newOrphansFlags()
+	if len(s.Providers) == 0 || flags.Has("help", args) {
+		fmt.Print(o.helpMsg + providersHelp(provider.CanSnapshots))
+		return 1
+	}
+
+	args, err := parseOwnFlags(o.flagSet, args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	if o.output != utils.Simplified && o.output != utils.JSON {
+		fmt.Fprintf(os.Stderr, "output mode '%s' is not supported, use \"simplified\" or \"json\"\n", o.output)
+		return 1
+	}
+
+	if len(s.Providers) == 1 && s.Providers[0] == "all" {
+		s.Providers = provider.Names(provider.CanSnapshots)
+	}
+
+	providers, snapshots, err := fetchOrphans(s.Context, s.Providers, args)
+
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	defer func() {
+		for _, name := range names {
+			printRetries(providers[name])
+		}
+	}()
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	if len(snapshots) == 0 {
+		s.Ui.Output("No orphaned snapshots found.")
+		return 0
+	}
+
+	if err := snapshots.Print(o.output); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	if !o.delete {
+		return 0
+	}
+
+	// Don't ask for question if --force is enabled
+	if !s.Force {
+		response, err := s.Ui.Ask(fmt.Sprintf("Do you really want to delete %d snapshot(s)? (Type 'yes' to continue):", len(snapshots)))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return 1
+		}
+
+		if response != "yes" {
+			s.Ui.Output("Delete cancelled.")
+			return 0
+		}
+	}
+
+	if err := deleteSnapshots(s.Context, providers, snapshots); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	s.Ui.Output(fmt.Sprintf("%d snapshot(s) deleted, %dGB freed.", len(snapshots), snapshots.Size()))
+	return 0
+}
+
+func (s *Snapshots) Synopsis() string {
+	return "List or delete orphaned snapshots"
+}
+
+// fetchOrphans fetches the orphaned snapshots of the given providers
+// concurrently. It fails if any of the providers can't fetch its snapshots.
+// The returned snapshots are sorted.
+func fetchOrphans(ctx context.Context, names []string, args []string) (map[string]provider.Provider, provider.Snapshots, error) {
+	var (
+		wg      sync.WaitGroup
+		results = make([]provider.Snapshots, len(names))
+		ps      = make([]provider.Provider, len(names))
+		errs    = make([]error, len(names))
+	)
+
+	fetchProvider := func(name string) (provider.Provider, provider.Snapshots, error) {
+		p, remArgs, err := capableProvider(name, provider.CanSnapshots, args)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		cleaner, ok := p.(SnapshotCleaner)
+		if !ok {
+			return p, nil, fmt.Errorf("'%s' doesn't support snapshots", name)
+		}
+
+		snapshots, err := cleaner.OrphanedSnapshots(ctx, remArgs)
+		return p, snapshots, err
+	}
+
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			ps[i], results[i], errs[i] = fetchProvider(name)
+			wg.Done()
+		}(i, name)
+	}
+
+	wg.Wait()
+
+	var (
+		multiErrors error
+		snapshots   provider.Snapshots
+		providers   = make(map[string]provider.Provider, len(names))
+	)
+
+	for i, name := range names {
+		if ps[i] != nil {
+			providers[name] = ps[i]
+		}
+
+		if errs[i] != nil {
+			multiErrors = multierror.Append(multiErrors, fmt.Errorf("%s: %s", name, errs[i]))
+			continue
+		}
+
+		for _, snapshot := range results[i] {
+			snapshot.Provider = name
+		}
+		snapshots = append(snapshots, results[i]...)
+	}
+
+	if multiErrors != nil {
+		return providers, nil, multiErrors
+	}
+
+	snapshots.Sort()
+	return providers, snapshots, nil
+}
+
+// deleteSnapshots deletes the given snapshots via the DeleteSnapshots method
+// of their providers.
+func deleteSnapshots(ctx context.Context, providers map[string]provider.Provider, snapshots provider.Snapshots) error {
+	byProvider := make(map[string]provider.Snapshots)
+	for _, snapshot := range snapshots {
+		byProvider[snapshot.Provider] = append(byProvider[snapshot.Provider], snapshot)
+	}
+
+	var (
+		wg          sync.WaitGroup
+		mu          sync.Mutex // protects multiErrors
+		multiErrors error
+	)
+
+	for name, snaps := range byProvider {
+		wg.Add(1)
+		go func(name string, snaps provider.Snapshots) {
+			defer wg.Done()
+
+			if err := providers[name].(SnapshotCleaner).DeleteSnapshots(ctx, snaps); err != nil {
+				mu.Lock()
+				multiErrors = multierror.Append(multiErrors, fmt.Errorf("%s: %s", name, err))
+				mu.Unlock()
+			}
+		}(name, snaps)
+	}
+
+	wg.Wait()
+	return multiErrors
+}
@@ -0,0 +1,96 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"provider"
+	"time"
+
+	"github.com/fatih/flags"
+	"github.com/mitchellh/cli"
+	"golang.org/x/net/context"
+)
+
+// defaultWaitTimeout is the maximum duration of the wait command if the global
+// --timeout flag isn't set, so pipelines don't wait forever.
+const defaultWaitTimeout = time.Hour
+
+type Wait struct {
+	*Config
+}
+
+func NewWait(config *Config) cli.CommandFactory {
+	return func() (cli.Command, error) {
+		return &Wait{
+			Config: config,
+		}, nil
+	}
+}
+
+func (w *Wait) Help() string {
+	if len(w.Providers) != 1 {
+		return `Usage: images wait [options]
+
+  Wait until images are ready, i.e. after they are built or copied. It exits
+  with a non zero status if an image fails or the --timeout (default: "1h")
+  is exceeded.
+
+Options:
+
+  -providers [name]    Provider to be used to wait for images
+
+` + providersHelp(provider.CanWait)
+	}
+
+	return Help("wait", w.Providers[0])
+}
+
+func (w *Wait) Run(args []string) int {
+	if len(w.Providers) != 1 {
+		fmt.Print(w.Help())
+		return 1
+	}
+
+	if flags.Has("help", args) {
+		fmt.Print(w.Help())
+		return 1
+	}
+
+	name := w.Providers[0]
+	if name == "all" {
+		fmt.Fprintln(os.Stderr, "Wait doesn't support multiple providers")
+		return 1
+	}
+
+	p, remArgs, err := capableProvider(name, provider.CanWait, args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	defer printRetries(p)
+
+	waiter, ok := p.(Waiter)
+	if !ok {
+		err := fmt.Errorf("'%s' doesn't support waiting for images", name)
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	ctx := w.Context
+	if w.Timeout == "" {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultWaitTimeout)
+		defer cancel()
+	}
+
+	if err := waiter.Wait(ctx, remArgs); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	return 0
+}
+
+func (w *Wait) Synopsis() string {
+	return "Wait until images are ready"
+}
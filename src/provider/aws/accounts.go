@@ -0,0 +1,187 @@
+package aws
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// AccountConfig is a named AWS account of the configuration, i.e:
+//
+//	[[aws.accounts]]
+//	name     = "prod"
+//	role_arn = "arn:aws:iam::123456789012:role/images"
+//
+// Without an access key or a profile, the credentials of the [aws] section are
+// used. The role and external id aren't inherited.
+type AccountConfig struct {
+	Name       string `toml:"name" json:"name"`
+	AccessKey  string `toml:"access_key" json:"access_key"`
+	SecretKey  string `toml:"secret_key" json:"secret_key"`
+	Profile    string `toml:"profile" json:"profile"`
+	RoleARN    string `toml:"role_arn" json:"role_arn"`
+	ExternalID string `toml:"external_id" json:"external_id"`
+}
+
+// config returns the configuration of the account, all settings except the
+// credentials are the same for all accounts.
+func (c *AccountConfig) config(conf *AwsConfig) *AwsConfig {
+	accountConf := *conf
+	accountConf.Accounts = nil
+	accountConf.RoleARN = c.RoleARN
+	accountConf.ExternalID = c.ExternalID
+
+	if c.AccessKey != "" || c.SecretKey != "" || c.Profile != "" {
+		accountConf.AccessKey = c.AccessKey
+		accountConf.SecretKey = c.SecretKey
+		accountConf.Profile = c.Profile
+	}
+
+	return &accountConf
+}
+
+// multiAccount manages the images of multiple accounts. Without named accounts
+// it contains a single account with an empty name.
+type multiAccount struct {
+	names    []string // sorted
+	accounts map[string]*AwsImages
+}
+
+func newMultiAccount(conf *AwsConfig) (*multiAccount, error) {
+	if len(conf.Accounts) == 0 {
+		awsImages, err := New(conf)
+		if err != nil {
+			return nil, err
+		}
+
+		return &multiAccount{
+			names:    []string{""},
+			accounts: map[string]*AwsImages{"": awsImages},
+		}, nil
+	}
+
+	m := &multiAccount{
+		accounts: make(map[string]*AwsImages, len(conf.Accounts)),
+	}
+
+	for _, account := range conf.Accounts {
+		name := account.Name
+		if name == "" {
+			return nil, errors.New("AWS Account name is not set. Please check your configuration")
+		}
+
+		if strings.Contains(name, ":") {
+			return nil, fmt.Errorf("AWS Account name '%s' contains a colon. Please check your configuration", name)
+		}
+
+		if _, ok := m.accounts[name]; ok {
+			return nil, fmt.Errorf("AWS Account '%s' is defined multiple times. Please check your configuration", name)
+		}
+
+		awsImages, err := New(account.config(conf))
+		if err != nil {
+			return nil, fmt.Errorf("account %s: %s", name, err)
+		}
+		awsImages.account = name
+
+		m.accounts[name] = awsImages
+		m.names = append(m.names, name)
+	}
+
+	sort.Strings(m.names)
+	return m, nil
+}
+
+// qualifiedID returns the id of an image or snapshot of the given account in
+// the form of "account:id". Without named accounts it's the plain id.
+func qualifiedID(account, id string) string {
+	if account == "" {
+		return id
+	}
+	return account + ":" + id
+}
+
+// splitIDs groups the given ids by their account. With named accounts each id
+// has to be qualified with its account, i.e: "prod:ami-123".
+func (m *multiAccount) splitIDs(ids []string) (map[string][]string, error) {
+	if _, ok := m.accounts[""]; ok {
+		return map[string][]string{"": ids}, nil
+	}
+
+	accounts := make(map[string][]string)
+	for _, id := range ids {
+		i := strings.Index(id, ":")
+		if i == -1 {
+			return nil, fmt.Errorf("'%s' has no account, use the form 'account:%s'", id, id)
+		}
+
+		account := id[:i]
+		if _, ok := m.accounts[account]; !ok {
+			return nil, fmt.Errorf("account '%s' of '%s' is not configured", account, id)
+		}
+
+		accounts[account] = append(accounts[account], id[i+1:])
+	}
+
+	return accounts, nil
+}
+
+// forEach calls fn concurrently for each of the given accounts. The errors of
+// named accounts are prefixed with the name of the account.
+func (m *multiAccount) forEach(names []string, fn func(a *AwsImages) error) error {
+	var (
+		wg          sync.WaitGroup
+		mu          sync.Mutex // protects multiErrors
+		multiErrors error
+	)
+
+	for _, name := range names {
+		a, ok := m.accounts[name]
+		if !ok {
+			return fmt.Errorf("account '%s' is not configured", name)
+		}
+
+		if name == "" {
+			// no need to prefix the error or to fan out, there is only a
+			// single account
+			return fn(a)
+		}
+
+		wg.Add(1)
+		go func(name string, a *AwsImages) {
+			defer wg.Done()
+
+			if err := fn(a); err != nil {
+				mu.Lock()
+				multiErrors = multierror.Append(multiErrors, fmt.Errorf("account %s: %s", name, err))
+				mu.Unlock()
+			}
+		}(name, a)
+	}
+
+	wg.Wait()
+	return multiErrors
+}
+
+// forEachID calls fn concurrently for each account of the given qualified ids
+// with the plain ids of the account.
+func (m *multiAccount) forEachID(ids []string, fn func(a *AwsImages, ids []string) error) error {
+	accounts, err := m.splitIDs(ids)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(accounts))
+	for name := range accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return m.forEach(names, func(a *AwsImages) error {
+		return fn(a, accounts[a.account])
+	})
+}
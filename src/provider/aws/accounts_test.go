@@ -0,0 +1,206 @@
+package aws
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+// newFakeAccounts returns an AwsCommand with the given named accounts, the
+// requests of each account are sent to its own fake API in us-east-1.
+func newFakeAccounts(fakes map[string]*fakeEC2) *AwsCommand {
+	m := &multiAccount{accounts: make(map[string]*AwsImages, len(fakes))}
+	for name, f := range fakes {
+		a := f.images("us-east-1")
+		a.account = name
+
+		m.accounts[name] = a
+		m.names = append(m.names, name)
+	}
+	sort.Strings(m.names)
+
+	return &AwsCommand{accounts: m}
+}
+
+func TestAccountConfig(t *testing.T) {
+	conf := &AwsConfig{
+		AccessKey:  "key",
+		SecretKey:  "secret",
+		RoleARN:    "arn:aws:iam::1:role/base",
+		ExternalID: "base",
+		Regions:    []string{"us-east-1"},
+		Accounts:   []*AccountConfig{{Name: "prod"}},
+	}
+
+	// without own credentials the credentials are inherited, but not the role
+	inherited := (&AccountConfig{Name: "prod"}).config(conf)
+	if inherited.AccessKey != "key" || inherited.SecretKey != "secret" {
+		t.Errorf("credentials are %q/%q, want the inherited key/secret", inherited.AccessKey, inherited.SecretKey)
+	}
+
+	if inherited.RoleARN != "" || inherited.ExternalID != "" || inherited.Accounts != nil {
+		t.Errorf("role %q, external id %q and accounts %v are inherited",
+			inherited.RoleARN, inherited.ExternalID, inherited.Accounts)
+	}
+
+	if len(inherited.Regions) != 1 || inherited.Regions[0] != "us-east-1" {
+		t.Errorf("regions are %v, want the inherited us-east-1", inherited.Regions)
+	}
+
+	// a profile replaces all inherited credentials
+	own := (&AccountConfig{Name: "dev", Profile: "dev", RoleARN: "arn:aws:iam::2:role/dev"}).config(conf)
+	if own.AccessKey != "" || own.SecretKey != "" || own.Profile != "dev" || own.RoleARN != "arn:aws:iam::2:role/dev" {
+		t.Errorf("account config is %+v, want only the profile and role of the account", own)
+	}
+}
+
+func TestNewMultiAccount(t *testing.T) {
+	tests := []struct {
+		names []string
+		err   string
+	}{
+		{names: []string{"prod", "dev"}},
+		{names: []string{"prod", ""}, err: "name is not set"},
+		{names: []string{"prod:eu"}, err: "contains a colon"},
+		{names: []string{"prod", "prod"}, err: "'prod' is defined multiple times"},
+	}
+
+	for _, test := range tests {
+		conf := &AwsConfig{
+			AccessKey: "key",
+			SecretKey: "secret",
+			Partition: "aws",
+			Regions:   []string{"us-east-1"},
+		}
+		for _, name := range test.names {
+			conf.Accounts = append(conf.Accounts, &AccountConfig{Name: name})
+		}
+
+		m, err := newMultiAccount(conf)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("accounts %q: error is %v, want %q", test.names, err, test.err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("accounts %q: %s", test.names, err)
+			continue
+		}
+
+		if want := []string{"dev", "prod"}; !reflect.DeepEqual(m.names, want) {
+			t.Errorf("accounts %q: names are %q, want %q", test.names, m.names, want)
+		}
+
+		for _, name := range m.names {
+			if m.accounts[name].account != name {
+				t.Errorf("account %s has the name %q", name, m.accounts[name].account)
+			}
+		}
+	}
+}
+
+func TestSplitIDs(t *testing.T) {
+	single := &multiAccount{names: []string{""}, accounts: map[string]*AwsImages{"": {}}}
+	ids, err := single.splitIDs([]string{"ami-1", "ami-2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := map[string][]string{"": {"ami-1", "ami-2"}}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("ids are %v, want %v", ids, want)
+	}
+
+	named := &multiAccount{
+		names:    []string{"dev", "prod"},
+		accounts: map[string]*AwsImages{"dev": {}, "prod": {}},
+	}
+
+	ids, err = named.splitIDs([]string{"prod:ami-1", "dev:ami-2", "prod:ami-3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := map[string][]string{"prod": {"ami-1", "ami-3"}, "dev": {"ami-2"}}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("ids are %v, want %v", ids, want)
+	}
+
+	for id, want := range map[string]string{
+		"ami-1":      "'ami-1' has no account",
+		"test:ami-1": "account 'test' of 'test:ami-1' is not configured",
+	} {
+		if _, err := named.splitIDs([]string{"prod:ami-2", id}); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: error is %v, want %q", id, err, want)
+		}
+	}
+}
+
+func TestFetchAccounts(t *testing.T) {
+	prod := newFakeEC2(t, func(r *ec2Request) (int, string) {
+		return 200, imagesResponse("ami-1", "available")
+	})
+	defer prod.Close()
+
+	dev := newFakeEC2(t, func(r *ec2Request) (int, string) {
+		return 400, ec2Error("AuthFailure")
+	})
+	defer dev.Close()
+
+	a := newFakeAccounts(map[string]*fakeEC2{"prod": prod, "dev": dev})
+
+	images, err := a.Fetch(context.Background(), nil)
+	if err == nil || !strings.Contains(err.Error(), "account dev: ") || !strings.Contains(err.Error(), "AuthFailure") {
+		t.Errorf("error is %v, want the AuthFailure of account dev", err)
+	}
+
+	if len(images) != 1 || images[0].ID != "ami-1" || images[0].Account != "prod" {
+		t.Fatalf("images are %v, want ami-1 of account prod", images)
+	}
+
+	if args := a.SelectArgs(images); !reflect.DeepEqual(args, []string{"-ids", "prod:ami-1"}) {
+		t.Errorf("select args are %q, want the qualified id", args)
+	}
+}
+
+func TestFetchAccountsIDs(t *testing.T) {
+	fakes := make(map[string]*fakeEC2)
+	for _, name := range []string{"prod", "dev", "test"} {
+		f := newFakeEC2(t, func(r *ec2Request) (int, string) {
+			return 200, imagesResponse(r.form.Get("ImageId.1"), "available")
+		})
+		defer f.Close()
+
+		fakes[name] = f
+	}
+
+	a := newFakeAccounts(fakes)
+
+	images, err := a.Fetch(context.Background(), []string{"-ids", "prod:ami-1,dev:ami-2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, image := range images {
+		got = append(got, image.Account+":"+image.ID)
+	}
+	sort.Strings(got)
+
+	if want := []string{"dev:ami-2", "prod:ami-1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("images are %q, want %q", got, want)
+	}
+
+	for name, want := range map[string]string{"prod": "ami-1", "dev": "ami-2"} {
+		if reqs := fakes[name].requests; len(reqs) != 1 || reqs[0].form.Get("ImageId.1") != want {
+			t.Errorf("account %s: requests are %v, want a single one for %s", name, reqs, want)
+		}
+	}
+
+	if reqs := fakes["test"].requests; len(reqs) != 0 {
+		t.Errorf("account test has %d requests, want none", len(reqs))
+	}
+}
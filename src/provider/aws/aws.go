@@ -2,11 +2,11 @@ package aws
 
 import (
 	"errors"
-	"net/http"
-	"time"
+	"fmt"
+
+	"provider/utils"
 
 	awsclient "github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/service/ec2"
 )
 
@@ -15,12 +15,54 @@ type AwsConfig struct {
 	RegionsExclude []string `toml:"regions_exclude" json:"regions_exclude"`
 	AccessKey      string   `toml:"access_key" json:"access_key"`
 	SecretKey      string   `toml:"secret_key" json:"secret_key"`
+
+	// Partition is the partition of the regions and credentials: "aws",
+	// "aws-us-gov" or "aws-cn" (default: "aws")
+	Partition string `toml:"partition" json:"partition" default:"aws"`
+
+	// Profile is the profile of the shared credentials file, which is used
+	// without an access and secret key (default: AWS_PROFILE or "default")
+	Profile string `toml:"profile" json:"profile"`
+
+	// RoleARN is the role which is assumed with the credentials (optional)
+	RoleARN string `toml:"role_arn" json:"role_arn"`
+
+	// ExternalID is passed when the role is assumed (optional)
+	ExternalID string `toml:"external_id" json:"external_id"`
+
+	// RequestTimeout is the timeout of a single request, i.e: "1m" (default:
+	// "30s"). The duration of a whole command is limited by the global
+	// timeout setting.
+	RequestTimeout string `toml:"request_timeout" json:"request_timeout"`
+
+	// Retries is the maximum number of retries of a failed or rate limited
+	// request (default: 5)
+	Retries int `toml:"retries" json:"retries" default:"5"`
+
+	// Parallelism is the maximum number of concurrent requests (default:
+	// the global parallelism)
+	Parallelism int `toml:"parallelism" json:"parallelism"`
+
+	// Accounts are named accounts with their own credentials. The images of
+	// all accounts are managed together (optional)
+	Accounts []*AccountConfig `toml:"accounts" json:"accounts"`
 }
 
 // AwsImages is responsible of managing AWS images (AMI's)
 type AwsImages struct {
+	// account is the name of the account, it's empty without named accounts
+	account string
+
 	services *multiRegion
+	retry    *utils.Retry
 	images   Images
+
+	// deprecations are the deprecation times of the fetched images
+	deprecations deprecationTimes
+
+	// parallelism is the parallelism setting of the provider, zero uses the
+	// global setting
+	parallelism int
 }
 
 func New(conf *AwsConfig) (*AwsImages, error) {
@@ -30,34 +72,64 @@ func New(conf *AwsConfig) (*AwsImages, error) {
 		return nil, errors.New("AWS Regions are not set. " + checkCfg)
 	}
 
-	if conf.AccessKey == "" {
-		return nil, errors.New("AWS Access Key is not set. " + checkCfg)
+	timeout, err := utils.ParseRequestTimeout(conf.RequestTimeout)
+	if err != nil {
+		return nil, err
 	}
 
-	if conf.SecretKey == "" {
-		return nil, errors.New("AWS Secret Key is not set. " + checkCfg)
+	retry, err := utils.NewRetry(conf.Retries)
+	if err != nil {
+		return nil, err
 	}
 
-	// increase the timeout
-	timeout := time.Second * 30
-	client := &http.Client{
-		Transport: &http.Transport{
-			Proxy:               http.ProxyFromEnvironment,
-			TLSHandshakeTimeout: timeout,
-		},
-		Timeout: timeout,
+	if err := utils.ValidateParallelism(conf.Parallelism); err != nil {
+		return nil, err
+	}
+
+	p, ok := partitions[conf.Partition]
+	if !ok {
+		return nil, fmt.Errorf("AWS Partition '%s' is not valid. %s", conf.Partition, checkCfg)
+	}
+
+	client := utils.NewHTTPClient(timeout)
+	creds, err := newCredentials(conf, client, p)
+	if err != nil {
+		return nil, err
 	}
 
-	creds := credentials.NewStaticCredentials(conf.AccessKey, conf.SecretKey, "")
 	awsCfg := &awsclient.Config{
 		Credentials: creds,
 		HTTPClient:  client,
 		Logger:      awsclient.NewDefaultLogger(),
 	}
 
-	m := newMultiRegion(awsCfg, filterRegions(conf.Regions, conf.RegionsExclude))
+	m := newMultiRegion(awsCfg, retry, p)
+
+	regions := conf.Regions
+	if isAllRegions(regions) {
+		regions, err = m.discoverRegions(conf.Partition, p,
+			conf.AccessKey, conf.Profile, conf.RoleARN)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	regions = filterRegions(regions, conf.RegionsExclude)
+	if len(regions) == 0 {
+		return nil, errors.New("AWS Regions are all excluded. " + checkCfg)
+	}
+	m.setRegions(regions)
+
 	return &AwsImages{
-		services: m,
-		images:   make(map[string][]*ec2.Image),
+		services:    m,
+		retry:       retry,
+		images:      make(map[string][]*ec2.Image),
+		parallelism: conf.Parallelism,
 	}, nil
 }
+
+// Retries returns the number of retried requests and how many of them were
+// rate limited.
+func (a *AwsImages) Retries() (total, throttled int) {
+	return a.retry.Retries()
+}
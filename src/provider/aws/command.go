@@ -2,16 +2,33 @@ package aws
 
 import (
 	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"command/loader"
+	"provider"
+	"provider/utils"
 
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"golang.org/x/net/context"
 )
 
 // AwsCommand implements the images various interfaces, such as Fetcher,
-// Deleter, Modifier, etc..
+// Deleter, Modifier, etc.. Each command is called for all configured
+// accounts.
 type AwsCommand struct {
-	*AwsImages
+	accounts *multiAccount
+}
+
+func init() {
+	provider.Register(&provider.Registration{
+		Name:         "aws",
+		New:          newProvider,
+		Capabilities: provider.CanList | provider.CanDelete | provider.CanModify | provider.CanCopy | provider.CanWait | provider.CanSnapshots,
+		Help:         Help,
+	})
 }
 
 // NewCommand returns a new instance of AwsCommand
@@ -26,7 +43,7 @@ func NewCommand(args []string) (*AwsCommand, []string, error) {
 		return nil, nil, err
 	}
 
-	awsImages, err := New(&conf.Aws)
+	accounts, err := newMultiAccount(&conf.Aws)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -34,36 +51,131 @@ func NewCommand(args []string) (*AwsCommand, []string, error) {
 	remainingArgs := loader.ExcludeArgs(&conf, args)
 
 	return &AwsCommand{
-		AwsImages: awsImages,
+		accounts: accounts,
 	}, remainingArgs, nil
 }
 
-// List implements the command.Lister interface
-func (a *AwsCommand) List(args []string) error {
+// newProvider implements the provider.Factory func type
+func newProvider(args []string) (provider.Provider, []string, error) {
+	cmd, remainingArgs, err := NewCommand(args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cmd, remainingArgs, nil
+}
+
+// Name implements the provider.Provider interface
+func (a *AwsCommand) Name() string { return "aws" }
+
+// Fetch implements the provider.Provider interface
+func (a *AwsCommand) Fetch(ctx context.Context, args []string) (provider.Images, error) {
 	l := newListFlags()
 	if err := l.flagSet.Parse(args); err != nil {
-		return nil // we don't return error, the usage will be printed instead
+		return nil, err
+	}
+
+	var (
+		mu     sync.Mutex // protects images
+		images provider.Images
+	)
+
+	fetch := func(a *AwsImages, ids []string) error {
+		accountFlags := *l
+		accountFlags.imageIds = ids
+
+		accountImages, err := a.listImages(ctx, &accountFlags)
+		if err != nil {
+			return err
+		}
+
+		normalized := accountImages.Normalize()
+		a.deprecations.set(normalized)
+		for _, image := range normalized {
+			image.Account = a.account
+		}
+
+		mu.Lock()
+		images = append(images, normalized...)
+		mu.Unlock()
+		return nil
 	}
 
-	input := &ec2.DescribeImagesInput{}
+	var err error
+	if len(l.imageIds) != 0 {
+		err = a.accounts.forEachID(l.imageIds, fetch)
+	} else {
+		err = a.accounts.forEach(a.accounts.names, func(a *AwsImages) error {
+			return fetch(a, nil)
+		})
+	}
+
+	return images, err
+}
+
+// SelectArgs implements the command.Selector interface
+func (a *AwsCommand) SelectArgs(images provider.Images) []string {
+	ids := make([]string, len(images))
+	for i, image := range images {
+		ids[i] = qualifiedID(image.Account, image.ID)
+	}
+
+	return []string{"-ids", strings.Join(ids, ",")}
+}
+
+// SplitListArgs implements the command.ListArgsSplitter interface. The owners,
+// executable users and filters narrow down the images to select from.
+func (a *AwsCommand) SplitListArgs(args []string) (list, rest []string) {
+	return utils.SplitFlags(newListFlags().flagSet, args, "owners", "executable-users", "filters")
+}
+
+// Describe implements the command.Describer interface
+func (a *AwsCommand) Describe(ctx context.Context, image *provider.Image) (*provider.Details, error) {
+	account, ok := a.accounts.accounts[image.Account]
+	if !ok {
+		return nil, fmt.Errorf("account '%s' of image '%s' is not configured", image.Account, image.ID)
+	}
 
-	if len(l.owners) == 0 {
+	return account.Describe(ctx, image)
+}
+
+// Retries returns the number of retried requests of all accounts and how
+// many of them were rate limited.
+func (a *AwsCommand) Retries() (total, throttled int) {
+	for _, account := range a.accounts.accounts {
+		t, th := account.Retries()
+		total += t
+		throttled += th
+	}
+	return total, throttled
+}
+
+// listImages returns the images matching the given list flags
+func (a *AwsImages) listImages(ctx context.Context, l *listFlags) (Images, error) {
+	input := &ec2.DescribeImagesInput{
+		Filters: l.filters,
+	}
+
+	// only the own images are listed by default, shared images are listed
+	// with the executable users
+	if len(l.owners) != 0 {
+		input.Owners = stringSlice(l.owners...)
+	} else if len(l.executableUsers) == 0 {
 		input.Owners = stringSlice("self")
 	}
 
-	if len(l.imageIds) != 0 {
-		input.ImageIds = stringSlice(l.imageIds...)
+	if len(l.executableUsers) != 0 {
+		input.ExecutableUsers = stringSlice(l.executableUsers...)
 	}
 
-	images, err := a.Images(input)
-	if err != nil {
-		return err
+	if len(l.imageIds) != 0 {
+		input.ImageIds = stringSlice(l.imageIds...)
 	}
 
-	return images.Print(l.output)
+	return a.Images(ctx, input)
 }
 
-func (a *AwsCommand) Copy(args []string) error {
+func (a *AwsCommand) Copy(ctx context.Context, args []string) error {
 	c := newCopyOptions()
 	if err := c.flagSet.Parse(args); err != nil {
 		return nil // we don't return error, the usage will be printed instead
@@ -78,10 +190,19 @@ func (a *AwsCommand) Copy(args []string) error {
 		return errors.New("no image is passed. Use --image")
 	}
 
-	return a.CopyImages(c)
+	if err := c.parseKmsKeys(); err != nil {
+		return err
+	}
+
+	// an image belongs to a single account, so it's copied within it
+	return a.accounts.forEachID([]string{c.ImageID}, func(a *AwsImages, ids []string) error {
+		accountOpts := *c
+		accountOpts.ImageID = ids[0]
+		return a.CopyImages(ctx, &accountOpts)
+	})
 }
 
-func (a *AwsCommand) Delete(args []string) error {
+func (a *AwsCommand) Delete(ctx context.Context, args []string) error {
 	d := newDeleteOptions()
 	if err := d.flagSet.Parse(args); err != nil {
 		return nil // we don't return error, the usage will be printed instead
@@ -96,12 +217,105 @@ func (a *AwsCommand) Delete(args []string) error {
 		return errors.New("no images are passed with [--ids]")
 	}
 
-	return a.DeleteImages(d)
+	// the results of all accounts are printed together
+	var report *deleteReport
+	if d.DeleteSnapshots {
+		report = &deleteReport{}
+		defer report.print()
+	}
+
+	return a.accounts.forEachID(d.ImageIds, func(a *AwsImages, ids []string) error {
+		accountOpts := *d
+		accountOpts.ImageIds = ids
+		return a.deleteImages(ctx, &accountOpts, report)
+	})
 }
 
-// Modify manages the tags of the given images. It can create, override or
-// delete tags associated with the given AMI ids.
-func (a *AwsCommand) Modify(args []string) error {
+// Wait waits until the given images reach a state.
+func (a *AwsCommand) Wait(ctx context.Context, args []string) error {
+	w := newWaitFlags()
+	if err := w.flagSet.Parse(args); err != nil {
+		return nil // we don't return error, the usage will be printed instead
+	}
+
+	if len(args) == 0 {
+		w.flagSet.Usage()
+		return nil
+	}
+
+	if len(w.imageIds) == 0 {
+		return errors.New("no images are passed with [--ids]")
+	}
+
+	accounts, err := a.accounts.splitIDs(w.imageIds)
+	if err != nil {
+		return err
+	}
+
+	// the accounts are waited for one after another, so their progress
+	// isn't mixed up
+	for _, name := range a.accounts.names {
+		ids, ok := accounts[name]
+		if !ok {
+			continue
+		}
+
+		if err := a.accounts.forEach([]string{name}, func(a *AwsImages) error {
+			return a.WaitImages(ctx, w.state, ids...)
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// OrphanedSnapshots returns the EBS snapshots of deregistered AMIs.
+func (a *AwsCommand) OrphanedSnapshots(ctx context.Context, args []string) (provider.Snapshots, error) {
+	var (
+		mu        sync.Mutex // protects snapshots
+		snapshots provider.Snapshots
+	)
+
+	err := a.accounts.forEach(a.accounts.names, func(a *AwsImages) error {
+		orphans, err := a.Orphans(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, snapshot := range orphans {
+			snapshot.Account = a.account
+		}
+
+		mu.Lock()
+		snapshots = append(snapshots, orphans...)
+		mu.Unlock()
+		return nil
+	})
+
+	return snapshots, err
+}
+
+// DeleteSnapshots deletes the given EBS snapshots of all accounts.
+func (a *AwsCommand) DeleteSnapshots(ctx context.Context, snapshots provider.Snapshots) error {
+	accounts := make(map[string]provider.Snapshots)
+	names := make([]string, 0)
+	for _, snapshot := range snapshots {
+		if _, ok := accounts[snapshot.Account]; !ok {
+			names = append(names, snapshot.Account)
+		}
+		accounts[snapshot.Account] = append(accounts[snapshot.Account], snapshot)
+	}
+
+	return a.accounts.forEach(names, func(a *AwsImages) error {
+		return a.DeleteSnapshots(ctx, accounts[a.account])
+	})
+}
+
+// Modify manages the tags, launch permissions, description and deprecation of
+// the given images. It can create, override or delete tags, share or unshare
+// and deprecate the given AMI ids.
+func (a *AwsCommand) Modify(ctx context.Context, args []string) error {
 	m := newModifyFlags()
 	if err := m.flagSet.Parse(args); err != nil {
 		return nil // we don't return error, the usage will be printed instead
@@ -120,27 +334,119 @@ func (a *AwsCommand) Modify(args []string) error {
 		return errors.New("not allowed to be used together: [--create-tags,--delete-tags]")
 	}
 
+	if m.makePublic && m.makePrivate {
+		return errors.New("not allowed to be used together: [--make-public,--make-private]")
+	}
+
+	if m.deprecateAt != "" && m.undeprecate {
+		return errors.New("not allowed to be used together: [--deprecate-at,--undeprecate]")
+	}
+
+	var deprecateAt time.Time
+	if m.deprecateAt != "" {
+		var err error
+		deprecateAt, err = utils.ParseTime(m.deprecateAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	add, err := parseLaunchPermissions(m.shareWith)
+	if err != nil {
+		return err
+	}
+
+	remove, err := parseLaunchPermissions(m.unshare)
+	if err != nil {
+		return err
+	}
+
+	if m.makePublic {
+		add = append(add, publicPermission)
+	}
+
+	if m.makePrivate {
+		remove = append(remove, publicPermission)
+	}
+
 	if m.createTags != "" {
-		return a.CreateTags(m.createTags, m.dryRun, m.imageIds...)
+		if err := a.accounts.forEachID(m.imageIds, func(a *AwsImages, ids []string) error {
+			return a.CreateTags(ctx, m.createTags, m.dryRun, ids...)
+		}); err != nil {
+			return err
+		}
 	}
 
 	if m.deleteTags != "" {
-		return a.DeleteTags(m.deleteTags, m.dryRun, m.imageIds...)
+		if err := a.accounts.forEachID(m.imageIds, func(a *AwsImages, ids []string) error {
+			return a.DeleteTags(ctx, m.deleteTags, m.dryRun, ids...)
+		}); err != nil {
+			return err
+		}
+	}
+
+	if len(add) != 0 || len(remove) != 0 {
+		if err := a.accounts.forEachID(m.imageIds, func(a *AwsImages, ids []string) error {
+			return a.ModifyLaunchPermissions(ctx, add, remove, m.dryRun, ids...)
+		}); err != nil {
+			return err
+		}
+	}
+
+	if m.description != "" {
+		if err := a.accounts.forEachID(m.imageIds, func(a *AwsImages, ids []string) error {
+			return a.SetDescription(ctx, m.description, m.dryRun, ids...)
+		}); err != nil {
+			return err
+		}
+	}
+
+	if m.deprecateAt != "" {
+		return a.accounts.forEachID(m.imageIds, func(a *AwsImages, ids []string) error {
+			return a.Deprecate(ctx, deprecateAt, m.dryRun, ids...)
+		})
+	}
+
+	if m.undeprecate {
+		return a.accounts.forEachID(m.imageIds, func(a *AwsImages, ids []string) error {
+			return a.Undeprecate(ctx, m.dryRun, ids...)
+		})
 	}
 
 	return nil
 }
 
-// Help prints the help message for the given command
-func (a *AwsCommand) Help(command string) string {
+// Help returns the help message for the given command
+func Help(command string) string {
 	var help string
 
 	global := `
   -access-key      "..."       AWS Access Key (env: IMAGES_AWS_ACCESS_KEY)
   -secret-key      "..."       AWS Secret Key (env: IMAGES_AWS_SECRET_KEY)
+  -profile         "..."       AWS Shared Credentials Profile, used without an access
+                               and secret key (default: AWS_PROFILE or "default",
+                               env: IMAGES_AWS_PROFILE)
+  -role-arn        "..."       AWS Role to assume with the credentials (default: none,
+                               env: IMAGES_AWS_ROLE_ARN)
+  -external-id     "..."       AWS External ID of the Role (default: none,
+                               env: IMAGES_AWS_EXTERNAL_ID)
   -regions         "..."       AWS Regions (env: IMAGES_AWS_REGION)
   -regions-exclude "..."       AWS Regions to be excluded (env: IMAGES_AWS_REGION_EXCLUDE)
+  -partition       "..."       AWS Partition: "aws", "aws-us-gov" or "aws-cn"
+                               (default: "aws", env: IMAGES_AWS_PARTITION)
+  -request-timeout "30s"       Timeout of a single request (default: "30s",
+                               env: IMAGES_AWS_REQUEST_TIMEOUT). The global -timeout
+                               limits the whole command
+  -retries         5           Maximum retries of a failed or rate limited request
+                               (default: 5, env: IMAGES_AWS_RETRIES)
+  -parallelism     4           Maximum concurrent requests (default: the global
+                               -parallelism, env: IMAGES_AWS_PARALLELISM)
+
+  Named accounts are configured with [[aws.accounts]] sections in the config
+  file, each with a name and optionally access_key, secret_key, profile,
+  role_arn and external_id. The images of all accounts are managed together.
 `
+
 	switch command {
 	case "modify":
 		help = newModifyFlags().helpMsg
@@ -150,6 +456,8 @@ func (a *AwsCommand) Help(command string) string {
 		help = newListFlags().helpMsg
 	case "copy":
 		help = newCopyOptions().helpMsg
+	case "wait":
+		help = newWaitFlags().helpMsg
 	default:
 		return "no help found for command " + command
 	}
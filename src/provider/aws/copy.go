@@ -1,17 +1,23 @@
 package aws
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"os"
+	"strings"
 	"sync"
+	"time"
+
+	"provider/utils"
 
 	awsclient "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/fatih/flags"
 	"github.com/hashicorp/go-multierror"
+	"golang.org/x/net/context"
 )
 
 type CopyOptions struct {
@@ -28,6 +34,31 @@ type CopyOptions struct {
 	// DryRun doesn't run the command, but shows the action
 	DryRun bool
 
+	// CopyTags copies the tags of the image to the new AMIs
+	CopyTags bool
+
+	// Tags are added to the new AMIs, in the form of "key1=val1,key2=val2".
+	// They override the copied tags (optional)
+	Tags string
+
+	// CopyPermissions applies the launch permissions of the image to the new
+	// AMIs once they are available
+	CopyPermissions bool
+
+	// Encrypted encrypts the snapshots of the new AMIs
+	Encrypted bool
+
+	// KmsKeys defines the KMS key used for the encryption of each region. The
+	// key of the empty region is used for all other regions. Without a key
+	// the default key of EBS is used.
+	KmsKeys map[string]string
+
+	// Wait waits until the new AMIs are available, at most WaitTimeout
+	Wait        bool
+	WaitTimeout time.Duration
+
+	kmsKeys []string
+
 	helpMsg string
 	flagSet *flag.FlagSet
 }
@@ -39,6 +70,13 @@ func newCopyOptions() *CopyOptions {
 	flagSet.StringVar(&c.ImageID, "image", "", "Image to be copied with the given id")
 	flagSet.StringVar(&c.Desc, "desc", "", "Description for the new AMI (optional)")
 	flagSet.BoolVar(&c.DryRun, "dry-run", false, "Don't run command, but show the action")
+	flagSet.BoolVar(&c.CopyTags, "copy-tags", false, "Copy the tags of the image")
+	flagSet.StringVar(&c.Tags, "tags", "", "Tags to be added to the new AMIs")
+	flagSet.BoolVar(&c.CopyPermissions, "copy-permissions", false, "Copy the launch permissions of the image")
+	flagSet.BoolVar(&c.Encrypted, "encrypted", false, "Encrypt the snapshots of the new AMIs")
+	flagSet.Var(flags.NewStringSlice(nil, &c.kmsKeys), "kms-key", "KMS keys used for the encryption")
+	flagSet.BoolVar(&c.Wait, "wait", false, "Wait until the new AMIs are available")
+	flagSet.Var(utils.NewDurationValue(time.Hour, &c.WaitTimeout), "wait-timeout", "Maximum duration of waiting")
 	flagSet.Var(flags.NewStringSlice(nil, &c.SourceRegions), "to", "Images to be copied to the given regions")
 
 	c.helpMsg = `Usage: images copy --providers aws [options]
@@ -51,10 +89,20 @@ Options:
   -to      "us-east-1,..."     Image to be copied to the given regions 
   -desc    "My New Image"      Description for the new AMI's (optional)
   -dry-run                     Don't run command, but show the action
+  -copy-tags                   Copy the tags of the image to the new AMI's
+  -tags    "key=val,..."       Tags to be added to the new AMI's (optional)
+  -copy-permissions            Copy the launch permissions of the image to the
+                               new AMI's once they are available
+  -encrypted                   Encrypt the snapshots of the new AMI's
+  -kms-key "region=key,..."    KMS key (id, alias or ARN) for each region used
+                               for the encryption. A key without a region is
+                               used for all other regions. (default: EBS key)
+  -wait                        Wait until the new AMI's are available
+  -wait-timeout "1h"           Maximum duration of waiting (default: "1h")
 `
 
 	flagSet.Usage = func() {
-		fmt.Fprintf(os.Stderr, c.helpMsg)
+		fmt.Fprint(os.Stderr, c.helpMsg)
 	}
 
 	flagSet.SetOutput(ioutil.Discard) // don't print anything without my permission
@@ -62,16 +110,59 @@ Options:
 	return c
 }
 
-// Copy transfers the images to other regions
-func (a *AwsImages) CopyImages(opts *CopyOptions) error {
-	var (
-		wg sync.WaitGroup
-		mu sync.Mutex
+// parseKmsKeys parses the -kms-key flag values, which are in the form of
+// "region=key" or just "key" for all regions.
+func (c *CopyOptions) parseKmsKeys() error {
+	if len(c.kmsKeys) == 0 {
+		return nil
+	}
+
+	if !c.Encrypted {
+		return errors.New("-kms-key can be only used with -encrypted")
+	}
+
+	c.KmsKeys = make(map[string]string, len(c.kmsKeys))
+	for _, val := range c.kmsKeys {
+		region, key := "", val
+		if i := strings.Index(val, "="); i != -1 {
+			region, key = val[:i], val[i+1:]
+		}
+
+		if key == "" {
+			return fmt.Errorf("no KMS key for region '%s'", region)
+		}
+
+		if _, ok := c.KmsKeys[region]; ok {
+			return fmt.Errorf("multiple KMS keys for region '%s'", region)
+		}
+
+		if region != "" && !contains(c.SourceRegions, region) {
+			return fmt.Errorf("KMS key for region '%s', which isn't passed with -to", region)
+		}
+
+		c.KmsKeys[region] = key
+	}
 
+	return nil
+}
+
+// kmsKey returns the KMS key for the given region
+func (c *CopyOptions) kmsKey(region string) string {
+	if key, ok := c.KmsKeys[region]; ok {
+		return key
+	}
+	return c.KmsKeys[""]
+}
+
+// Copy transfers the images to other regions. It prints the new AMI of each
+// region and waits until they are available if opts.Wait is set.
+func (a *AwsImages) CopyImages(ctx context.Context, opts *CopyOptions) error {
+	var (
+		mu          sync.Mutex // protects multiErrors
 		multiErrors error
 	)
 
-	images, err := a.matchImages(opts.ImageID)
+	images, err := a.matchImages(ctx, opts.ImageID)
 	if err != nil {
 		return err
 	}
@@ -86,44 +177,208 @@ func (a *AwsImages) CopyImages(opts *CopyOptions) error {
 		return err
 	}
 
-	resp, err := svc.DescribeImages(&ec2.DescribeImagesInput{
+	req, resp := svc.DescribeImagesRequest(&ec2.DescribeImagesInput{
 		Owners:   stringSlice("self"),
 		ImageIds: stringSlice(opts.ImageID),
 	})
-	if err != nil {
+	if err := send(ctx, req); err != nil {
 		return err
 	}
 
 	image := resp.Images[0]
 
 	if opts.Desc == "" {
-		opts.Desc = *image.Description
-	}
-
-	for _, r := range opts.SourceRegions {
-		wg.Add(1)
-		go func(region string) {
-			imageDesc := fmt.Sprintf("[Copied %s from %s via images] %s", opts.ImageID, region, opts.Desc)
-			log.Println("copying image ...")
-			input := &ec2.CopyImageInput{
-				SourceImageId: awsclient.String(opts.ImageID),
-				SourceRegion:  awsclient.String(region),
-				Description:   awsclient.String(imageDesc),
-				Name:          image.Name,
-				DryRun:        awsclient.Bool(opts.DryRun),
-			}
+		opts.Desc = awsclient.StringValue(image.Description)
+	}
+
+	attrs := &copyAttributes{}
+	if opts.CopyTags {
+		attrs.addTags(image.Tags)
+	}
+
+	if opts.Tags != "" {
+		attrs.addTags(populateEC2Tags(opts.Tags, true))
+	}
+
+	if opts.CopyPermissions {
+		attrs.perms, err = describeLaunchPermissions(ctx, svc, opts.ImageID)
+		if err != nil {
+			return err
+		}
+	}
+
+	progress := utils.NewProgress(os.Stdout, opts.SourceRegions...)
+	tracker := utils.NewTracker(opts.SourceRegions...)
+
+	utils.ForEach(ctx, utils.Parallelism(ctx, a.parallelism), len(opts.SourceRegions), func(i int) {
+		region := opts.SourceRegions[i]
+		progress.Set(region, "copying")
+
+		err := a.copyImage(ctx, progress, opts, image, attrs, imageRegion, region)
+		if err != nil {
+			progress.Set(region, "failed")
 
-			_, err := svc.CopyImage(input)
-			if err != nil {
-				mu.Lock()
-				multiErrors = multierror.Append(multiErrors, err)
-				mu.Unlock()
+			mu.Lock()
+			multiErrors = multierror.Append(multiErrors, fmt.Errorf("%s: %s", region, err))
+			mu.Unlock()
+			return
+		}
+
+		tracker.Done(region)
+	})
+
+	return tracker.Err(ctx, multiErrors)
+}
+
+// copyImageInput is ec2.CopyImageInput with the encryption parameters, which
+// aren't supported by the vendored SDK.
+type copyImageInput struct {
+	Description   *string `type:"string"`
+	DryRun        *bool   `locationName:"dryRun" type:"boolean"`
+	Encrypted     *bool   `locationName:"encrypted" type:"boolean"`
+	KmsKeyId      *string `locationName:"kmsKeyId" type:"string"`
+	Name          *string `type:"string" required:"true"`
+	SourceImageId *string `type:"string" required:"true"`
+	SourceRegion  *string `type:"string" required:"true"`
+}
+
+// copyAttributes are the attributes of the image, which aren't copied by EC2
+// and are applied to each new AMI instead.
+type copyAttributes struct {
+	tags  []*ec2.Tag
+	perms []*launchPermission
+}
+
+// addTags adds the given tags, overriding existing tags with the same key.
+// Tags with the "aws:" prefix are reserved and skipped.
+func (c *copyAttributes) addTags(tags []*ec2.Tag) {
+	for _, tag := range tags {
+		key := awsclient.StringValue(tag.Key)
+		if strings.HasPrefix(key, "aws:") {
+			continue
+		}
+
+		replaced := false
+		for i, t := range c.tags {
+			if awsclient.StringValue(t.Key) == key {
+				c.tags[i] = tag
+				replaced = true
 			}
+		}
+
+		if !replaced {
+			c.tags = append(c.tags, tag)
+		}
+	}
+}
+
+// copyImage copies the given image from the source region to the destination
+// region. The copy is requested in the destination region. The tags are
+// applied as soon as the new AMI exists, the launch permissions once it's
+// available.
+func (a *AwsImages) copyImage(ctx context.Context, progress *utils.Progress, opts *CopyOptions, image *ec2.Image, attrs *copyAttributes, src, dst string) error {
+	svc, err := a.svcFromRegion(dst)
+	if err != nil {
+		// the destination doesn't need to be one of the configured regions
+		svc = a.services.newSvc(dst)
+	}
+
+	imageDesc := fmt.Sprintf("[Copied %s from %s via images] %s", opts.ImageID, src, opts.Desc)
+	input := &copyImageInput{
+		SourceImageId: awsclient.String(opts.ImageID),
+		SourceRegion:  awsclient.String(src),
+		Description:   awsclient.String(imageDesc),
+		Name:          image.Name,
+		DryRun:        awsclient.Bool(opts.DryRun),
+	}
 
-			wg.Done()
-		}(r)
+	if opts.Encrypted {
+		input.Encrypted = awsclient.Bool(true)
+		if key := opts.kmsKey(dst); key != "" {
+			input.KmsKeyId = awsclient.String(key)
+		}
 	}
 
-	wg.Wait()
-	return multiErrors
+	resp := &ec2.CopyImageOutput{}
+	req := newRequest(svc, "CopyImage", input, resp)
+	if err := send(ctx, req); err != nil {
+		if isDryRun(err) {
+			progress.Set(dst, "%s", result("copied", true))
+			return nil
+		}
+		return err
+	}
+
+	id := awsclient.StringValue(resp.ImageId)
+	progress.Set(dst, "%s  pending", id)
+
+	tagged := len(attrs.tags) == 0
+	waitAvailable := opts.Wait || len(attrs.perms) != 0
+	if tagged && !waitAvailable {
+		return nil
+	}
+
+	return utils.Poll(ctx, opts.WaitTimeout, func() (bool, error) {
+		req, resp := svc.DescribeImagesRequest(&ec2.DescribeImagesInput{
+			ImageIds: stringSlice(id),
+		})
+		if err := send(ctx, req); err != nil {
+			// a new AMI might not be visible immediately
+			if isImageNotFound(err) {
+				return false, nil
+			}
+			return false, err
+		}
+
+		if len(resp.Images) == 0 {
+			return false, nil
+		}
+
+		if !tagged {
+			req, _ := svc.CreateTagsRequest(&ec2.CreateTagsInput{
+				Resources: stringSlice(id),
+				Tags:      attrs.tags,
+			})
+			if err := send(ctx, req); err != nil {
+				// a new AMI might not be taggable immediately
+				if isImageNotFound(err) {
+					return false, nil
+				}
+				return false, fmt.Errorf("tagging %s: %s", id, err)
+			}
+			tagged = true
+		}
+
+		newImage := resp.Images[0]
+		state := awsclient.StringValue(newImage.State)
+		progress.Set(dst, "%s  %s", id, state)
+
+		if !waitAvailable {
+			return true, nil
+		}
+
+		switch state {
+		case ec2.ImageStateAvailable:
+			if len(attrs.perms) != 0 {
+				if err := modifyLaunchPermissions(ctx, svc, id, attrs.perms, nil, false); err != nil {
+					return false, fmt.Errorf("applying launch permissions to %s: %s", id, err)
+				}
+			}
+			return true, nil
+		case ec2.ImageStatePending:
+			return false, nil
+		}
+
+		if newImage.StateReason != nil {
+			return false, fmt.Errorf("%s is %s: %s", id, state, awsclient.StringValue(newImage.StateReason.Message))
+		}
+		return false, fmt.Errorf("%s is %s", id, state)
+	})
+}
+
+// isImageNotFound returns true if the error is returned for an unknown AMI,
+// i.e. for a new AMI which isn't visible yet.
+func isImageNotFound(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	return ok && awsErr.Code() == "InvalidAMIID.NotFound"
 }
@@ -0,0 +1,336 @@
+package aws
+
+import (
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"provider/utils"
+
+	awsclient "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"golang.org/x/net/context"
+)
+
+const copyImageResponse = `<CopyImageResponse><imageId>ami-new</imageId></CopyImageResponse>`
+
+// discardProgress returns a progress of the given regions, which isn't shown
+func discardProgress(t *testing.T, regions ...string) *utils.Progress {
+	f, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+
+	return utils.NewProgress(f, regions...)
+}
+
+// copyToEU copies ami-1 from us-east-1 to eu-west-1 with the given options
+func copyToEU(t *testing.T, f *fakeEC2, opts *CopyOptions, attrs *copyAttributes) error {
+	opts.ImageID = "ami-1"
+	if opts.WaitTimeout == 0 {
+		opts.WaitTimeout = time.Minute
+	}
+
+	image := &ec2.Image{ImageId: awsclient.String("ami-1"), Name: awsclient.String("base")}
+	a := f.images("us-east-1", "eu-west-1")
+
+	return a.copyImage(context.Background(), discardProgress(t, "eu-west-1"), opts, image, attrs, "us-east-1", "eu-west-1")
+}
+
+func TestCopyImage(t *testing.T) {
+	f := newFakeEC2(t, func(r *ec2Request) (int, string) {
+		if r.action != "CopyImage" {
+			t.Errorf("unexpected action %s", r.action)
+			return 400, ec2Error("InvalidAction")
+		}
+		return 200, copyImageResponse
+	})
+	defer f.Close()
+
+	if err := copyToEU(t, f, &CopyOptions{Desc: "my image"}, &copyAttributes{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// without waiting and attributes the new AMI isn't polled
+	if len(f.requests) != 1 {
+		t.Fatalf("requests are %q, want only CopyImage", f.actions())
+	}
+
+	r := f.requests[0]
+	if r.region != "eu-west-1" {
+		t.Errorf("copy is requested in %q, want the destination eu-west-1", r.region)
+	}
+
+	for key, want := range map[string]string{
+		"SourceImageId": "ami-1",
+		"SourceRegion":  "us-east-1",
+		"Name":          "base",
+		"Description":   "[Copied ami-1 from us-east-1 via images] my image",
+	} {
+		if got := r.form.Get(key); got != want {
+			t.Errorf("%s is %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestCopyImageWait(t *testing.T) {
+	var described int32
+	f := newFakeEC2(t, func(r *ec2Request) (int, string) {
+		switch r.action {
+		case "CopyImage":
+			return 200, copyImageResponse
+		case "DescribeImages":
+			if r.form.Get("ImageId.1") != "ami-new" {
+				t.Errorf("described image is %q, want ami-new", r.form.Get("ImageId.1"))
+			}
+
+			// the new AMI isn't visible for the first request
+			if atomic.AddInt32(&described, 1) == 1 {
+				return 400, ec2Error("InvalidAMIID.NotFound")
+			}
+			return 200, imagesResponse("ami-new", "available")
+		}
+
+		t.Errorf("unexpected action %s", r.action)
+		return 400, ec2Error("InvalidAction")
+	})
+	defer f.Close()
+
+	if err := copyToEU(t, f, &CopyOptions{Wait: true}, &copyAttributes{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if described := atomic.LoadInt32(&described); described != 2 {
+		t.Errorf("new AMI is described %d times, want 2", described)
+	}
+}
+
+func TestCopyImageWaitFailed(t *testing.T) {
+	f := newFakeEC2(t, func(r *ec2Request) (int, string) {
+		switch r.action {
+		case "CopyImage":
+			return 200, copyImageResponse
+		case "DescribeImages":
+			return 200, `<DescribeImagesResponse><imagesSet><item><imageId>ami-new</imageId>` +
+				`<imageState>failed</imageState><stateReason><message>snapshot is missing</message>` +
+				`</stateReason></item></imagesSet></DescribeImagesResponse>`
+		}
+
+		t.Errorf("unexpected action %s", r.action)
+		return 400, ec2Error("InvalidAction")
+	})
+	defer f.Close()
+
+	err := copyToEU(t, f, &CopyOptions{Wait: true}, &copyAttributes{})
+	if want := "ami-new is failed: snapshot is missing"; err == nil || err.Error() != want {
+		t.Errorf("error is %v, want %q", err, want)
+	}
+}
+
+func TestCopyImageWaitTimeout(t *testing.T) {
+	f := newFakeEC2(t, func(r *ec2Request) (int, string) {
+		if r.action == "CopyImage" {
+			return 200, copyImageResponse
+		}
+		return 200, imagesResponse("ami-new", "pending")
+	})
+	defer f.Close()
+
+	err := copyToEU(t, f, &CopyOptions{Wait: true, WaitTimeout: 10 * time.Millisecond}, &copyAttributes{})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("error is %v, want a timeout", err)
+	}
+}
+
+func TestCopyAttributesAddTags(t *testing.T) {
+	attrs := &copyAttributes{}
+	attrs.addTags(populateEC2Tags("Name=base,aws:cloudformation:stack-name=stack,env=prod", true))
+	attrs.addTags(populateEC2Tags("env=dev,team=infra", true))
+
+	var got []string
+	for _, tag := range attrs.tags {
+		got = append(got, awsclient.StringValue(tag.Key)+"="+awsclient.StringValue(tag.Value))
+	}
+
+	if want := "Name=base,env=dev,team=infra"; strings.Join(got, ",") != want {
+		t.Errorf("tags are %q, want %q", strings.Join(got, ","), want)
+	}
+}
+
+func TestCopyImageTags(t *testing.T) {
+	f := newFakeEC2(t, func(r *ec2Request) (int, string) {
+		switch r.action {
+		case "CopyImage":
+			return 200, copyImageResponse
+		case "DescribeImages":
+			return 200, imagesResponse("ami-new", "pending")
+		case "CreateTags":
+			if r.form.Get("ResourceId.1") != "ami-new" || r.form.Get("Tag.1.Key") != "env" || r.form.Get("Tag.1.Value") != "prod" {
+				t.Errorf("tags are created with %v, want env=prod for ami-new", r.form)
+			}
+			return 200, `<CreateTagsResponse><return>true</return></CreateTagsResponse>`
+		}
+
+		t.Errorf("unexpected action %s", r.action)
+		return 400, ec2Error("InvalidAction")
+	})
+	defer f.Close()
+
+	attrs := &copyAttributes{}
+	attrs.addTags(populateEC2Tags("env=prod", true))
+
+	if err := copyToEU(t, f, &CopyOptions{}, attrs); err != nil {
+		t.Fatal(err)
+	}
+
+	// the tags are applied to the pending AMI, without waiting until it's
+	// available
+	if got := strings.Join(f.actions(), ","); got != "CopyImage,DescribeImages,CreateTags" {
+		t.Errorf("actions are %s, want CopyImage,DescribeImages,CreateTags", got)
+	}
+}
+
+func TestCopyImagePermissions(t *testing.T) {
+	f := newFakeEC2(t, func(r *ec2Request) (int, string) {
+		switch r.action {
+		case "CopyImage":
+			return 200, copyImageResponse
+		case "DescribeImages":
+			return 200, imagesResponse("ami-new", "available")
+		case "ModifyImageAttribute":
+			if r.form.Get("ImageId") != "ami-new" || r.form.Get("LaunchPermission.Add.1.UserId") != "123456789012" {
+				t.Errorf("launch permissions are modified with %v, want account 123456789012 for ami-new", r.form)
+			}
+			return 200, `<ModifyImageAttributeResponse><return>true</return></ModifyImageAttributeResponse>`
+		}
+
+		t.Errorf("unexpected action %s", r.action)
+		return 400, ec2Error("InvalidAction")
+	})
+	defer f.Close()
+
+	attrs := &copyAttributes{
+		perms: []*launchPermission{{UserId: awsclient.String("123456789012")}},
+	}
+
+	if err := copyToEU(t, f, &CopyOptions{}, attrs); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(f.actions(), ","); got != "CopyImage,DescribeImages,ModifyImageAttribute" {
+		t.Errorf("actions are %s, want CopyImage,DescribeImages,ModifyImageAttribute", got)
+	}
+}
+
+func TestCopyImageDryRun(t *testing.T) {
+	f := newFakeEC2(t, func(r *ec2Request) (int, string) {
+		if r.action != "CopyImage" {
+			t.Errorf("unexpected action %s", r.action)
+			return 400, ec2Error("InvalidAction")
+		}
+
+		if r.form.Get("DryRun") != "true" {
+			t.Errorf("copy is not a dry run")
+		}
+		return 412, ec2Error("DryRunOperation")
+	})
+	defer f.Close()
+
+	attrs := &copyAttributes{}
+	attrs.addTags(populateEC2Tags("env=prod", true))
+
+	if err := copyToEU(t, f, &CopyOptions{DryRun: true, Wait: true}, attrs); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.actions()) != 1 {
+		t.Errorf("actions are %q, want only CopyImage", f.actions())
+	}
+}
+
+func TestParseKmsKeys(t *testing.T) {
+	tests := []struct {
+		encrypted bool
+		keys      []string
+		want      map[string]string
+		err       string
+	}{
+		{encrypted: true},
+		{
+			encrypted: true,
+			keys:      []string{"alias/default", "eu-west-1=arn:aws:kms:eu-west-1:1:key/1"},
+			want:      map[string]string{"": "alias/default", "eu-west-1": "arn:aws:kms:eu-west-1:1:key/1"},
+		},
+		{keys: []string{"alias/default"}, err: "-kms-key can be only used with -encrypted"},
+		{encrypted: true, keys: []string{"eu-west-1="}, err: "no KMS key for region 'eu-west-1'"},
+		{encrypted: true, keys: []string{"key1", "key2"}, err: "multiple KMS keys for region ''"},
+		{encrypted: true, keys: []string{"ap-south-1=key"}, err: "KMS key for region 'ap-south-1', which isn't passed with -to"},
+	}
+
+	for _, test := range tests {
+		c := &CopyOptions{
+			Encrypted:     test.encrypted,
+			SourceRegions: []string{"eu-west-1", "us-west-2"},
+			kmsKeys:       test.keys,
+		}
+
+		err := c.parseKmsKeys()
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%q: error is %v, want %q", test.keys, err, test.err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q: %s", test.keys, err)
+			continue
+		}
+
+		if len(c.KmsKeys) != len(test.want) {
+			t.Errorf("%q: keys are %v, want %v", test.keys, c.KmsKeys, test.want)
+		}
+
+		for region, key := range test.want {
+			if c.KmsKeys[region] != key {
+				t.Errorf("%q: key of region %q is %q, want %q", test.keys, region, c.KmsKeys[region], key)
+			}
+		}
+	}
+}
+
+func TestCopyImageEncrypted(t *testing.T) {
+	tests := []struct {
+		keys map[string]string
+		want string
+	}{
+		{want: ""},
+		{keys: map[string]string{"": "alias/default"}, want: "alias/default"},
+		{keys: map[string]string{"": "alias/default", "eu-west-1": "alias/eu"}, want: "alias/eu"},
+		{keys: map[string]string{"us-west-2": "alias/us"}, want: ""},
+	}
+
+	for _, test := range tests {
+		f := newFakeEC2(t, func(r *ec2Request) (int, string) {
+			return 200, copyImageResponse
+		})
+
+		if err := copyToEU(t, f, &CopyOptions{Encrypted: true, KmsKeys: test.keys}, &copyAttributes{}); err != nil {
+			t.Errorf("keys %v: %s", test.keys, err)
+		}
+
+		form := f.requests[0].form
+		if form.Get("Encrypted") != "true" {
+			t.Errorf("keys %v: copy isn't encrypted", test.keys)
+		}
+
+		if _, ok := form["KmsKeyId"]; ok != (test.want != "") || form.Get("KmsKeyId") != test.want {
+			t.Errorf("keys %v: KMS key is %q, want %q", test.keys, form.Get("KmsKeyId"), test.want)
+		}
+
+		f.Close()
+	}
+}
@@ -0,0 +1,145 @@
+package aws
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"provider/utils"
+
+	awsclient "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+const (
+	// metadataTimeout is the timeout of a request to the EC2 instance
+	// metadata or the ECS credentials endpoint. Both are local, a longer
+	// timeout only delays the failure outside of EC2 and ECS.
+	metadataTimeout = 2 * time.Second
+
+	// expiryWindow refreshes temporary credentials before they expire
+	expiryWindow = 5 * time.Minute
+
+	// ecsCredentialsHost is the host of the credentials endpoint of ECS
+	// tasks, the path is passed via the environment.
+	ecsCredentialsHost = "http://169.254.170.2"
+)
+
+// newCredentials returns the credentials for the given configuration. Static
+// keys of the configuration take precedence, otherwise the standard chain is
+// used: the environment variables (AWS_ACCESS_KEY_ID, ...), the shared
+// credentials file with the configured profile, the role of an ECS task and
+// the role of an EC2 instance. If a role ARN is configured, the role is
+// assumed with these credentials.
+func newCredentials(conf *AwsConfig, client *http.Client, p partition) (*credentials.Credentials, error) {
+	if (conf.AccessKey == "") != (conf.SecretKey == "") {
+		return nil, errors.New("AWS Access Key and Secret Key must be set together. Please check your configuration")
+	}
+
+	var creds *credentials.Credentials
+	if conf.AccessKey != "" {
+		creds = credentials.NewStaticCredentials(conf.AccessKey, conf.SecretKey, "")
+	} else {
+		metadataClient := utils.NewHTTPClient(metadataTimeout)
+
+		creds = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvProvider{},
+			&credentials.SharedCredentialsProvider{Profile: conf.Profile},
+			&ecsProvider{client: metadataClient},
+			&ec2rolecreds.EC2RoleProvider{
+				Client:       ec2metadata.New(&ec2metadata.Config{HTTPClient: metadataClient}),
+				ExpiryWindow: expiryWindow,
+			},
+		})
+	}
+
+	if conf.RoleARN == "" {
+		if conf.ExternalID != "" {
+			return nil, errors.New("AWS External ID is set without a Role ARN. Please check your configuration")
+		}
+		return creds, nil
+	}
+
+	stsConf := &awsclient.Config{
+		Credentials: creds,
+		HTTPClient:  client,
+		Region:      awsclient.String(p.region),
+	}
+	if endpoint := p.endpoint("sts", p.region); endpoint != "" {
+		stsConf.Endpoint = awsclient.String(endpoint)
+	}
+	svc := sts.New(stsConf)
+
+	return credentials.NewCredentials(&stscreds.AssumeRoleProvider{
+		Client:          externalIDRoler{svc: svc, externalID: conf.ExternalID},
+		RoleARN:         conf.RoleARN,
+		RoleSessionName: fmt.Sprintf("images-%d", time.Now().Unix()),
+		Duration:        time.Hour,
+		ExpiryWindow:    expiryWindow,
+	}), nil
+}
+
+// externalIDRoler adds the external ID to the AssumeRole requests of the
+// stscreds provider, which doesn't support it.
+type externalIDRoler struct {
+	svc        *sts.STS
+	externalID string
+}
+
+func (e externalIDRoler) AssumeRole(input *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error) {
+	if e.externalID != "" {
+		input.ExternalId = awsclient.String(e.externalID)
+	}
+	return e.svc.AssumeRole(input)
+}
+
+// ecsProvider retrieves the credentials of the task role of an ECS container.
+// The SDK doesn't support it yet.
+type ecsProvider struct {
+	credentials.Expiry
+
+	client *http.Client
+}
+
+func (e *ecsProvider) Retrieve() (credentials.Value, error) {
+	path := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI")
+	if path == "" {
+		return credentials.Value{}, errors.New("not running in an ECS task")
+	}
+
+	resp, err := e.client.Get(ecsCredentialsHost + path)
+	if err != nil {
+		return credentials.Value{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return credentials.Value{}, fmt.Errorf("fetching ECS task credentials failed: %s", resp.Status)
+	}
+
+	var v struct {
+		AccessKeyID     string `json:"AccessKeyId"`
+		SecretAccessKey string
+		Token           string
+		Expiration      time.Time
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return credentials.Value{}, err
+	}
+
+	e.SetExpiration(v.Expiration, expiryWindow)
+
+	return credentials.Value{
+		AccessKeyID:     v.AccessKeyID,
+		SecretAccessKey: v.SecretAccessKey,
+		SessionToken:    v.Token,
+	}, nil
+}
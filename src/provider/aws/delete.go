@@ -1,15 +1,24 @@
 package aws
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"provider/utils"
 
 	awsclient "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/fatih/flags"
 	"github.com/hashicorp/go-multierror"
+	"golang.org/x/net/context"
 )
 
 type DeleteOptions struct {
@@ -19,6 +28,10 @@ type DeleteOptions struct {
 	// DryRun doesn't run the command, but shows the action
 	DryRun bool
 
+	// DeleteSnapshots deletes the EBS snapshots of the images, which aren't
+	// used by other images
+	DeleteSnapshots bool
+
 	helpMsg string
 	flagSet *flag.FlagSet
 }
@@ -29,6 +42,7 @@ func newDeleteOptions() *DeleteOptions {
 	flagSet := flag.NewFlagSet("delete", flag.ContinueOnError)
 	flagSet.Var(flags.NewStringSlice(nil, &d.ImageIds), "ids", "Images to be delete with the given ids")
 	flagSet.BoolVar(&d.DryRun, "dry-run", false, "Don't run command, but show the action")
+	flagSet.BoolVar(&d.DeleteSnapshots, "delete-snapshots", false, "Delete the snapshots of the images")
 	d.helpMsg = `Usage: images delete --providers aws [options]
 
   Deregister AMI's.
@@ -37,9 +51,11 @@ Options:
 
   -ids         "ami-123,..."   Images to be deleted with the given ids
   -dry-run                     Don't run command, but show the action
+  -delete-snapshots            Delete the EBS snapshots of the AMI's, which
+                               aren't used by other AMI's
 `
 	flagSet.Usage = func() {
-		fmt.Fprintf(os.Stderr, d.helpMsg)
+		fmt.Fprint(os.Stderr, d.helpMsg)
 	}
 
 	flagSet.SetOutput(ioutil.Discard) // don't print anything without my permission
@@ -47,19 +63,44 @@ Options:
 	return d
 }
 
-// Delete deletes the given images.
-func (a *AwsImages) DeleteImages(opts *DeleteOptions) error {
-	deleteImages := func(svc *ec2.EC2, images []string) error {
+// Delete deletes the given images. With opts.DeleteSnapshots the snapshots of
+// the images are deleted too and the result of each image and snapshot is
+// printed.
+func (a *AwsImages) DeleteImages(ctx context.Context, opts *DeleteOptions) error {
+	var report *deleteReport
+	if opts.DeleteSnapshots {
+		report = &deleteReport{}
+		defer report.print()
+	}
+
+	return a.deleteImages(ctx, opts, report)
+}
+
+// deleteImages deletes the given images. With opts.DeleteSnapshots the
+// results are added to report.
+func (a *AwsImages) deleteImages(ctx context.Context, opts *DeleteOptions, report *deleteReport) error {
+	if opts.DeleteSnapshots {
+		deleteImages := func(ctx context.Context, svc *ec2.EC2, images []string) error {
+			return a.deleteWithSnapshots(ctx, svc, report, opts.DryRun, images)
+		}
+
+		return a.multiCall(ctx, deleteImages, opts.ImageIds...)
+	}
+
+	deleteImages := func(ctx context.Context, svc *ec2.EC2, images []string) error {
 		var multiErrors error
 
 		for _, image := range images {
-			input := &ec2.DeregisterImageInput{
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			req, _ := svc.DeregisterImageRequest(&ec2.DeregisterImageInput{
 				ImageId: awsclient.String(image),
 				DryRun:  awsclient.Bool(opts.DryRun),
-			}
+			})
 
-			_, err := svc.DeregisterImage(input)
-			if err != nil {
+			if err := send(ctx, req); err != nil {
 				multiErrors = multierror.Append(multiErrors, err)
 			}
 		}
@@ -67,5 +108,214 @@ func (a *AwsImages) DeleteImages(opts *DeleteOptions) error {
 		return multiErrors
 	}
 
-	return a.multiCall(deleteImages, opts.ImageIds...)
+	return a.multiCall(ctx, deleteImages, opts.ImageIds...)
+}
+
+// snapshotInUseTimeout is the maximum duration to wait for a snapshot, which
+// is still in use right after its image is deregistered.
+const snapshotInUseTimeout = time.Minute
+
+// deleteWithSnapshots deregisters the given images of a single region and
+// deletes their snapshots afterwards. Snapshots which are used by other images
+// are skipped.
+func (a *AwsImages) deleteWithSnapshots(ctx context.Context, svc *ec2.EC2, report *deleteReport, dryRun bool, images []string) error {
+	region := awsclient.StringValue(svc.Config.Region)
+
+	req, resp := svc.DescribeImagesRequest(&ec2.DescribeImagesInput{
+		ImageIds: stringSlice(images...),
+	})
+	if err := send(ctx, req); err != nil {
+		return err
+	}
+
+	snapshots := make(map[string][]string, len(resp.Images))
+	var snapshotIds []string
+	for _, image := range resp.Images {
+		id := awsclient.StringValue(image.ImageId)
+		snapshots[id] = []string{}
+
+		for _, device := range image.BlockDeviceMappings {
+			if device.Ebs != nil && device.Ebs.SnapshotId != nil {
+				snapshots[id] = append(snapshots[id], *device.Ebs.SnapshotId)
+				snapshotIds = append(snapshotIds, *device.Ebs.SnapshotId)
+			}
+		}
+	}
+
+	users, err := snapshotUsers(ctx, svc, snapshotIds, images)
+	if err != nil {
+		return err
+	}
+
+	var multiErrors error
+	fail := func(id string, err error) {
+		report.add(a.account, region, id, "failed: %s", err)
+		multiErrors = multierror.Append(multiErrors, fmt.Errorf("%s: %s", id, err))
+	}
+
+	deleted := make(map[string]bool)
+	for _, image := range images {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		imageSnapshots, ok := snapshots[image]
+		if !ok {
+			fail(image, errors.New("image not found"))
+			continue
+		}
+
+		req, _ := svc.DeregisterImageRequest(&ec2.DeregisterImageInput{
+			ImageId: awsclient.String(image),
+			DryRun:  awsclient.Bool(dryRun),
+		})
+		if err := send(ctx, req); err != nil && !isDryRun(err) {
+			// the snapshots are still used by the image
+			fail(image, err)
+			continue
+		}
+		report.add(a.account, region, image, "%s", result("deregistered", dryRun))
+
+		for _, snapshot := range imageSnapshots {
+			if deleted[snapshot] {
+				continue
+			}
+
+			if used := users[snapshot]; len(used) != 0 {
+				report.add(a.account, region, snapshot, "skipped: used by %s", strings.Join(used, ", "))
+				continue
+			}
+
+			if err := deleteSnapshot(ctx, svc, snapshot, dryRun); err != nil {
+				fail(snapshot, err)
+				continue
+			}
+
+			deleted[snapshot] = true
+			report.add(a.account, region, snapshot, "%s", result("deleted", dryRun))
+		}
+	}
+
+	return multiErrors
+}
+
+// snapshotUsers returns the images, except the excluded ones, which use the
+// given snapshots.
+func snapshotUsers(ctx context.Context, svc *ec2.EC2, snapshots, exclude []string) (map[string][]string, error) {
+	users := make(map[string][]string)
+	if len(snapshots) == 0 {
+		return users, nil
+	}
+
+	req, resp := svc.DescribeImagesRequest(&ec2.DescribeImagesInput{
+		Owners: stringSlice("self"),
+		Filters: []*ec2.Filter{{
+			Name:   awsclient.String("block-device-mapping.snapshot-id"),
+			Values: stringSlice(snapshots...),
+		}},
+	})
+	if err := send(ctx, req); err != nil {
+		return nil, err
+	}
+
+	for _, image := range resp.Images {
+		id := awsclient.StringValue(image.ImageId)
+		if contains(exclude, id) {
+			continue
+		}
+
+		for _, device := range image.BlockDeviceMappings {
+			if device.Ebs != nil && device.Ebs.SnapshotId != nil {
+				snapshot := *device.Ebs.SnapshotId
+				users[snapshot] = append(users[snapshot], id)
+			}
+		}
+	}
+
+	return users, nil
+}
+
+// deleteSnapshot deletes the given snapshot. A snapshot might be still in use
+// for a short time after its image is deregistered, so it's retried until
+// snapshotInUseTimeout.
+func deleteSnapshot(ctx context.Context, svc *ec2.EC2, snapshot string, dryRun bool) error {
+	var inUse error
+	err := utils.Poll(ctx, snapshotInUseTimeout, func() (bool, error) {
+		req, _ := svc.DeleteSnapshotRequest(&ec2.DeleteSnapshotInput{
+			SnapshotId: awsclient.String(snapshot),
+			DryRun:     awsclient.Bool(dryRun),
+		})
+
+		err := send(ctx, req)
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "InvalidSnapshot.InUse" {
+			inUse = err
+			return false, nil
+		}
+
+		inUse = nil
+		if err != nil && !isDryRun(err) {
+			return false, err
+		}
+		return true, nil
+	})
+
+	if err != nil && inUse != nil && ctx.Err() == nil {
+		return inUse
+	}
+	return err
+}
+
+// isDryRun returns true if the error reports that a dry run request would
+// have succeeded.
+func isDryRun(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	return ok && awsErr.Code() == "DryRunOperation"
+}
+
+// result returns the result of a successful action, which is only reported
+// for a dry run.
+func result(action string, dryRun bool) string {
+	if dryRun {
+		return "would be " + action + " (dry run)"
+	}
+	return action
+}
+
+// deleteReport collects the result of each deleted image and snapshot. It's
+// safe for concurrent use.
+type deleteReport struct {
+	mu      sync.Mutex
+	results [][4]string // account, region, id and result
+}
+
+func (d *deleteReport) add(account, region, id, format string, args ...interface{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.results = append(d.results, [4]string{account, region, id, fmt.Sprintf(format, args...)})
+}
+
+// print prints the results sorted by account and region, the results of a
+// single region are in the order they were added. The account is only
+// printed for named accounts.
+func (d *deleteReport) print() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	sort.SliceStable(d.results, func(i, j int) bool {
+		if d.results[i][0] != d.results[j][0] {
+			return d.results[i][0] < d.results[j][0]
+		}
+		return d.results[i][1] < d.results[j][1]
+	})
+
+	w := utils.NewImagesTabWriter(os.Stdout)
+	defer w.Flush()
+
+	for _, r := range d.results {
+		if r[0] != "" {
+			fmt.Fprintf(w, "%s\t", r[0])
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", r[1], r[2], r[3])
+	}
 }
@@ -0,0 +1,170 @@
+package aws
+
+import (
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+// deleteResults returns the results of the report in the form of
+// "id: result"
+func deleteResults(report *deleteReport) []string {
+	var results []string
+	for _, r := range report.results {
+		results = append(results, r[2]+": "+r[3])
+	}
+	return results
+}
+
+func TestDeleteWithSnapshots(t *testing.T) {
+	f := newFakeEC2(t, func(r *ec2Request) (int, string) {
+		switch r.action {
+		case "DescribeImages":
+			if r.form.Get("Filter.1.Name") == "block-device-mapping.snapshot-id" {
+				return 200, imagePage("", "ami-1", "snap-1", "ami-other", "snap-shared")
+			}
+			return 200, `<DescribeImagesResponse><imagesSet><item><imageId>ami-1</imageId>` +
+				`<blockDeviceMapping>` +
+				`<item><ebs><snapshotId>snap-1</snapshotId></ebs></item>` +
+				`<item><ebs><snapshotId>snap-shared</snapshotId></ebs></item>` +
+				`<item><virtualName>ephemeral0</virtualName></item>` +
+				`</blockDeviceMapping></item></imagesSet></DescribeImagesResponse>`
+		case "DeregisterImage", "DeleteSnapshot":
+			return 200, `<Response><return>true</return></Response>`
+		}
+
+		t.Errorf("unexpected action %s", r.action)
+		return 400, ec2Error("InvalidAction")
+	})
+	defer f.Close()
+
+	report := &deleteReport{}
+	opts := &DeleteOptions{ImageIds: []string{"ami-1", "ami-missing"}, DeleteSnapshots: true}
+
+	err := f.images("us-east-1").deleteImages(context.Background(), opts, report)
+	if err == nil || !strings.Contains(err.Error(), "ami-missing: image not found") {
+		t.Errorf("error is %v, want ami-missing not found", err)
+	}
+
+	want := []string{
+		"ami-1: deregistered",
+		"snap-1: deleted",
+		"snap-shared: skipped: used by ami-other",
+		"ami-missing: failed: image not found",
+	}
+	if got := deleteResults(report); !reflect.DeepEqual(got, want) {
+		t.Errorf("results are %q, want %q", got, want)
+	}
+
+	for _, r := range f.requests {
+		if r.action == "DeleteSnapshot" && r.form.Get("SnapshotId") != "snap-1" {
+			t.Errorf("snapshot %s is deleted, want only snap-1", r.form.Get("SnapshotId"))
+		}
+	}
+}
+
+func TestDeleteWithSnapshotsDeregisterFails(t *testing.T) {
+	f := newFakeEC2(t, func(r *ec2Request) (int, string) {
+		switch r.action {
+		case "DescribeImages":
+			return 200, imagePage("", "ami-1", "snap-1")
+		case "DeregisterImage":
+			return 400, ec2Error("AuthFailure")
+		}
+
+		t.Errorf("unexpected action %s", r.action)
+		return 400, ec2Error("InvalidAction")
+	})
+	defer f.Close()
+
+	report := &deleteReport{}
+	opts := &DeleteOptions{ImageIds: []string{"ami-1"}, DeleteSnapshots: true}
+
+	err := f.images("us-east-1").deleteImages(context.Background(), opts, report)
+	if err == nil || !strings.Contains(err.Error(), "AuthFailure") {
+		t.Errorf("error is %v, want AuthFailure", err)
+	}
+
+	if got := deleteResults(report); len(got) != 1 || !strings.HasPrefix(got[0], "ami-1: failed") {
+		t.Errorf("results are %q, want only the failed image", got)
+	}
+}
+
+func TestDeleteWithSnapshotsDryRun(t *testing.T) {
+	f := newFakeEC2(t, func(r *ec2Request) (int, string) {
+		switch r.action {
+		case "DescribeImages":
+			return 200, imagePage("", "ami-1", "snap-1")
+		case "DeregisterImage", "DeleteSnapshot":
+			if r.form.Get("DryRun") != "true" {
+				t.Errorf("%s is not a dry run", r.action)
+			}
+			return 412, ec2Error("DryRunOperation")
+		}
+
+		t.Errorf("unexpected action %s", r.action)
+		return 400, ec2Error("InvalidAction")
+	})
+	defer f.Close()
+
+	report := &deleteReport{}
+	opts := &DeleteOptions{ImageIds: []string{"ami-1"}, DeleteSnapshots: true, DryRun: true}
+
+	if err := f.images("us-east-1").deleteImages(context.Background(), opts, report); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"ami-1: would be deregistered (dry run)",
+		"snap-1: would be deleted (dry run)",
+	}
+	if got := deleteResults(report); !reflect.DeepEqual(got, want) {
+		t.Errorf("results are %q, want %q", got, want)
+	}
+}
+
+func TestDeleteSnapshotInUse(t *testing.T) {
+	var attempts int32
+
+	f := newFakeEC2(t, func(r *ec2Request) (int, string) {
+		if r.action != "DeleteSnapshot" {
+			t.Errorf("unexpected action %s", r.action)
+			return 400, ec2Error("InvalidAction")
+		}
+
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			return 400, ec2Error("InvalidSnapshot.InUse")
+		}
+		return 200, `<Response><return>true</return></Response>`
+	})
+	defer f.Close()
+
+	svc, _ := f.images("us-east-1").singleSvc()
+	if err := deleteSnapshot(context.Background(), svc, "snap-1", false); err != nil {
+		t.Fatal(err)
+	}
+
+	if attempts := atomic.LoadInt32(&attempts); attempts != 2 {
+		t.Errorf("snapshot is deleted with %d attempts, want 2", attempts)
+	}
+}
+
+func TestDeleteSnapshotInUseCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	f := newFakeEC2(t, func(r *ec2Request) (int, string) {
+		cancel()
+		return 400, ec2Error("InvalidSnapshot.InUse")
+	})
+	defer f.Close()
+
+	svc, _ := f.images("us-east-1").singleSvc()
+
+	if err := deleteSnapshot(ctx, svc, "snap-1", false); err != context.Canceled {
+		t.Errorf("error is %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,86 @@
+package aws
+
+import (
+	"fmt"
+	"time"
+
+	awsclient "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"golang.org/x/net/context"
+)
+
+type enableImageDeprecationInput struct {
+	DeprecateAt *time.Time `type:"timestamp" timestampFormat:"iso8601" required:"true"`
+	DryRun      *bool      `type:"boolean"`
+	ImageId     *string    `type:"string" required:"true"`
+}
+
+type disableImageDeprecationInput struct {
+	DryRun  *bool   `type:"boolean"`
+	ImageId *string `type:"string" required:"true"`
+}
+
+type imageDeprecationOutput struct {
+	Return *bool `locationName:"return" type:"boolean"`
+}
+
+// SetDescription sets the description of the given images.
+func (a *AwsImages) SetDescription(ctx context.Context, description string, dryRun bool, images ...string) error {
+	setDescription := func(ctx context.Context, svc *ec2.EC2, images []string) error {
+		for _, image := range images {
+			req, _ := svc.ModifyImageAttributeRequest(&ec2.ModifyImageAttributeInput{
+				ImageId:     awsclient.String(image),
+				Description: &ec2.AttributeValue{Value: awsclient.String(description)},
+				DryRun:      awsclient.Bool(dryRun),
+			})
+
+			if err := send(ctx, req); err != nil {
+				return fmt.Errorf("%s: %s", image, err)
+			}
+		}
+		return nil
+	}
+
+	return a.multiCall(ctx, setDescription, images...)
+}
+
+// Deprecate schedules the deprecation of the given images at the given time.
+// Deprecated images can still be launched, but aren't listed for other
+// accounts anymore.
+func (a *AwsImages) Deprecate(ctx context.Context, at time.Time, dryRun bool, images ...string) error {
+	deprecate := func(ctx context.Context, svc *ec2.EC2, images []string) error {
+		for _, image := range images {
+			req := newRequest(svc, "EnableImageDeprecation", &enableImageDeprecationInput{
+				DeprecateAt: &at,
+				DryRun:      awsclient.Bool(dryRun),
+				ImageId:     awsclient.String(image),
+			}, &imageDeprecationOutput{})
+
+			if err := send(ctx, req); err != nil {
+				return fmt.Errorf("%s: %s", image, err)
+			}
+		}
+		return nil
+	}
+
+	return a.multiCall(ctx, deprecate, images...)
+}
+
+// Undeprecate cancels the deprecation of the given images.
+func (a *AwsImages) Undeprecate(ctx context.Context, dryRun bool, images ...string) error {
+	undeprecate := func(ctx context.Context, svc *ec2.EC2, images []string) error {
+		for _, image := range images {
+			req := newRequest(svc, "DisableImageDeprecation", &disableImageDeprecationInput{
+				DryRun:  awsclient.Bool(dryRun),
+				ImageId: awsclient.String(image),
+			}, &imageDeprecationOutput{})
+
+			if err := send(ctx, req); err != nil {
+				return fmt.Errorf("%s: %s", image, err)
+			}
+		}
+		return nil
+	}
+
+	return a.multiCall(ctx, undeprecate, images...)
+}
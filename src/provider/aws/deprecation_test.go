@@ -0,0 +1,104 @@
+package aws
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestModifyDeprecation(t *testing.T) {
+	f := newFakeEC2(t, func(r *ec2Request) (int, string) {
+		switch r.action {
+		case "ModifyImageAttribute":
+			return 200, modifyImageAttributeResponse
+		case "EnableImageDeprecation", "DisableImageDeprecation":
+			return 200, `<Response><return>true</return></Response>`
+		}
+
+		t.Errorf("unexpected action %s", r.action)
+		return 400, ec2Error("InvalidAction")
+	})
+	defer f.Close()
+
+	a := newFakeAccounts(map[string]*fakeEC2{"": f})
+	err := a.Modify(context.Background(), []string{
+		"-ids", "ami-1",
+		"-description", "base image",
+		"-deprecate-at", "2030-01-02",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Modify(context.Background(), []string{"-ids", "ami-1", "-undeprecate"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "ModifyImageAttribute,EnableImageDeprecation,DisableImageDeprecation"
+	if got := strings.Join(f.actions(), ","); got != want {
+		t.Fatalf("actions are %s, want %s", got, want)
+	}
+
+	for i, params := range []map[string]string{
+		{"ImageId": "ami-1", "Description.Value": "base image"},
+		{"ImageId": "ami-1", "DeprecateAt": "2030-01-02T00:00:00Z"},
+		{"ImageId": "ami-1"},
+	} {
+		for key, want := range params {
+			if got := f.requests[i].form.Get(key); got != want {
+				t.Errorf("%s: %s is %q, want %q", f.requests[i].action, key, got, want)
+			}
+		}
+	}
+}
+
+func TestModifyDeprecationConflicts(t *testing.T) {
+	f := newFakeEC2(t, func(r *ec2Request) (int, string) {
+		t.Errorf("unexpected action %s", r.action)
+		return 400, ec2Error("InvalidAction")
+	})
+	defer f.Close()
+
+	a := newFakeAccounts(map[string]*fakeEC2{"": f})
+
+	for _, args := range [][]string{
+		{"-ids", "ami-1", "-deprecate-at", "2030-01-02", "-undeprecate"},
+		{"-ids", "ami-1", "-deprecate-at", "someday"},
+	} {
+		if err := a.Modify(context.Background(), args); err == nil {
+			t.Errorf("%q: no error, want an invalid deprecation", args)
+		}
+	}
+}
+
+func TestFetchDeprecationTime(t *testing.T) {
+	f := newFakeEC2(t, func(r *ec2Request) (int, string) {
+		return 200, `<DescribeImagesResponse><imagesSet>` +
+			`<item><imageId>ami-1</imageId><imageState>available</imageState><creationDate>2015-09-01T12:00:00.000Z</creationDate>` +
+			`<deprecationTime>2030-01-02T00:00:00.000Z</deprecationTime></item>` +
+			`<item><imageId>ami-2</imageId><imageState>available</imageState><creationDate>2015-09-01T12:00:00.000Z</creationDate></item>` +
+			`</imagesSet></DescribeImagesResponse>`
+	})
+	defer f.Close()
+
+	images, err := newFakeAccounts(map[string]*fakeEC2{"": f}).Fetch(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deprecated := make(map[string]*time.Time)
+	for _, image := range images {
+		deprecated[image.ID] = image.DeprecatedAt
+	}
+
+	want := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+	if d := deprecated["ami-1"]; d == nil || !d.Equal(want) {
+		t.Errorf("ami-1 is deprecated at %v, want %s", d, want)
+	}
+
+	if d, ok := deprecated["ami-2"]; !ok || d != nil {
+		t.Errorf("ami-2 is deprecated at %v, want no deprecation", d)
+	}
+}
@@ -0,0 +1,117 @@
+package aws
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+
+	"provider/utils"
+
+	awsclient "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+// ec2Request is a single request to the fake EC2 API
+type ec2Request struct {
+	region string
+	action string
+	form   url.Values
+}
+
+// ec2Handler returns the status and the XML body of the response to a
+// request of the fake EC2 API.
+type ec2Handler func(r *ec2Request) (int, string)
+
+// fakeEC2 is a fake EC2 API, which records all requests.
+type fakeEC2 struct {
+	t       *testing.T
+	server  *httptest.Server
+	handler ec2Handler
+
+	mu       sync.Mutex
+	requests []*ec2Request
+}
+
+var credentialRegion = regexp.MustCompile(`Credential=[^/]+/[^/]+/([^/]+)/`)
+
+func newFakeEC2(t *testing.T, handler ec2Handler) *fakeEC2 {
+	f := &fakeEC2{t: t, handler: handler}
+	f.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parsing request: %s", err)
+		}
+
+		req := &ec2Request{action: r.Form.Get("Action"), form: r.Form}
+		if m := credentialRegion.FindStringSubmatch(r.Header.Get("Authorization")); m != nil {
+			req.region = m[1]
+		}
+
+		f.mu.Lock()
+		f.requests = append(f.requests, req)
+		f.mu.Unlock()
+
+		status, body := handler(req)
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+
+	return f
+}
+
+// images returns a new AwsImages for the given regions, whose requests are
+// sent to the fake API. Failed requests are not retried.
+func (f *fakeEC2) images(regions ...string) *AwsImages {
+	return f.retryImages(0, regions...)
+}
+
+// retryImages is like images, but retries failed requests at most the given
+// number of times.
+func (f *fakeEC2) retryImages(retries int, regions ...string) *AwsImages {
+	conf := &awsclient.Config{
+		Credentials: credentials.NewStaticCredentials("access", "secret", ""),
+		Endpoint:    awsclient.String(f.server.URL),
+	}
+
+	retry, _ := utils.NewRetry(retries)
+	a := &AwsImages{
+		services: newMultiRegion(conf, retry, partitions["aws"]),
+		retry:    retry,
+	}
+	a.services.setRegions(regions)
+	return a
+}
+
+// actions returns the actions of all recorded requests
+func (f *fakeEC2) actions() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	actions := make([]string, len(f.requests))
+	for i, r := range f.requests {
+		actions[i] = r.action
+	}
+	return actions
+}
+
+func (f *fakeEC2) Close() { f.server.Close() }
+
+// ec2Error returns the XML body of an EC2 error response
+func ec2Error(code string) string {
+	return `<Response><Errors><Error><Code>` + code + `</Code><Message>` + code +
+		`</Message></Error></Errors><RequestID>1</RequestID></Response>`
+}
+
+// imagesResponse returns the XML body of a DescribeImages response with the
+// given images, which are in the form of id and state pairs.
+func imagesResponse(idStates ...string) string {
+	body := `<DescribeImagesResponse><imagesSet>`
+	for i := 0; i+1 < len(idStates); i += 2 {
+		body += `<item><imageId>` + idStates[i] + `</imageId><imageState>` + idStates[i+1] +
+			`</imageState><name>` + idStates[i] + `</name><creationDate>2015-09-01T12:00:00.000Z</creationDate></item>`
+	}
+	return body + `</imagesSet></DescribeImagesResponse>`
+}
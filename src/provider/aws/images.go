@@ -1,111 +1,73 @@
 package aws
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
+	"time"
 
-	"provider/utils"
+	"provider"
 
+	awsclient "github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
-	"github.com/fatih/color"
-	"github.com/shiena/ansicolor"
 )
 
 // Images defines and represents regions to images
 type Images map[string][]*ec2.Image
 
-// Print prints the images to standard output.
-func (i Images) Print(mode utils.OutputMode) error {
-	if len(i) == 0 {
-		return errors.New("no images found")
-	}
-
-	switch mode {
-	case utils.JSON:
-		out, err := i.outputJSON()
-		if err != nil {
-			return err
+// Normalize returns the provider agnostic representation of the images.
+func (i Images) Normalize() provider.Images {
+	images := make(provider.Images, 0)
+	for region, regionImages := range i {
+		for _, image := range regionImages {
+			images = append(images, normalize(region, image))
 		}
+	}
+	return images
+}
 
-		fmt.Println(out)
-		return nil
-	case utils.Simplified:
-
-		green := color.New(color.FgGreen).SprintfFunc()
-		output := ansicolor.NewAnsiColorWriter(os.Stdout)
-		w := utils.NewImagesTabWriter(output)
-		defer w.Flush()
-
-		for region, images := range i {
-			if len(images) == 0 {
-				continue
-			}
-
-			fmt.Fprintln(w, green("AWS Region: %s (%d images):", region, len(images)))
-			fmt.Fprintln(w, "    Name\tID\tState\tTags")
+func normalize(region string, image *ec2.Image) *provider.Image {
+	img := &provider.Image{
+		Provider: "aws",
+		Region:   region,
+		ID:       awsclient.StringValue(image.ImageId),
+		Name:     awsclient.StringValue(image.Name),
+		State:    awsclient.StringValue(image.State),
+		Tags:     make(map[string]string, len(image.Tags)),
+		Raw:      image,
+	}
 
-			for ix, image := range images {
-				tags := make([]string, len(image.Tags))
-				for i, tag := range image.Tags {
-					tags[i] = *tag.Key + ":" + *tag.Value
-				}
+	if image.CreationDate != nil {
+		img.CreatedAt, _ = time.Parse(time.RFC3339, *image.CreationDate)
+	}
 
-				name := ""
-				if image.Name != nil {
-					name = *image.Name
-				}
+	for _, tag := range image.Tags {
+		img.Tags[awsclient.StringValue(tag.Key)] = awsclient.StringValue(tag.Value)
+	}
 
-				state := *image.State
-				if *image.State == "failed" {
-					state += " (" + *image.StateReason.Message + ")"
-				}
+	encrypted, snapshots := 0, 0
+	for _, device := range image.BlockDeviceMappings {
+		if device.Ebs != nil {
+			img.Size += awsclient.Int64Value(device.Ebs.VolumeSize)
 
-				fmt.Fprintf(w, "[%d] %s\t%s\t%s\t%+v\n",
-					ix+1, name, *image.ImageId, state, tags)
+			snapshots++
+			if awsclient.BoolValue(device.Ebs.Encrypted) {
+				encrypted++
 			}
-
-			fmt.Fprintln(w, "")
 		}
-		return nil
-	default:
-		return fmt.Errorf("output mode '%s' is not valid", mode)
 	}
-}
-
-// outputJSON returns a JSON formatted output of all images
-func (i Images) outputJSON() (string, error) {
-	images := i.outputImages()
 
-	out, err := json.MarshalIndent(&images, "", "    ")
-	if err != nil {
-		return "", err
+	switch {
+	case snapshots == 0:
+		// instance store AMIs don't have any snapshots
+	case encrypted == snapshots:
+		img.Encryption = "encrypted"
+	case encrypted == 0:
+		img.Encryption = "unencrypted"
+	default:
+		img.Encryption = "partial"
 	}
 
-	return string(out), nil
-}
-
-// outputImage is used to attach the region to an image before marshalling it into
-// other output modes.
-type outputImage struct {
-	ImageRegion string
-	*ec2.Image
-}
-
-// outputImages returns a slice of images which includes additional region
-// data.  It flattens the images to a single slice of images.
-func (i Images) outputImages() []outputImage {
-	flattened := make([]outputImage, 0)
-	for region, images := range i {
-		for _, image := range images {
-			flattened = append(flattened, outputImage{
-				Image:       image,
-				ImageRegion: region,
-			})
-		}
-	}
-	return flattened
+	return img
 }
 
 // RegionFromId returns the region for the given id
@@ -6,19 +6,30 @@ import (
 	"io/ioutil"
 	"os"
 	"sort"
+	"strings"
 	"sync"
+	"time"
 
+	"provider"
 	"provider/utils"
 
+	awsclient "github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/fatih/flags"
 	"github.com/hashicorp/go-multierror"
+	"golang.org/x/net/context"
 )
 
+// describeImagesPageSize is the number of images fetched with a single
+// DescribeImages request
+const describeImagesPageSize = 1000
+
 type listFlags struct {
-	output   utils.OutputMode
-	imageIds []string
-	owners   []string
+	output          utils.OutputMode
+	imageIds        []string
+	owners          []string
+	executableUsers []string
+	filters         filtersValue
 
 	helpMsg string
 	flagSet *flag.FlagSet
@@ -31,6 +42,8 @@ func newListFlags() *listFlags {
 	flagSet.Var(utils.NewOutputValue(utils.Simplified, &l.output), "output", "Output mode")
 	flagSet.Var(flags.NewStringSlice(nil, &l.imageIds), "ids", "Images to be listed. Default case is all images")
 	flagSet.Var(flags.NewStringSlice(nil, &l.owners), "owners", "Filters the images by the owner. By ddefault self is being used")
+	flagSet.Var(flags.NewStringSlice(nil, &l.executableUsers), "executable-users", "Filters the images by the users with launch permissions")
+	flagSet.Var(&l.filters, "filters", "Filters the images by the given filter")
 	l.helpMsg = `Usage: images list --providers aws [options]
 
    List AMI properties.
@@ -39,19 +52,30 @@ Options:
 
   -ids     "ami-123,..."       Images to be listed. By default all images are shown.
   -owners  "self,..."          Filters the images by the owner. By default self is being used.
+                               Accepts account ids and the aliases "self", "amazon"
+                               and "aws-marketplace".
+  -executable-users "all,..."  Filters the images by the users with launch permissions.
+                               Accepts account ids and the aliases "self" and "all".
+  -filters "name=...,values=..."
+                               Filters the images by the given DescribeImages filter,
+                               i.e: "name=tag:env,values=prod,staging". Can be given
+                               multiple times, all filters have to match.
   -output  "json"              Output mode of images. (default: "simplified")
-                               Available options: "json","table" or "simplified" 
+                               Available options: ` + utils.OutputsHelp + `
 `
 
 	flagSet.Usage = func() {
-		fmt.Fprintf(os.Stderr, l.helpMsg)
+		fmt.Fprint(os.Stderr, l.helpMsg)
 	}
 	flagSet.SetOutput(ioutil.Discard) // don't print anything without my permission
 	l.flagSet = flagSet
 	return l
 }
 
-func (a *AwsImages) Images(input *ec2.DescribeImagesInput) (Images, error) {
+// Images returns the images of all regions matching the given input. If ctx
+// is done before all regions are fetched, the returned error reports the
+// finished regions.
+func (a *AwsImages) Images(ctx context.Context, input *ec2.DescribeImagesInput) (Images, error) {
 	var (
 		wg sync.WaitGroup
 		mu sync.Mutex
@@ -59,23 +83,32 @@ func (a *AwsImages) Images(input *ec2.DescribeImagesInput) (Images, error) {
 		multiErrors error
 	)
 
+	regions := make([]string, 0, len(a.services.regions))
+	for r := range a.services.regions {
+		regions = append(regions, r)
+	}
+	sort.Strings(regions)
+
+	tracker := utils.NewTracker(regions...)
 	images := make(map[string][]*ec2.Image)
 
 	for r, s := range a.services.regions {
 		wg.Add(1)
 		go func(region string, svc *ec2.EC2) {
-			resp, err := svc.DescribeImages(input)
+			regionImages, deprecations, err := describeImages(ctx, svc, input)
+			a.deprecations.add(deprecations)
 			mu.Lock()
 
 			if err != nil {
 				multiErrors = multierror.Append(multiErrors, err)
 			} else {
 				// sort from oldest to newest
-				if len(resp.Images) > 1 {
-					sort.Sort(byTime(resp.Images))
+				if len(regionImages) > 1 {
+					sort.Sort(byTime(regionImages))
 				}
 
-				images[region] = resp.Images
+				images[region] = regionImages
+				tracker.Done(region)
 			}
 
 			mu.Unlock()
@@ -85,13 +118,176 @@ func (a *AwsImages) Images(input *ec2.DescribeImagesInput) (Images, error) {
 
 	wg.Wait()
 
-	return images, multiErrors
+	return images, tracker.Err(ctx, multiErrors)
 }
 
-func (a *AwsImages) ownerImages() (Images, error) {
+func (a *AwsImages) ownerImages(ctx context.Context) (Images, error) {
 	input := &ec2.DescribeImagesInput{
 		Owners: stringSlice("self"),
 	}
 
-	return a.Images(input)
+	return a.Images(ctx, input)
+}
+
+// describeImagesInput is ec2.DescribeImagesInput with the pagination
+// parameters, which aren't supported by the vendored SDK.
+type describeImagesInput struct {
+	DryRun          *bool         `locationName:"dryRun" type:"boolean"`
+	ExecutableUsers []*string     `locationName:"ExecutableBy" locationNameList:"ExecutableBy" type:"list"`
+	Filters         []*ec2.Filter `locationName:"Filter" locationNameList:"Filter" type:"list"`
+	ImageIds        []*string     `locationName:"ImageId" locationNameList:"ImageId" type:"list"`
+	IncludeDisabled *bool         `type:"boolean"`
+	MaxResults      *int64        `type:"integer"`
+	NextToken       *string       `type:"string"`
+	Owners          []*string     `locationName:"Owner" locationNameList:"Owner" type:"list"`
+}
+
+// describeImagesOutput is ec2.DescribeImagesOutput with the pagination token.
+// Deprecations are unmarshaled from the same images, as ec2.Image of the
+// vendored SDK doesn't have a deprecation time.
+type describeImagesOutput struct {
+	Images       []*ec2.Image        `locationName:"imagesSet" locationNameList:"item" type:"list"`
+	Deprecations []*imageDeprecation `locationName:"imagesSet" locationNameList:"item" type:"list"`
+	NextToken    *string             `locationName:"nextToken" type:"string"`
+}
+
+type imageDeprecation struct {
+	ImageId         *string `locationName:"imageId" type:"string"`
+	DeprecationTime *string `locationName:"deprecationTime" type:"string"`
+}
+
+// describeImages returns all images of a region matching the given input and
+// the deprecation times of the images, which are scheduled for deprecation.
+// The images are fetched page by page, unless image ids are given, which
+// can't be combined with pagination.
+func describeImages(ctx context.Context, svc *ec2.EC2, input *ec2.DescribeImagesInput) ([]*ec2.Image, map[string]time.Time, error) {
+	pageInput := &describeImagesInput{
+		DryRun:          input.DryRun,
+		ExecutableUsers: input.ExecutableUsers,
+		Filters:         input.Filters,
+		ImageIds:        input.ImageIds,
+		Owners:          input.Owners,
+	}
+
+	if len(input.ImageIds) == 0 {
+		pageInput.MaxResults = awsclient.Int64(describeImagesPageSize)
+	}
+
+	return describeImagePages(ctx, svc, pageInput)
+}
+
+// describeImagePages returns the images of all pages for the given input,
+// which is modified to request the following pages.
+func describeImagePages(ctx context.Context, svc *ec2.EC2, pageInput *describeImagesInput) ([]*ec2.Image, map[string]time.Time, error) {
+	var images []*ec2.Image
+	deprecations := make(map[string]time.Time)
+	for {
+		resp := &describeImagesOutput{}
+		req := newRequest(svc, "DescribeImages", pageInput, resp)
+		if err := send(ctx, req); err != nil {
+			return nil, nil, err
+		}
+
+		images = append(images, resp.Images...)
+
+		for _, d := range resp.Deprecations {
+			if d.DeprecationTime == nil {
+				continue
+			}
+
+			t, err := time.Parse(time.RFC3339, *d.DeprecationTime)
+			if err != nil {
+				continue
+			}
+			deprecations[awsclient.StringValue(d.ImageId)] = t
+		}
+
+		if awsclient.StringValue(resp.NextToken) == "" {
+			return images, deprecations, nil
+		}
+		pageInput.NextToken = resp.NextToken
+	}
+}
+
+// deprecationTimes are the deprecation times of the fetched images by their
+// ids. It's safe for concurrent use.
+type deprecationTimes struct {
+	mu    sync.Mutex
+	times map[string]time.Time
+}
+
+func (d *deprecationTimes) add(times map[string]time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.times == nil {
+		d.times = make(map[string]time.Time, len(times))
+	}
+
+	for id, t := range times {
+		d.times[id] = t
+	}
+}
+
+// set sets the deprecation time of the given images.
+func (d *deprecationTimes) set(images provider.Images) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, image := range images {
+		if t, ok := d.times[image.ID]; ok {
+			image.DeprecatedAt = &t
+		}
+	}
+}
+
+// filtersValue implements flag.Value for DescribeImages filters in the
+// format of the AWS CLI: "name=tag:env,values=prod,staging". Each flag adds
+// a filter, multiple filters can be separated by spaces as well.
+type filtersValue []*ec2.Filter
+
+func (f *filtersValue) String() string {
+	var filters []string
+	for _, filter := range *f {
+		filters = append(filters, fmt.Sprintf("name=%s,values=%s",
+			awsclient.StringValue(filter.Name),
+			strings.Join(awsclient.StringValueSlice(filter.Values), ",")))
+	}
+	return strings.Join(filters, " ")
+}
+
+func (f *filtersValue) Set(value string) error {
+	for _, field := range strings.Fields(value) {
+		filter := &ec2.Filter{}
+
+		inValues := false
+		for _, part := range strings.Split(field, ",") {
+			key, val := "", part
+			if i := strings.Index(part, "="); i != -1 {
+				key, val = strings.ToLower(part[:i]), part[i+1:]
+			}
+
+			switch {
+			case key == "name":
+				filter.Name = awsclient.String(val)
+				inValues = false
+			case key == "values":
+				filter.Values = append(filter.Values, awsclient.String(val))
+				inValues = true
+			case inValues:
+				// values can contain "=", only the keys are parsed
+				filter.Values = append(filter.Values, awsclient.String(part))
+			default:
+				return fmt.Errorf("invalid filter '%s', expected: name=...,values=...", field)
+			}
+		}
+
+		if awsclient.StringValue(filter.Name) == "" || len(filter.Values) == 0 {
+			return fmt.Errorf("invalid filter '%s', expected: name=...,values=...", field)
+		}
+
+		*f = append(*f, filter)
+	}
+
+	return nil
 }
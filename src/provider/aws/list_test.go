@@ -0,0 +1,77 @@
+package aws
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestFiltersValue(t *testing.T) {
+	var f filtersValue
+	if err := f.Set("name=tag:env,values=prod,staging name=tag:query,values=a=b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.Set("Name=state,Values=available"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "name=tag:env,values=prod,staging name=tag:query,values=a=b name=state,values=available"
+	if f.String() != want {
+		t.Errorf("filters are %q, want %q", f.String(), want)
+	}
+
+	for _, value := range []string{"name=tag:env", "values=prod", "tag:env=prod", "name=,values=prod"} {
+		var f filtersValue
+		if err := f.Set(value); err == nil {
+			t.Errorf("%s: no error, want an invalid filter", value)
+		}
+	}
+}
+
+func TestFetchListArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want map[string]string
+	}{
+		{
+			want: map[string]string{"Owner.1": "self", "MaxResults": "1000"},
+		},
+		{
+			args: []string{"-executable-users", "all"},
+			want: map[string]string{"Owner.1": "", "ExecutableBy.1": "all"},
+		},
+		{
+			args: []string{"-owners", "amazon", "-filters", "name=tag:env,values=prod,staging"},
+			want: map[string]string{
+				"Owner.1":          "amazon",
+				"Filter.1.Name":    "tag:env",
+				"Filter.1.Value.1": "prod",
+				"Filter.1.Value.2": "staging",
+			},
+		},
+		{
+			// image ids can't be combined with pagination
+			args: []string{"-ids", "ami-1"},
+			want: map[string]string{"ImageId.1": "ami-1", "MaxResults": ""},
+		},
+	}
+
+	for _, test := range tests {
+		f := newFakeEC2(t, func(r *ec2Request) (int, string) {
+			return 200, imagesResponse("ami-1", "available")
+		})
+
+		if _, err := newFakeAccounts(map[string]*fakeEC2{"": f}).Fetch(context.Background(), test.args); err != nil {
+			t.Errorf("%q: %s", test.args, err)
+		}
+
+		for key, want := range test.want {
+			if got := f.requests[0].form.Get(key); got != want {
+				t.Errorf("%q: %s is %q, want %q", test.args, key, got, want)
+			}
+		}
+
+		f.Close()
+	}
+}
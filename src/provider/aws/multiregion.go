@@ -1,49 +1,55 @@
 package aws
 
 import (
+	"provider/utils"
+
 	awsclient "github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 )
 
-var allRegions = []string{
-	"ap-northeast-1",
-	"ap-southeast-1",
-	"ap-southeast-2",
-	"eu-central-1",
-	"eu-west-1",
-	"sa-east-1",
-	"us-east-1",
-	"us-west-1",
-	"us-west-2",
-	// These are problematic for the first time, thus enable later. The user
-	// can always define manually and set explicitly if wished.
-	// "cn-north-1",
-	// "us-gov-west-1",
-}
-
 type multiRegion struct {
 	regions map[string]*ec2.EC2
+
+	conf      *awsclient.Config
+	retry     *utils.Retry
+	partition partition
 }
 
-func newMultiRegion(conf *awsclient.Config, regions []string) *multiRegion {
-	m := &multiRegion{
-		regions: make(map[string]*ec2.EC2, 0),
+func newMultiRegion(conf *awsclient.Config, retry *utils.Retry, p partition) *multiRegion {
+	return &multiRegion{
+		regions:   make(map[string]*ec2.EC2, 0),
+		conf:      conf,
+		retry:     retry,
+		partition: p,
 	}
+}
 
+// setRegions creates the services of the given regions.
+func (m *multiRegion) setRegions(regions []string) {
 	for _, region := range regions {
-		m.regions[region] = ec2.New(conf.Merge(&awsclient.Config{
-			Region: awsclient.String(region),
-		}))
+		m.regions[region] = m.newSvc(region)
 	}
-
-	return m
 }
 
-func filterRegions(regions, excludedRegions []string) []string {
-	if len(regions) == 1 && regions[0] == "all" {
-		regions = allRegions
+// newSvc returns a new *ec2.EC2 service for the given region.
+func (m *multiRegion) newSvc(region string) *ec2.EC2 {
+	conf := &awsclient.Config{Region: awsclient.String(region)}
+	if endpoint := m.partition.endpoint("ec2", region); endpoint != "" {
+		conf.Endpoint = awsclient.String(endpoint)
 	}
 
+	svc := ec2.New(m.conf.Merge(conf))
+	svc.Retryer = retryer{retry: m.retry}
+
+	return svc
+}
+
+// isAllRegions reports whether the regions are set to "all"
+func isAllRegions(regions []string) bool {
+	return len(regions) == 1 && regions[0] == "all"
+}
+
+func filterRegions(regions, excludedRegions []string) []string {
 	inExcluded := func(r string) bool {
 		for _, region := range excludedRegions {
 			if r == region {
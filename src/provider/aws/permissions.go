@@ -0,0 +1,137 @@
+package aws
+
+import (
+	"fmt"
+	"regexp"
+
+	awsclient "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"golang.org/x/net/context"
+)
+
+var (
+	// accountIDRegexp matches an AWS account id
+	accountIDRegexp = regexp.MustCompile(`^[0-9]{12}$`)
+
+	// organizationRegexp matches the ARN of an organization or an
+	// organizational unit, i.e:
+	// "arn:aws:organizations::123456789012:organization/o-abc" and
+	// "arn:aws:organizations::123456789012:ou/o-abc/ou-abc-def"
+	organizationRegexp = regexp.MustCompile(`^arn:[a-z-]+:organizations::[0-9]{12}:(organization|ou)/`)
+)
+
+// launchPermission is ec2.LaunchPermission with the organization and
+// organizational unit ARNs, which aren't supported by the vendored SDK.
+type launchPermission struct {
+	Group                 *string `locationName:"group" type:"string"`
+	OrganizationArn       *string `locationName:"organizationArn" type:"string"`
+	OrganizationalUnitArn *string `locationName:"organizationalUnitArn" type:"string"`
+	UserId                *string `locationName:"userId" type:"string"`
+}
+
+// String returns the launch permission in the form of "account:123",
+// "organization:arn:...", "ou:arn:..." or "group:all".
+func (l *launchPermission) String() string {
+	switch {
+	case l.UserId != nil:
+		return "account:" + awsclient.StringValue(l.UserId)
+	case l.OrganizationArn != nil:
+		return "organization:" + awsclient.StringValue(l.OrganizationArn)
+	case l.OrganizationalUnitArn != nil:
+		return "ou:" + awsclient.StringValue(l.OrganizationalUnitArn)
+	default:
+		return "group:" + awsclient.StringValue(l.Group)
+	}
+}
+
+type launchPermissionModifications struct {
+	Add    []*launchPermission `locationNameList:"item" type:"list"`
+	Remove []*launchPermission `locationNameList:"item" type:"list"`
+}
+
+// modifyImageAttributeInput is ec2.ModifyImageAttributeInput with the
+// launchPermission type of this package.
+type modifyImageAttributeInput struct {
+	Attribute        *string                        `type:"string"`
+	DryRun           *bool                          `locationName:"dryRun" type:"boolean"`
+	ImageId          *string                        `type:"string" required:"true"`
+	LaunchPermission *launchPermissionModifications `type:"structure"`
+}
+
+type describeImageAttributeOutput struct {
+	LaunchPermissions []*launchPermission `locationName:"launchPermission" locationNameList:"item" type:"list"`
+}
+
+// publicPermission is the launch permission of public AMIs
+var publicPermission = &launchPermission{Group: awsclient.String("all")}
+
+// parseLaunchPermissions returns the launch permissions of the given account
+// ids and organization or organizational unit ARNs.
+func parseLaunchPermissions(values []string) ([]*launchPermission, error) {
+	perms := make([]*launchPermission, 0, len(values))
+	for _, value := range values {
+		switch {
+		case accountIDRegexp.MatchString(value):
+			perms = append(perms, &launchPermission{UserId: awsclient.String(value)})
+		case organizationRegexp.MatchString(value):
+			perm := &launchPermission{}
+			if organizationRegexp.FindStringSubmatch(value)[1] == "ou" {
+				perm.OrganizationalUnitArn = awsclient.String(value)
+			} else {
+				perm.OrganizationArn = awsclient.String(value)
+			}
+			perms = append(perms, perm)
+		default:
+			return nil, fmt.Errorf("'%s' is neither an account id nor an organization or organizational unit ARN", value)
+		}
+	}
+
+	return perms, nil
+}
+
+// ModifyLaunchPermissions adds and removes the given launch permissions of
+// the given images. The permissions are changed with a single request for
+// each image.
+func (a *AwsImages) ModifyLaunchPermissions(ctx context.Context, add, remove []*launchPermission, dryRun bool, images ...string) error {
+	modify := func(ctx context.Context, svc *ec2.EC2, images []string) error {
+		for _, image := range images {
+			if err := modifyLaunchPermissions(ctx, svc, image, add, remove, dryRun); err != nil {
+				return fmt.Errorf("%s: %s", image, err)
+			}
+		}
+		return nil
+	}
+
+	return a.multiCall(ctx, modify, images...)
+}
+
+// modifyLaunchPermissions adds and removes the given launch permissions of a
+// single AMI.
+func modifyLaunchPermissions(ctx context.Context, svc *ec2.EC2, id string, add, remove []*launchPermission, dryRun bool) error {
+	req := newRequest(svc, "ModifyImageAttribute", &modifyImageAttributeInput{
+		Attribute: awsclient.String("launchPermission"),
+		DryRun:    awsclient.Bool(dryRun),
+		ImageId:   awsclient.String(id),
+		LaunchPermission: &launchPermissionModifications{
+			Add:    add,
+			Remove: remove,
+		},
+	}, &ec2.ModifyImageAttributeOutput{})
+
+	return send(ctx, req)
+}
+
+// describeLaunchPermissions returns the launch permissions of the given AMI
+func describeLaunchPermissions(ctx context.Context, svc *ec2.EC2, id string) ([]*launchPermission, error) {
+	resp := &describeImageAttributeOutput{}
+	req := newRequest(svc, "DescribeImageAttribute", &ec2.DescribeImageAttributeInput{
+		Attribute: awsclient.String("launchPermission"),
+		ImageId:   awsclient.String(id),
+	}, resp)
+
+	if err := send(ctx, req); err != nil {
+		return nil, err
+	}
+
+	return resp.LaunchPermissions, nil
+}
@@ -0,0 +1,101 @@
+package aws
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+const modifyImageAttributeResponse = `<ModifyImageAttributeResponse><return>true</return></ModifyImageAttributeResponse>`
+
+func TestParseLaunchPermissions(t *testing.T) {
+	perms, err := parseLaunchPermissions([]string{
+		"123456789012",
+		"arn:aws:organizations::123456789012:organization/o-123",
+		"arn:aws:organizations::123456789012:ou/o-123/ou-456",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, perm := range perms {
+		got = append(got, perm.String())
+	}
+
+	want := "account:123456789012," +
+		"organization:arn:aws:organizations::123456789012:organization/o-123," +
+		"ou:arn:aws:organizations::123456789012:ou/o-123/ou-456"
+	if strings.Join(got, ",") != want {
+		t.Errorf("permissions are %s, want %s", strings.Join(got, ","), want)
+	}
+
+	for _, value := range []string{"12345", "all", "arn:aws:iam::123456789012:root"} {
+		if _, err := parseLaunchPermissions([]string{value}); err == nil {
+			t.Errorf("%s: no error, want an invalid permission", value)
+		}
+	}
+}
+
+func TestModifyLaunchPermissions(t *testing.T) {
+	f := newFakeEC2(t, func(r *ec2Request) (int, string) {
+		if r.action != "ModifyImageAttribute" {
+			t.Errorf("unexpected action %s", r.action)
+			return 400, ec2Error("InvalidAction")
+		}
+		return 200, modifyImageAttributeResponse
+	})
+	defer f.Close()
+
+	a := newFakeAccounts(map[string]*fakeEC2{"": f})
+	err := a.Modify(context.Background(), []string{
+		"-ids", "ami-1,ami-2",
+		"-share-with", "123456789012,arn:aws:organizations::123456789012:organization/o-123",
+		"-unshare", "arn:aws:organizations::123456789012:ou/o-123/ou-456",
+		"-make-public",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the permissions of each image are changed with a single request
+	if len(f.requests) != 2 {
+		t.Fatalf("actions are %q, want one request for each image", f.actions())
+	}
+
+	for i, r := range f.requests {
+		for key, want := range map[string]string{
+			"ImageId":                                         []string{"ami-1", "ami-2"}[i],
+			"Attribute":                                       "launchPermission",
+			"LaunchPermission.Add.1.UserId":                   "123456789012",
+			"LaunchPermission.Add.2.OrganizationArn":          "arn:aws:organizations::123456789012:organization/o-123",
+			"LaunchPermission.Add.3.Group":                    "all",
+			"LaunchPermission.Remove.1.OrganizationalUnitArn": "arn:aws:organizations::123456789012:ou/o-123/ou-456",
+		} {
+			if got := r.form.Get(key); got != want {
+				t.Errorf("request %d: %s is %q, want %q", i, key, got, want)
+			}
+		}
+	}
+}
+
+func TestModifyLaunchPermissionsConflicts(t *testing.T) {
+	f := newFakeEC2(t, func(r *ec2Request) (int, string) {
+		t.Errorf("unexpected action %s", r.action)
+		return 400, ec2Error("InvalidAction")
+	})
+	defer f.Close()
+
+	a := newFakeAccounts(map[string]*fakeEC2{"": f})
+
+	for _, args := range [][]string{
+		{"-ids", "ami-1", "-make-public", "-make-private"},
+		{"-ids", "ami-1", "-share-with", "me"},
+		{"-ids", "ami-1", "-unshare", "123"},
+	} {
+		if err := a.Modify(context.Background(), args); err == nil {
+			t.Errorf("%q: no error, want an invalid modification", args)
+		}
+	}
+}
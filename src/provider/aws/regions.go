@@ -0,0 +1,129 @@
+package aws
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	awsclient "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// regionsCacheTTL is the duration the discovered regions are cached for.
+const regionsCacheTTL = 24 * time.Hour
+
+// partition is a group of AWS regions, such as the standard regions, the
+// GovCloud or the China regions. Credentials are only valid in one partition.
+type partition struct {
+	// region is used to discover the regions and assume roles
+	region string
+
+	// dnsSuffix is the suffix of the endpoints of the regions
+	dnsSuffix string
+}
+
+var partitions = map[string]partition{
+	"aws":        {region: "us-east-1", dnsSuffix: "amazonaws.com"},
+	"aws-us-gov": {region: "us-gov-west-1", dnsSuffix: "amazonaws.com"},
+	"aws-cn":     {region: "cn-north-1", dnsSuffix: "amazonaws.com.cn"},
+}
+
+// endpoint returns the endpoint of the given service and region. It's empty
+// for the standard DNS suffix, which is resolved by the SDK.
+func (p partition) endpoint(service, region string) string {
+	if p.dnsSuffix == partitions["aws"].dnsSuffix {
+		return ""
+	}
+	return fmt.Sprintf("https://%s.%s.%s", service, region, p.dnsSuffix)
+}
+
+// discoverRegions returns the regions which are enabled for the account,
+// regions which require an opt-in are only returned after opting in. The
+// result is cached for each partition and credentials in the user cache
+// directory, so only the first command calls the API.
+func (m *multiRegion) discoverRegions(name string, p partition, identity ...string) ([]string, error) {
+	cachePath := regionsCachePath(name, identity...)
+	if regions, ok := readRegionsCache(cachePath); ok {
+		return regions, nil
+	}
+
+	resp, err := m.newSvc(p.region).DescribeRegions(&ec2.DescribeRegionsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("discovering the regions of partition '%s' failed: %s", name, err)
+	}
+
+	regions := make([]string, 0, len(resp.Regions))
+	for _, region := range resp.Regions {
+		regions = append(regions, awsclient.StringValue(region.RegionName))
+	}
+	sort.Strings(regions)
+
+	writeRegionsCache(cachePath, regions)
+	return regions, nil
+}
+
+// regionsCachePath returns the cache file of the given partition. The
+// identity (i.e. the profile) is part of the name, as the enabled regions
+// differ between accounts.
+func regionsCachePath(partition string, identity ...string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+
+	h := sha1.New()
+	for _, s := range identity {
+		fmt.Fprintf(h, "%s\x00", s)
+	}
+
+	name := fmt.Sprintf("aws-regions-%s-%x.json", partition, h.Sum(nil)[:8])
+	return filepath.Join(dir, "images", name)
+}
+
+// readRegionsCache returns the cached regions if the cache isn't expired.
+func readRegionsCache(path string) ([]string, bool) {
+	if path == "" {
+		return nil, false
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil || time.Since(fi.ModTime()) > regionsCacheTTL {
+		return nil, false
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	var regions []string
+	if err := json.Unmarshal(data, &regions); err != nil || len(regions) == 0 {
+		return nil, false
+	}
+
+	return regions, true
+}
+
+// writeRegionsCache caches the regions. Failures are ignored, the regions
+// are discovered again with the next command.
+func writeRegionsCache(path string, regions []string) {
+	if path == "" {
+		return
+	}
+
+	data, err := json.Marshal(regions)
+	if err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+
+	ioutil.WriteFile(path, data, 0644)
+}
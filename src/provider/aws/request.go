@@ -0,0 +1,24 @@
+package aws
+
+import (
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// apiVersion is the EC2 API version of requests, which use parameters that
+// aren't supported by the API version of the vendored SDK ("2015-04-15").
+const apiVersion = "2016-11-15"
+
+// newRequest returns a request for the given EC2 operation with the newer API
+// version. input is serialized with the same struct tags as the SDK types and
+// the response is unmarshaled into output.
+func newRequest(svc *ec2.EC2, operation string, input, output interface{}) *request.Request {
+	req := svc.NewRequest(&request.Operation{
+		Name:       operation,
+		HTTPMethod: "POST",
+		HTTPPath:   "/",
+	}, input, output)
+
+	req.Service.APIVersion = apiVersion
+	return req
+}
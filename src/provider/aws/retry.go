@@ -0,0 +1,90 @@
+package aws
+
+import (
+	"net/http"
+	"time"
+
+	"provider/utils"
+
+	awsclient "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"golang.org/x/net/context"
+)
+
+// throttlingCodes are the error codes AWS returns for rate limited requests
+var throttlingCodes = map[string]bool{
+	"Throttling":           true,
+	"ThrottlingException":  true,
+	"RequestLimitExceeded": true,
+	"RequestThrottled":     true,
+}
+
+// retryer implements the request.Retryer interface with the backoff shared by
+// all providers.
+type retryer struct {
+	retry *utils.Retry
+}
+
+// MaxRetries implements the request.Retryer interface
+func (r retryer) MaxRetries() uint { return uint(r.retry.MaxRetries) }
+
+// ShouldRetry implements the request.Retryer interface. Server errors,
+// throttled requests and the retryable error codes of the SDK are retried.
+func (r retryer) ShouldRetry(req *request.Request) bool {
+	if req.HTTPResponse != nil {
+		code := req.HTTPResponse.StatusCode
+		if code >= 500 || code == http.StatusTooManyRequests {
+			return true
+		}
+	}
+
+	return req.IsErrorRetryable()
+}
+
+// RetryRules implements the request.Retryer interface
+func (r retryer) RetryRules(req *request.Request) time.Duration {
+	return r.retry.Delay(int(req.RetryCount), utils.RetryAfter(req.HTTPResponse), isThrottled(req))
+}
+
+// isThrottled returns whether the given request was rate limited
+func isThrottled(req *request.Request) bool {
+	if req.HTTPResponse != nil && req.HTTPResponse.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+
+	if err, ok := req.Error.(awserr.Error); ok {
+		return throttlingCodes[err.Code()]
+	}
+
+	return false
+}
+
+// afterRetry replaces the AfterRetry handler of the SDK, which blocks during
+// the delay before a retry, with one that stops waiting once ctx is done.
+func afterRetry(ctx context.Context) func(*request.Request) {
+	return func(r *request.Request) {
+		if r.Retryable == nil {
+			r.Retryable = awsclient.Bool(r.ShouldRetry(r))
+		}
+
+		if !r.WillRetry() {
+			return
+		}
+
+		r.RetryDelay = r.RetryRules(r)
+		if err := utils.Sleep(ctx, r.RetryDelay); err != nil {
+			r.Retryable = awsclient.Bool(false)
+			r.Error = err
+			return
+		}
+
+		// expired credentials are refreshed with the next attempt
+		if r.IsErrorExpired() {
+			r.Service.Config.Credentials.Expire()
+		}
+
+		r.RetryCount++
+		r.Error = nil
+	}
+}
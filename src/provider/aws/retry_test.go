@@ -0,0 +1,121 @@
+package aws
+
+import (
+	"sync"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestRetryer(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []int
+		codes     []string
+		retries   int
+		err       bool
+		attempts  int
+		throttled int
+	}{
+		{
+			name:      "server error",
+			responses: []int{500, 503, 200},
+			codes:     []string{"InternalError", "Unavailable", ""},
+			retries:   5,
+			attempts:  3,
+		},
+		{
+			name:      "throttled",
+			responses: []int{503, 400, 200},
+			codes:     []string{"RequestLimitExceeded", "Throttling", ""},
+			retries:   5,
+			attempts:  3,
+			throttled: 2,
+		},
+		{
+			name:      "too many requests",
+			responses: []int{429, 200},
+			codes:     []string{"TooManyRequests", ""},
+			retries:   5,
+			attempts:  2,
+			throttled: 1,
+		},
+		{
+			name:      "max retries",
+			responses: []int{500, 500, 200},
+			codes:     []string{"InternalError", "InternalError", ""},
+			retries:   1,
+			err:       true,
+			attempts:  2,
+		},
+		{
+			name:      "client error",
+			responses: []int{400, 200},
+			codes:     []string{"InvalidParameterValue", ""},
+			retries:   5,
+			err:       true,
+			attempts:  1,
+		},
+	}
+
+	for _, test := range tests {
+		var (
+			mu       sync.Mutex
+			attempts int
+		)
+
+		f := newFakeEC2(t, func(r *ec2Request) (int, string) {
+			mu.Lock()
+			i := attempts
+			attempts++
+			mu.Unlock()
+
+			if test.codes[i] != "" {
+				return test.responses[i], ec2Error(test.codes[i])
+			}
+			return test.responses[i], imagesResponse("ami-1", "available")
+		})
+
+		a := f.retryImages(test.retries, "us-east-1")
+		images, err := a.describeRegion(context.Background(), "us-east-1", []string{"ami-1"})
+		f.Close()
+
+		if test.err != (err != nil) {
+			t.Errorf("%s: error is %v, want an error: %t", test.name, err, test.err)
+		}
+
+		if !test.err && len(images) != 1 {
+			t.Errorf("%s: described %d images, want 1", test.name, len(images))
+		}
+
+		if attempts != test.attempts {
+			t.Errorf("%s: sent %d requests, want %d", test.name, attempts, test.attempts)
+		}
+
+		if total, throttled := a.Retries(); total != test.attempts-1 || throttled != test.throttled {
+			t.Errorf("%s: retries are %d, %d throttled, want %d, %d throttled",
+				test.name, total, throttled, test.attempts-1, test.throttled)
+		}
+	}
+}
+
+func TestRetryerCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var attempts int
+	f := newFakeEC2(t, func(r *ec2Request) (int, string) {
+		attempts++
+		cancel()
+		return 503, ec2Error("Unavailable")
+	})
+	defer f.Close()
+
+	a := f.retryImages(5, "us-east-1")
+	if _, err := a.describeRegion(ctx, "us-east-1", []string{"ami-1"}); err == nil {
+		t.Fatal("expected an error for a canceled request")
+	}
+
+	if attempts != 1 {
+		t.Errorf("sent %d requests after the cancellation, want 1", attempts)
+	}
+}
@@ -0,0 +1,136 @@
+package aws
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"provider"
+
+	awsclient "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"golang.org/x/net/context"
+)
+
+// Describe returns all attributes of the given AMI, including its block device
+// mappings and launch permissions.
+func (a *AwsImages) Describe(ctx context.Context, image *provider.Image) (*provider.Details, error) {
+	raw, ok := image.Raw.(*ec2.Image)
+	if !ok {
+		return nil, fmt.Errorf("image '%s' is not an AMI", image.ID)
+	}
+
+	d := provider.NewDetails(image)
+	d.Add("Description", awsclient.StringValue(raw.Description))
+	d.Add("Architecture", awsclient.StringValue(raw.Architecture))
+	d.Add("Virtualization", awsclient.StringValue(raw.VirtualizationType))
+	d.Add("Hypervisor", awsclient.StringValue(raw.Hypervisor))
+	d.Add("Image type", awsclient.StringValue(raw.ImageType))
+	d.Add("Platform", awsclient.StringValue(raw.Platform))
+	d.Add("Owner", strings.TrimSpace(awsclient.StringValue(raw.OwnerId)+" "+awsclient.StringValue(raw.ImageOwnerAlias)))
+	d.Add("Public", strconv.FormatBool(awsclient.BoolValue(raw.Public)))
+	d.Add("Location", awsclient.StringValue(raw.ImageLocation))
+	d.Add("Kernel", awsclient.StringValue(raw.KernelId))
+	d.Add("Ramdisk", awsclient.StringValue(raw.RamdiskId))
+	d.Add("SR-IOV", awsclient.StringValue(raw.SriovNetSupport))
+	if raw.RootDeviceName != nil {
+		d.Add("Root device", awsclient.StringValue(raw.RootDeviceName)+" ("+awsclient.StringValue(raw.RootDeviceType)+")")
+	}
+
+	if raw.StateReason != nil {
+		d.Add("State reason", awsclient.StringValue(raw.StateReason.Message))
+	}
+
+	if image.DeprecatedAt != nil {
+		d.Add("Deprecated at", image.DeprecatedAt.Format(time.RFC3339))
+	}
+
+	devices := make([]string, 0, len(raw.BlockDeviceMappings))
+	for _, device := range raw.BlockDeviceMappings {
+		devices = append(devices, blockDevice(device))
+	}
+	d.Add("Block devices", devices...)
+
+	for _, code := range raw.ProductCodes {
+		d.Add("Product code", awsclient.StringValue(code.ProductCodeId)+" ("+awsclient.StringValue(code.ProductCodeType)+")")
+	}
+
+	perms, err := a.launchPermissions(ctx, image.Region, image.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(perms) == 0 {
+		perms = []string{"none (private)"}
+	}
+	d.Add("Launch permissions", perms...)
+
+	return d, nil
+}
+
+// launchPermissions returns the launch permissions of the given AMI. The
+// group "all" means the AMI is public.
+func (a *AwsImages) launchPermissions(ctx context.Context, region, id string) ([]string, error) {
+	svc, err := a.svcFromRegion(region)
+	if err != nil {
+		return nil, err
+	}
+
+	launchPerms, err := describeLaunchPermissions(ctx, svc, id)
+	if err != nil {
+		return nil, err
+	}
+
+	perms := make([]string, 0, len(launchPerms))
+	for _, perm := range launchPerms {
+		perms = append(perms, perm.String())
+	}
+
+	return perms, nil
+}
+
+// blockDevice returns a single line description of the given block device
+// mapping, i.e: "/dev/sda1 snap-123 8GB gp2 delete-on-termination"
+func blockDevice(device *ec2.BlockDeviceMapping) string {
+	fields := []string{awsclient.StringValue(device.DeviceName)}
+
+	if device.VirtualName != nil {
+		fields = append(fields, awsclient.StringValue(device.VirtualName))
+	}
+
+	if device.NoDevice != nil {
+		fields = append(fields, "no-device")
+	}
+
+	if ebs := device.Ebs; ebs != nil {
+		fields = append(fields, awsclient.StringValue(ebs.SnapshotId))
+
+		if ebs.VolumeSize != nil {
+			fields = append(fields, strconv.FormatInt(*ebs.VolumeSize, 10)+"GB")
+		}
+
+		fields = append(fields, awsclient.StringValue(ebs.VolumeType))
+
+		if ebs.Iops != nil {
+			fields = append(fields, strconv.FormatInt(*ebs.Iops, 10)+" iops")
+		}
+
+		if awsclient.BoolValue(ebs.Encrypted) {
+			fields = append(fields, "encrypted")
+		}
+
+		if awsclient.BoolValue(ebs.DeleteOnTermination) {
+			fields = append(fields, "delete-on-termination")
+		}
+	}
+
+	nonEmpty := fields[:0]
+	for _, f := range fields {
+		if f != "" {
+			nonEmpty = append(nonEmpty, f)
+		}
+	}
+
+	return strings.Join(nonEmpty, " ")
+}
@@ -0,0 +1,189 @@
+package aws
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+
+	"provider"
+	"provider/utils"
+
+	awsclient "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/hashicorp/go-multierror"
+	"golang.org/x/net/context"
+)
+
+// amiRegexp matches the AMI of a snapshot in its description. EC2 adds it to
+// the snapshots created by CreateImage ("Created by CreateImage(i-123) for
+// ami-123 from vol-123") and CopyImage ("Copied for DestinationAmi ami-123
+// from SourceAmi ami-456 ..."), the first AMI is always the one the snapshot
+// belongs to.
+var amiRegexp = regexp.MustCompile(`ami-[0-9a-f]+`)
+
+// Orphans returns the EBS snapshots of all regions which were
+// created for an AMI, which doesn't exist anymore, and aren't used by any
+// other AMI. Snapshots which don't belong to an AMI, such as backups of
+// volumes, are never orphaned.
+func (a *AwsImages) Orphans(ctx context.Context) (provider.Snapshots, error) {
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex // protects the fields below
+
+		snapshots   provider.Snapshots
+		multiErrors error
+	)
+
+	regions := make([]string, 0, len(a.services.regions))
+	for r := range a.services.regions {
+		regions = append(regions, r)
+	}
+	sort.Strings(regions)
+
+	tracker := utils.NewTracker(regions...)
+
+	for r, s := range a.services.regions {
+		wg.Add(1)
+		go func(region string, svc *ec2.EC2) {
+			defer wg.Done()
+
+			orphans, err := regionOrphans(ctx, region, svc)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				multiErrors = multierror.Append(multiErrors, fmt.Errorf("%s: %s", region, err))
+				return
+			}
+
+			snapshots = append(snapshots, orphans...)
+			tracker.Done(region)
+		}(r, s)
+	}
+
+	wg.Wait()
+
+	return snapshots, tracker.Err(ctx, multiErrors)
+}
+
+// regionOrphans returns the orphaned snapshots of a single region. All pages
+// of the images, including disabled ones, are looked up first. If any of them
+// fails, no snapshot is reported, a snapshot of an image which wasn't seen
+// would look orphaned.
+func regionOrphans(ctx context.Context, region string, svc *ec2.EC2) (provider.Snapshots, error) {
+	ownImages, _, err := describeImagePages(ctx, svc, &describeImagesInput{
+		Owners:          stringSlice("self"),
+		IncludeDisabled: awsclient.Bool(true),
+		MaxResults:      awsclient.Int64(describeImagesPageSize),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("looking up the images: %s", err)
+	}
+
+	images := make(map[string]bool, len(ownImages))
+	used := make(map[string]bool)
+	for _, image := range ownImages {
+		if image.ImageId == nil {
+			return nil, errors.New("looking up the images: image without an id")
+		}
+		images[*image.ImageId] = true
+
+		for _, device := range image.BlockDeviceMappings {
+			if device.Ebs != nil && device.Ebs.SnapshotId != nil {
+				used[*device.Ebs.SnapshotId] = true
+			}
+		}
+	}
+
+	var (
+		orphans   provider.Snapshots
+		nextToken *string
+	)
+
+	for {
+		req, resp := svc.DescribeSnapshotsRequest(&ec2.DescribeSnapshotsInput{
+			OwnerIds:   stringSlice("self"),
+			MaxResults: awsclient.Int64(1000),
+			NextToken:  nextToken,
+		})
+		if err := send(ctx, req); err != nil {
+			return nil, err
+		}
+
+		for _, snapshot := range resp.Snapshots {
+			id := awsclient.StringValue(snapshot.SnapshotId)
+			ami := amiRegexp.FindString(awsclient.StringValue(snapshot.Description))
+			if ami == "" || images[ami] || used[id] {
+				continue
+			}
+
+			orphan := &provider.Snapshot{
+				Provider: "aws",
+				Region:   region,
+				ID:       id,
+				Source:   ami,
+				Size:     awsclient.Int64Value(snapshot.VolumeSize),
+				Raw:      snapshot,
+			}
+
+			if snapshot.StartTime != nil {
+				orphan.CreatedAt = *snapshot.StartTime
+			}
+
+			for _, tag := range snapshot.Tags {
+				if awsclient.StringValue(tag.Key) == "Name" {
+					orphan.Name = awsclient.StringValue(tag.Value)
+				}
+			}
+
+			orphans = append(orphans, orphan)
+		}
+
+		if awsclient.StringValue(resp.NextToken) == "" {
+			return orphans, nil
+		}
+		nextToken = resp.NextToken
+	}
+}
+
+// DeleteSnapshots deletes the given EBS snapshots. If ctx is done before all
+// snapshots are deleted, the returned error reports the deleted snapshots.
+func (a *AwsImages) DeleteSnapshots(ctx context.Context, snapshots provider.Snapshots) error {
+	var (
+		mu          sync.Mutex // protects multiErrors
+		multiErrors error
+	)
+
+	ids := make([]string, len(snapshots))
+	for i, snapshot := range snapshots {
+		ids[i] = snapshot.ID
+	}
+
+	tracker := utils.NewTracker(ids...)
+
+	utils.ForEach(ctx, utils.Parallelism(ctx, a.parallelism), len(snapshots), func(i int) {
+		snapshot := snapshots[i]
+
+		svc, err := a.svcFromRegion(snapshot.Region)
+		if err == nil {
+			req, _ := svc.DeleteSnapshotRequest(&ec2.DeleteSnapshotInput{
+				SnapshotId: awsclient.String(snapshot.ID),
+			})
+			err = send(ctx, req)
+		}
+
+		if err != nil {
+			mu.Lock()
+			multiErrors = multierror.Append(multiErrors, fmt.Errorf("%s: %s", snapshot.ID, err))
+			mu.Unlock()
+			return
+		}
+
+		tracker.Done(snapshot.ID)
+	})
+
+	return tracker.Err(ctx, multiErrors)
+}
@@ -0,0 +1,117 @@
+package aws
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+// imagePage returns a DescribeImages response with images, which use the
+// given snapshots, and the token of the next page.
+func imagePage(nextToken string, imageSnapshots ...string) string {
+	body := `<DescribeImagesResponse><imagesSet>`
+	for i := 0; i+1 < len(imageSnapshots); i += 2 {
+		body += `<item><imageId>` + imageSnapshots[i] + `</imageId><blockDeviceMapping><item>` +
+			`<deviceName>/dev/sda1</deviceName><ebs><snapshotId>` + imageSnapshots[i+1] +
+			`</snapshotId></ebs></item></blockDeviceMapping></item>`
+	}
+	body += `</imagesSet>`
+	if nextToken != "" {
+		body += `<nextToken>` + nextToken + `</nextToken>`
+	}
+	return body + `</DescribeImagesResponse>`
+}
+
+// snapshotsResponse returns a DescribeSnapshots response with snapshots in
+// the form of id and description pairs.
+func snapshotsResponse(idDescriptions ...string) string {
+	body := `<DescribeSnapshotsResponse><snapshotSet>`
+	for i := 0; i+1 < len(idDescriptions); i += 2 {
+		body += `<item><snapshotId>` + idDescriptions[i] + `</snapshotId><description>` +
+			idDescriptions[i+1] + `</description><volumeSize>8</volumeSize></item>`
+	}
+	return body + `</snapshotSet></DescribeSnapshotsResponse>`
+}
+
+func TestOrphans(t *testing.T) {
+	f := newFakeEC2(t, func(r *ec2Request) (int, string) {
+		switch r.action {
+		case "DescribeImages":
+			if r.form.Get("IncludeDisabled") != "true" {
+				t.Errorf("disabled images are not included: %v", r.form)
+			}
+
+			if r.form.Get("NextToken") == "" {
+				return 200, imagePage("page2", "ami-1", "snap-1")
+			}
+			return 200, imagePage("", "ami-2", "snap-2", "ami-3", "snap-copied")
+		case "DescribeSnapshots":
+			return 200, snapshotsResponse(
+				"snap-1", "Created by CreateImage(i-1) for ami-1 from vol-1",
+				"snap-2", "Created by CreateImage(i-2) for ami-2 from vol-2",
+				"snap-copied", "Copied for DestinationAmi ami-9 from SourceAmi ami-1",
+				"snap-orphan", "Created by CreateImage(i-4) for ami-4 from vol-4",
+				"snap-backup", "daily backup of vol-5",
+			)
+		}
+
+		t.Errorf("unexpected action %s", r.action)
+		return 400, ec2Error("InvalidAction")
+	})
+	defer f.Close()
+
+	snapshots, err := f.images("us-east-1").Orphans(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(snapshots) != 1 || snapshots[0].ID != "snap-orphan" || snapshots[0].Source != "ami-4" {
+		t.Fatalf("orphans are %v, want only snap-orphan of ami-4", snapshots)
+	}
+
+	if s := snapshots[0]; s.Region != "us-east-1" || s.Size != 8 {
+		t.Errorf("orphan is in %q with %dGB, want us-east-1 with 8GB", s.Region, s.Size)
+	}
+}
+
+func TestOrphansIncompleteImages(t *testing.T) {
+	f := newFakeEC2(t, func(r *ec2Request) (int, string) {
+		switch {
+		case r.action == "DescribeImages" && r.region == "eu-west-1" && r.form.Get("NextToken") != "":
+			return 400, ec2Error("InvalidParameterValue")
+		case r.action == "DescribeImages" && r.form.Get("NextToken") == "":
+			return 200, imagePage("page2", "ami-1", "snap-1")
+		case r.action == "DescribeImages":
+			return 200, imagePage("", "ami-2", "snap-2")
+		case r.action == "DescribeSnapshots":
+			return 200, snapshotsResponse("snap-orphan", "Created by CreateImage(i-4) for ami-4 from vol-4")
+		}
+
+		return 400, ec2Error("InvalidAction")
+	})
+	defer f.Close()
+
+	// the failed region doesn't report any snapshot
+	snapshots, err := f.images("eu-west-1", "us-east-1").Orphans(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "eu-west-1: looking up the images") {
+		t.Errorf("error is %v, want a failed image lookup in eu-west-1", err)
+	}
+
+	if len(snapshots) != 1 || snapshots[0].Region != "us-east-1" {
+		t.Errorf("orphans are %v, want only the one of us-east-1", snapshots)
+	}
+
+	var snapshotRegions []string
+	for _, r := range f.requests {
+		if r.action == "DescribeSnapshots" {
+			snapshotRegions = append(snapshotRegions, r.region)
+		}
+	}
+	sort.Strings(snapshotRegions)
+
+	if len(snapshotRegions) != 1 || snapshotRegions[0] != "us-east-1" {
+		t.Errorf("snapshots are described in %v, want only us-east-1", snapshotRegions)
+	}
+}
@@ -10,14 +10,22 @@ import (
 	awsclient "github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/fatih/flags"
+	"golang.org/x/net/context"
 )
 
 type modifyFlags struct {
-	createTags string
-	deleteTags string
-	imageIds   []string
-	dryRun     bool
-	helpMsg    string
+	createTags  string
+	deleteTags  string
+	shareWith   []string
+	unshare     []string
+	makePublic  bool
+	makePrivate bool
+	description string
+	deprecateAt string
+	undeprecate bool
+	imageIds    []string
+	dryRun      bool
+	helpMsg     string
 
 	flagSet *flag.FlagSet
 }
@@ -29,6 +37,13 @@ func newModifyFlags() *modifyFlags {
 	flagSet.StringVar(&m.createTags, "create-tags", "", "Create  or override tags")
 	flagSet.StringVar(&m.deleteTags, "delete-tags", "", "Delete tags")
 	flagSet.Var(flags.NewStringSlice(nil, &m.imageIds), "ids", "Images to be delete with actions")
+	flagSet.Var(flags.NewStringSlice(nil, &m.shareWith), "share-with", "Share with accounts or organizations")
+	flagSet.Var(flags.NewStringSlice(nil, &m.unshare), "unshare", "Unshare with accounts or organizations")
+	flagSet.BoolVar(&m.makePublic, "make-public", false, "Make the images public")
+	flagSet.BoolVar(&m.makePrivate, "make-private", false, "Make the images private")
+	flagSet.StringVar(&m.description, "description", "", "Set the description")
+	flagSet.StringVar(&m.deprecateAt, "deprecate-at", "", "Schedule the deprecation")
+	flagSet.BoolVar(&m.undeprecate, "undeprecate", false, "Cancel the deprecation")
 	flagSet.BoolVar(&m.dryRun, "dry-run", false, "Don't run command, but show the action")
 	m.helpMsg = `Usage: images modify --providers aws [options]
 
@@ -39,10 +54,21 @@ Options:
   -ids         "ami-123,..."   Images to be used with below actions
   -create-tags "key=val,..."   Create or override tags
   -delete-tags "key,..."       Delete tags
+  -share-with  "123,..."       Grant launch permissions to accounts, organizations
+                               or organizational units (ids or ARNs)
+  -unshare     "123,..."       Revoke launch permissions of accounts, organizations
+                               or organizational units (ids or ARNs)
+  -make-public                 Grant launch permissions to everyone
+  -make-private                Revoke the public launch permission, shares with
+                               accounts and organizations are kept
+  -description "..."           Set the description
+  -deprecate-at "2030-01-01"   Schedule the deprecation, either at a date, a RFC3339
+                               time or after a duration from now, i.e: "90d"
+  -undeprecate                 Cancel the deprecation
   -dry-run                     Don't run command, but show the action
 `
 	flagSet.Usage = func() {
-		fmt.Fprintf(os.Stderr, m.helpMsg)
+		fmt.Fprint(os.Stderr, m.helpMsg)
 	}
 
 	flagSet.SetOutput(ioutil.Discard) // don't print anything without my permission
@@ -55,17 +81,17 @@ Options:
 // One or more tags. The value parameter is required, but if you don't want the
 // tag to have a value, specify the parameter with no value (i.e: "key3" or
 // "key4=" both works)
-func (a *AwsImages) CreateTags(tags string, dryRun bool, images ...string) error {
-	createTags := func(svc *ec2.EC2, images []string) error {
-		_, err := svc.CreateTags(&ec2.CreateTagsInput{
+func (a *AwsImages) CreateTags(ctx context.Context, tags string, dryRun bool, images ...string) error {
+	createTags := func(ctx context.Context, svc *ec2.EC2, images []string) error {
+		req, _ := svc.CreateTagsRequest(&ec2.CreateTagsInput{
 			Resources: stringSlice(images...),
 			Tags:      populateEC2Tags(tags, true),
 			DryRun:    awsclient.Bool(dryRun),
 		})
-		return err
+		return send(ctx, req)
 	}
 
-	return a.multiCall(createTags, images...)
+	return a.multiCall(ctx, createTags, images...)
 }
 
 // DeleteTags deletes the given tags for the given images. Tags is in the form
@@ -74,17 +100,17 @@ func (a *AwsImages) CreateTags(tags string, dryRun bool, images ...string) error
 // delete the tag regardless of its value. If you specify this parameter with
 // an empty string (i.e: "key4=" as the value, we delete the key only if its
 // value is an empty string.
-func (a *AwsImages) DeleteTags(tags string, dryRun bool, images ...string) error {
-	deleteTags := func(svc *ec2.EC2, images []string) error {
-		_, err := svc.DeleteTags(&ec2.DeleteTagsInput{
+func (a *AwsImages) DeleteTags(ctx context.Context, tags string, dryRun bool, images ...string) error {
+	deleteTags := func(ctx context.Context, svc *ec2.EC2, images []string) error {
+		req, _ := svc.DeleteTagsRequest(&ec2.DeleteTagsInput{
 			Resources: stringSlice(images...),
 			Tags:      populateEC2Tags(tags, false),
 			DryRun:    awsclient.Bool(dryRun),
 		})
-		return err
+		return send(ctx, req)
 	}
 
-	return a.multiCall(deleteTags, images...)
+	return a.multiCall(ctx, deleteTags, images...)
 }
 
 // populateEC2Tags returns a list of *ec2.Tag. tags is in the form of
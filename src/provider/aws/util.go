@@ -7,16 +7,23 @@ import (
 	"sync"
 	"time"
 
+	"provider/utils"
+
 	awsclient "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/hashicorp/go-multierror"
+	"golang.org/x/net/context"
 )
 
-type multiFunc func(svc *ec2.EC2, images []string) error
+type multiFunc func(ctx context.Context, svc *ec2.EC2, images []string) error
 
 // multiCall calls the given function concurrently for each region. It fetches
-// and matches the region to each image automatically.
-func (a *AwsImages) multiCall(fn multiFunc, images ...string) error {
+// and matches the region to each image automatically. If ctx is done before
+// all regions are finished, the returned error reports the finished images.
+func (a *AwsImages) multiCall(ctx context.Context, fn multiFunc, images ...string) error {
+	tracker := utils.NewTracker(images...)
+
 	// for one region just assume all image ids belong to the this region
 	// (which `list` returns already)
 	if len(a.services.regions) == 1 {
@@ -25,14 +32,18 @@ func (a *AwsImages) multiCall(fn multiFunc, images ...string) error {
 			return err
 		}
 
-		return fn(svc, images)
+		if err := fn(ctx, svc, images); err != nil {
+			return tracker.Err(ctx, err)
+		}
+
+		return nil
 	}
 
 	// so we have multiple regions, the given images might belong to different
 	// regions. Fetch all images and match each image id to the given region.
-	matchedImages, err := a.matchImages(images...)
+	matchedImages, err := a.matchImages(ctx, images...)
 	if err != nil {
-		return err
+		return tracker.Err(ctx, err)
 	}
 
 	var (
@@ -54,23 +65,25 @@ func (a *AwsImages) multiCall(fn multiFunc, images ...string) error {
 				return
 			}
 
-			if err := fn(svc, images); err != nil {
+			if err := fn(ctx, svc, images); err != nil {
 				mu.Lock()
 				multiErrors = multierror.Append(multiErrors, err)
 				mu.Unlock()
+				return
 			}
+
+			tracker.Done(images...)
 		}(r, i)
 	}
 
 	wg.Wait()
-	return multiErrors
-
+	return tracker.Err(ctx, multiErrors)
 }
 
 // matchImages matches the given images to their respective regions and returns
 // map of region to images.
-func (a *AwsImages) matchImages(images ...string) (map[string][]string, error) {
-	ownerImages, err := a.ownerImages()
+func (a *AwsImages) matchImages(ctx context.Context, images ...string) (map[string][]string, error) {
+	ownerImages, err := a.ownerImages(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -115,6 +128,23 @@ func (a *AwsImages) svcFromRegion(region string) (*ec2.EC2, error) {
 	return nil, fmt.Errorf("no svc found for region '%s'", region)
 }
 
+// send sends the given request. The request and all of its retries are
+// canceled once ctx is done, in which case the error of ctx is returned.
+func send(ctx context.Context, req *request.Request) error {
+	req.HTTPRequest = req.HTTPRequest.WithContext(ctx)
+	req.Handlers.Retry.PushBack(func(r *request.Request) {
+		if ctx.Err() != nil {
+			r.Retryable = awsclient.Bool(false)
+			r.Error = ctx.Err()
+		}
+	})
+
+	req.Handlers.AfterRetry.Clear()
+	req.Handlers.AfterRetry.PushBack(afterRetry(ctx))
+
+	return req.Send()
+}
+
 // byTime implements sort.Interface for []*ec2.Image based on the CreationDate field.
 type byTime []*ec2.Image
 
@@ -145,3 +175,13 @@ func stringSlice(vals ...string) []*string {
 
 	return a
 }
+
+// contains returns true if vals contains the given value
+func contains(vals []string, val string) bool {
+	for _, v := range vals {
+		if v == val {
+			return true
+		}
+	}
+	return false
+}
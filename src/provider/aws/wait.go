@@ -0,0 +1,192 @@
+package aws
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+
+	"provider/utils"
+
+	awsclient "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/fatih/flags"
+	"github.com/hashicorp/go-multierror"
+	"golang.org/x/net/context"
+)
+
+// failedStates are the states of AMIs which can't become available anymore
+var failedStates = map[string]bool{
+	ec2.ImageStateInvalid:      true,
+	ec2.ImageStateDeregistered: true,
+	ec2.ImageStateFailed:       true,
+	ec2.ImageStateError:        true,
+}
+
+type waitFlags struct {
+	imageIds []string
+	state    string
+
+	helpMsg string
+	flagSet *flag.FlagSet
+}
+
+func newWaitFlags() *waitFlags {
+	w := &waitFlags{}
+
+	flagSet := flag.NewFlagSet("wait", flag.ContinueOnError)
+	flagSet.Var(flags.NewStringSlice(nil, &w.imageIds), "ids", "Images to wait for with the given ids")
+	flagSet.StringVar(&w.state, "state", ec2.ImageStateAvailable, "State to wait for")
+	w.helpMsg = `Usage: images wait --providers aws [options]
+
+  Wait until AMI's reach a state
+
+Options:
+
+  -ids         "ami-123,..."   Images to wait for with the given ids
+  -state       "available"     State to wait for (default: "available")
+`
+	flagSet.Usage = func() {
+		fmt.Fprint(os.Stderr, w.helpMsg)
+	}
+
+	flagSet.SetOutput(ioutil.Discard) // don't print anything without my permission
+	w.flagSet = flagSet
+	return w
+}
+
+// WaitImages waits until all given images reach the given state and prints
+// the state of each image. It fails once an image fails. All regions are
+// polled for the images, so images which are not visible yet, i.e. right
+// after a copy, are pending until they show up in any region.
+func (a *AwsImages) WaitImages(ctx context.Context, state string, ids ...string) error {
+	switch state {
+	case ec2.ImageStatePending, ec2.ImageStateAvailable, ec2.ImageStateInvalid,
+		ec2.ImageStateDeregistered, ec2.ImageStateTransient, ec2.ImageStateFailed,
+		ec2.ImageStateError:
+	default:
+		return fmt.Errorf("invalid AMI state '%s'", state)
+	}
+
+	regions := make([]string, 0, len(a.services.regions))
+	for region := range a.services.regions {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+
+	progress := utils.NewProgress(os.Stdout, ids...)
+	tracker := utils.NewTracker(ids...)
+
+	pending := ids
+	err := utils.Poll(ctx, 0, func() (bool, error) {
+		found, err := a.describeRegions(ctx, regions, pending)
+		if err != nil {
+			return false, err
+		}
+
+		var remaining []string
+		for _, id := range pending {
+			image, ok := found[id]
+			if !ok {
+				// deregistered AMIs disappear after a while
+				if state == ec2.ImageStateDeregistered {
+					progress.Set(id, "%s", state)
+					tracker.Done(id)
+					continue
+				}
+
+				progress.Set(id, "not visible yet")
+				remaining = append(remaining, id)
+				continue
+			}
+
+			current := awsclient.StringValue(image.image.State)
+			progress.Set(id, "%s  %s", image.region, current)
+
+			if current == state {
+				tracker.Done(id)
+				continue
+			}
+
+			if failedStates[current] {
+				if r := image.image.StateReason; r != nil && awsclient.StringValue(r.Message) != "" {
+					return false, fmt.Errorf("%s is %s: %s", id, current, awsclient.StringValue(r.Message))
+				}
+				return false, fmt.Errorf("%s is %s", id, current)
+			}
+
+			remaining = append(remaining, id)
+		}
+
+		pending = remaining
+		return len(pending) == 0, nil
+	})
+
+	return tracker.Err(ctx, err)
+}
+
+// regionImage is an image together with its region
+type regionImage struct {
+	region string
+	image  *ec2.Image
+}
+
+// describeRegions describes the given images in all given regions
+// concurrently and returns the found images by their ids. Images which
+// don't exist in a region are left out, they are filtered by their ids
+// instead of passing the ids, which fails for unknown ids.
+func (a *AwsImages) describeRegions(ctx context.Context, regions, ids []string) (map[string]*regionImage, error) {
+	var (
+		mu          sync.Mutex // protects found and multiErrors
+		found       = make(map[string]*regionImage, len(ids))
+		multiErrors error
+	)
+
+	utils.ForEach(ctx, utils.Parallelism(ctx, a.parallelism), len(regions), func(i int) {
+		region := regions[i]
+		images, err := a.describeRegion(ctx, region, ids)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err != nil {
+			multiErrors = multierror.Append(multiErrors, fmt.Errorf("%s: %s", region, err))
+			return
+		}
+
+		for _, image := range images {
+			found[awsclient.StringValue(image.ImageId)] = &regionImage{region: region, image: image}
+		}
+	})
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return found, multiErrors
+}
+
+// describeRegion describes the given images of a single region with a single
+// request.
+func (a *AwsImages) describeRegion(ctx context.Context, region string, ids []string) ([]*ec2.Image, error) {
+	svc, err := a.svcFromRegion(region)
+	if err != nil {
+		return nil, err
+	}
+
+	req, resp := svc.DescribeImagesRequest(&ec2.DescribeImagesInput{
+		Filters: []*ec2.Filter{
+			{Name: awsclient.String("image-id"), Values: stringSlice(ids...)},
+		},
+	})
+	if err := send(ctx, req); err != nil {
+		if isImageNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return resp.Images, nil
+}
@@ -0,0 +1,115 @@
+package aws
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestWaitImages(t *testing.T) {
+	tests := []struct {
+		name  string
+		state string
+
+		// responses are the DescribeImages responses of eu-west-1 for each
+		// poll, us-east-1 never has any images
+		responses []string
+		err       string
+	}{
+		{
+			name:  "available",
+			state: "available",
+			responses: []string{
+				imagesResponse("ami-1", "available", "ami-2", "available"),
+			},
+		},
+		{
+			name:  "not visible yet",
+			state: "available",
+			responses: []string{
+				imagesResponse(),
+				imagesResponse("ami-1", "available", "ami-2", "available"),
+			},
+		},
+		{
+			name:  "not found yet",
+			state: "available",
+			responses: []string{
+				ec2Error("InvalidAMIID.NotFound"),
+				imagesResponse("ami-1", "available", "ami-2", "pending"),
+				imagesResponse("ami-2", "available"),
+			},
+		},
+		{
+			name:  "failed",
+			state: "available",
+			responses: []string{
+				imagesResponse("ami-1", "available", "ami-2", "failed"),
+			},
+			err: "ami-2 is failed",
+		},
+		{
+			name:  "deregistered",
+			state: "deregistered",
+			responses: []string{
+				imagesResponse("ami-1", "deregistered"),
+			},
+		},
+		{
+			name:  "invalid state",
+			state: "gone",
+			err:   "invalid AMI state 'gone'",
+		},
+		{
+			name:  "unauthorized",
+			state: "available",
+			responses: []string{
+				ec2Error("UnauthorizedOperation"),
+			},
+			err: "UnauthorizedOperation",
+		},
+	}
+
+	for _, test := range tests {
+		var polls int32
+		fake := newFakeEC2(t, func(r *ec2Request) (int, string) {
+			if r.action != "DescribeImages" {
+				t.Errorf("%s: unexpected action %s", test.name, r.action)
+			}
+
+			if r.form.Get("ImageId.1") != "" {
+				t.Errorf("%s: images are described by their ids, which fails for unknown ids", test.name)
+			}
+
+			if r.region == "us-east-1" {
+				return 200, imagesResponse()
+			}
+
+			i := int(atomic.AddInt32(&polls, 1)) - 1
+			if i >= len(test.responses) {
+				i = len(test.responses) - 1
+			}
+
+			body := test.responses[i]
+			if strings.Contains(body, "<Errors>") {
+				return 400, body
+			}
+			return 200, body
+		})
+
+		a := fake.images("us-east-1", "eu-west-1")
+		err := a.WaitImages(context.Background(), test.state, "ami-1", "ami-2")
+		fake.Close()
+
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: %s", test.name, err)
+		case test.err != "" && err == nil:
+			t.Errorf("%s: expected error %q", test.name, test.err)
+		case test.err != "" && !strings.Contains(err.Error(), test.err):
+			t.Errorf("%s: error is %q, want %q", test.name, err, test.err)
+		}
+	}
+}
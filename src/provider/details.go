@@ -0,0 +1,102 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"provider/utils"
+)
+
+// Attribute is a provider specific attribute of an image. Attributes with
+// multiple values, such as block device mappings, have one value per entry.
+type Attribute struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// Details is the full representation of a single image, which contains all
+// provider specific attributes in addition to the common fields.
+type Details struct {
+	*Image
+
+	// Attributes are the provider specific attributes in the order they are
+	// displayed.
+	Attributes []*Attribute `json:"attributes,omitempty"`
+}
+
+// NewDetails returns the details of the given image without any provider
+// specific attributes.
+func NewDetails(image *Image) *Details {
+	return &Details{Image: image}
+}
+
+// Add adds an attribute with the given values. Empty values are ignored, an
+// attribute without any value isn't added at all.
+func (d *Details) Add(name string, values ...string) {
+	nonEmpty := make([]string, 0, len(values))
+	for _, val := range values {
+		if val != "" {
+			nonEmpty = append(nonEmpty, val)
+		}
+	}
+
+	if len(nonEmpty) == 0 {
+		return
+	}
+
+	d.Attributes = append(d.Attributes, &Attribute{Name: name, Values: nonEmpty})
+}
+
+// Print prints the details to standard output. Only the simplified and JSON
+// output modes are supported.
+func (d *Details) Print(mode utils.OutputMode) error {
+	switch mode {
+	case utils.JSON:
+		out, err := json.MarshalIndent(d, "", "    ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(out))
+		return nil
+	case utils.Simplified:
+		w := utils.NewImagesTabWriter(os.Stdout)
+		defer w.Flush()
+
+		attributes := []*Attribute{
+			{Name: "Provider", Values: []string{d.Provider}},
+			{Name: "Region", Values: []string{d.Region}},
+			{Name: "ID", Values: []string{d.ID}},
+			{Name: "Name", Values: []string{d.Name}},
+			{Name: "State", Values: []string{d.State}},
+			{Name: "Created", Values: []string{d.created()}},
+			{Name: "Size", Values: []string{d.size()}},
+			{Name: "Tags", Values: []string{d.tags()}},
+		}
+
+		if d.Account != "" {
+			attributes = append(attributes, &Attribute{Name: "Account", Values: []string{d.Account}})
+		}
+
+		if d.Encryption != "" {
+			attributes = append(attributes, &Attribute{Name: "Encryption", Values: []string{d.Encryption}})
+		}
+
+		for _, attr := range append(attributes, d.Attributes...) {
+			for i, val := range attr.Values {
+				name := ""
+				if i == 0 {
+					name = attr.Name + ":"
+				}
+
+				fmt.Fprintf(w, "%s\t%s\n", name, strings.TrimSpace(val))
+			}
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("output mode '%s' is not supported, use \"simplified\" or \"json\"", mode)
+	}
+}
@@ -2,8 +2,12 @@ package do
 
 import (
 	"errors"
+	"strings"
 
 	"command/loader"
+	"provider"
+
+	"golang.org/x/net/context"
 )
 
 // DoCommand implements the images various interfaces, such as Fetcher,
@@ -12,6 +16,15 @@ type DoCommand struct {
 	*DoImages
 }
 
+func init() {
+	provider.Register(&provider.Registration{
+		Name:         "do",
+		New:          newProvider,
+		Capabilities: provider.CanList | provider.CanDelete | provider.CanModify | provider.CanCopy | provider.CanWait | provider.CanSnapshots,
+		Help:         Help,
+	})
+}
+
 // NewCommand returns a new instance of DoCommand
 func NewCommand(args []string) (*DoCommand, []string, error) {
 	var conf struct {
@@ -36,22 +49,46 @@ func NewCommand(args []string) (*DoCommand, []string, error) {
 	}, remainingArgs, nil
 }
 
-// List implements the command.Lister interface
-func (d *DoCommand) List(args []string) error {
+// newProvider implements the provider.Factory func type
+func newProvider(args []string) (provider.Provider, []string, error) {
+	cmd, remainingArgs, err := NewCommand(args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cmd, remainingArgs, nil
+}
+
+// Name implements the provider.Provider interface
+func (d *DoCommand) Name() string { return "do" }
+
+// Fetch implements the provider.Provider interface
+func (d *DoCommand) Fetch(ctx context.Context, args []string) (provider.Images, error) {
 	l := newListFlags()
 	if err := l.flagSet.Parse(args); err != nil {
-		return nil // we don't return error, the usage will be printed instead
+		return nil, err
 	}
 
-	images, err := d.UserImages()
+	images, err := d.UserImages(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return images.Print(l.output)
+	return images.Normalize(), nil
+}
+
+// SelectArgs implements the command.Selector interface
+func (d *DoCommand) SelectArgs(images provider.Images) []string {
+	return []string{"-ids", strings.Join(images.IDs(), ",")}
+}
+
+// SplitListArgs implements the command.ListArgsSplitter interface. The list
+// command has no flags which narrow down the images.
+func (d *DoCommand) SplitListArgs(args []string) (list, rest []string) {
+	return nil, args
 }
 
-func (d *DoCommand) Copy(args []string) error {
+func (d *DoCommand) Copy(ctx context.Context, args []string) error {
 	c := newCopyOptions()
 	if err := c.flagSet.Parse(args); err != nil {
 		return nil // we don't return error, the usage will be printed instead
@@ -66,10 +103,10 @@ func (d *DoCommand) Copy(args []string) error {
 		return errors.New("no image is passed. Use --image")
 	}
 
-	return d.CopyImages(c)
+	return d.CopyImages(ctx, c)
 }
 
-func (d *DoCommand) Delete(args []string) error {
+func (d *DoCommand) Delete(ctx context.Context, args []string) error {
 	df := newDeleteOptions()
 	if err := df.flagSet.Parse(args); err != nil {
 		return nil // we don't return error, the usage will be printed instead
@@ -84,11 +121,11 @@ func (d *DoCommand) Delete(args []string) error {
 		return errors.New("no images are passed with [--ids]")
 	}
 
-	return d.DeleteImages(df)
+	return d.DeleteImages(ctx, df)
 }
 
 // Modify renames the given images
-func (d *DoCommand) Modify(args []string) error {
+func (d *DoCommand) Modify(ctx context.Context, args []string) error {
 	r := newRenameOptions()
 	if err := r.flagSet.Parse(args); err != nil {
 		return nil // we don't return error, the usage will be printed instead
@@ -107,11 +144,35 @@ func (d *DoCommand) Modify(args []string) error {
 		return errors.New("no name is passed with [--name]")
 	}
 
-	return d.RenameImages(r)
+	return d.RenameImages(ctx, r)
+}
+
+// OrphanedSnapshots returns the volume snapshots of deleted volumes.
+func (d *DoCommand) OrphanedSnapshots(ctx context.Context, args []string) (provider.Snapshots, error) {
+	return d.Orphans(ctx)
+}
+
+// Wait waits until the running actions of the given images are completed.
+func (d *DoCommand) Wait(ctx context.Context, args []string) error {
+	w := newWaitFlags()
+	if err := w.flagSet.Parse(args); err != nil {
+		return nil // we don't return error, the usage will be printed instead
+	}
+
+	if len(args) == 0 {
+		w.flagSet.Usage()
+		return nil
+	}
+
+	if len(w.imageIds) == 0 {
+		return errors.New("no images are passed with [--ids]")
+	}
+
+	return d.WaitImages(ctx, w.imageIds...)
 }
 
-// Help prints the help message for the given command
-func (d *DoCommand) Help(command string) string {
+// Help returns the help message for the given command
+func Help(command string) string {
 	var help string
 	switch command {
 	case "delete":
@@ -122,12 +183,15 @@ func (d *DoCommand) Help(command string) string {
 		help = newCopyOptions().helpMsg
 	case "list":
 		help = newListFlags().helpMsg
+	case "wait":
+		help = newWaitFlags().helpMsg
 	default:
 		return "no help found for command " + command
 	}
 
 	global := `
   -token       "..."           DigitalOcean Access Token (env: IMAGES_DO_TOKEN)
+  -request-timeout "30s"       Timeout of a single request (env: IMAGES_DO_REQUEST_TIMEOUT)
 `
 
 	help += global
@@ -6,16 +6,30 @@ import (
 	"io/ioutil"
 	"os"
 	"sync"
+	"time"
+
+	"provider/utils"
 
 	"github.com/digitalocean/godo"
 	"github.com/fatih/flags"
 	"github.com/hashicorp/go-multierror"
+	"golang.org/x/net/context"
+)
+
+// states of a DigitalOcean action
+const (
+	actionCompleted = "completed"
+	actionErrored   = "errored"
 )
 
 type CopyOptions struct {
 	ImageID       int
 	SourceRegions []string
 
+	// Wait waits until the transfers are completed, at most WaitTimeout
+	Wait        bool
+	WaitTimeout time.Duration
+
 	helpMsg string
 	flagSet *flag.FlagSet
 }
@@ -26,6 +40,8 @@ func newCopyOptions() *CopyOptions {
 	flagSet := flag.NewFlagSet("copy", flag.ContinueOnError)
 	flagSet.IntVar(&c.ImageID, "image", 0, "Image to be copied with the given id")
 	flagSet.Var(flags.NewStringSlice(nil, &c.SourceRegions), "to", "Images to be copied to the given regions")
+	flagSet.BoolVar(&c.Wait, "wait", false, "Wait until the transfers are completed")
+	flagSet.Var(utils.NewDurationValue(time.Hour, &c.WaitTimeout), "wait-timeout", "Maximum duration of waiting")
 
 	c.helpMsg = `Usage: images copy --providers do [options]
 
@@ -35,10 +51,12 @@ Options:
 
   -image   "123"               Image to be copied with the given id
   -to      "fra1,nyc2,..."     Image to be copied to the given regions 
+  -wait                        Wait until the transfers are completed
+  -wait-timeout "1h"           Maximum duration of waiting (default: "1h")
 `
 
 	flagSet.Usage = func() {
-		fmt.Fprintf(os.Stderr, c.helpMsg)
+		fmt.Fprint(os.Stderr, c.helpMsg)
 	}
 
 	flagSet.SetOutput(ioutil.Discard) // don't print anything without my permission
@@ -46,32 +64,69 @@ Options:
 	return c
 }
 
-// Copy transfers the images to other regions
-func (d *DoImages) CopyImages(opts *CopyOptions) error {
+// Copy transfers the images to other regions. It prints the transfer of each
+// region and waits until they are completed if opts.Wait is set.
+func (d *DoImages) CopyImages(ctx context.Context, opts *CopyOptions) error {
 	var (
-		wg sync.WaitGroup
-		mu sync.Mutex
-
+		mu          sync.Mutex // protects multiErrors
 		multiErrors error
 	)
 
-	for _, r := range opts.SourceRegions {
-		wg.Add(1)
-		go func(region string) {
-			_, _, err := d.client.ImageActions.Transfer(opts.ImageID, &godo.ActionRequest{
-				"type":   "transfer",
-				"region": region,
-			})
-			if err != nil {
-				mu.Lock()
-				multiErrors = multierror.Append(multiErrors, err)
-				mu.Unlock()
-			}
-
-			wg.Done()
-		}(r)
+	progress := utils.NewProgress(os.Stdout, opts.SourceRegions...)
+	tracker := utils.NewTracker(opts.SourceRegions...)
+
+	utils.ForEach(ctx, utils.Parallelism(ctx, d.parallelism), len(opts.SourceRegions), func(i int) {
+		region := opts.SourceRegions[i]
+		progress.Set(region, "transferring")
+
+		err := d.transfer(ctx, d.client(ctx), progress, opts, region)
+		if err != nil {
+			progress.Set(region, "failed")
+
+			mu.Lock()
+			multiErrors = multierror.Append(multiErrors, fmt.Errorf("%s: %s", region, err))
+			mu.Unlock()
+			return
+		}
+
+		tracker.Done(region)
+	})
+
+	return tracker.Err(ctx, multiErrors)
+}
+
+// transfer transfers the image to the given region. DigitalOcean keeps the
+// id of the image in all regions.
+func (d *DoImages) transfer(ctx context.Context, client *godo.Client, progress *utils.Progress, opts *CopyOptions, region string) error {
+	action, _, err := client.ImageActions.Transfer(opts.ImageID, &godo.ActionRequest{
+		"type":   "transfer",
+		"region": region,
+	})
+	if err != nil {
+		return err
 	}
 
-	wg.Wait()
-	return multiErrors
+	progress.Set(region, "%d  %s", opts.ImageID, action.Status)
+
+	if !opts.Wait {
+		return nil
+	}
+
+	return utils.Poll(ctx, opts.WaitTimeout, func() (bool, error) {
+		action, _, err := client.ImageActions.Get(opts.ImageID, action.ID)
+		if err != nil {
+			return false, err
+		}
+
+		progress.Set(region, "%d  %s", opts.ImageID, action.Status)
+
+		switch action.Status {
+		case actionCompleted:
+			return true, nil
+		case actionErrored:
+			return false, fmt.Errorf("transfer of %d errored", opts.ImageID)
+		}
+
+		return false, nil
+	})
 }
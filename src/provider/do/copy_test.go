@@ -0,0 +1,117 @@
+package do
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestCopyImages(t *testing.T) {
+	tests := []struct {
+		name string
+		wait bool
+
+		// status returns the status of the transfer to the given region
+		status func(region string) string
+		err    string
+	}{
+		{
+			name:   "without waiting",
+			status: func(region string) string { return "in-progress" },
+		},
+		{
+			name:   "completed",
+			wait:   true,
+			status: func(region string) string { return "completed" },
+		},
+		{
+			name: "errored",
+			wait: true,
+			status: func(region string) string {
+				if region == "ams3" {
+					return "errored"
+				}
+				return "completed"
+			},
+			err: "ams3: transfer of 7 errored",
+		},
+	}
+
+	for _, test := range tests {
+		var (
+			mu        sync.Mutex
+			transfers []string
+			polls     int
+			regions   = make(map[int]string) // by action id
+		)
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			switch {
+			case r.Method == "POST" && r.URL.Path == "/v2/images/7/actions":
+				var req struct{ Type, Region string }
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Type != "transfer" {
+					t.Errorf("%s: transfer request is %+v, %v", test.name, req, err)
+				}
+
+				id := len(transfers) + 1
+				transfers = append(transfers, req.Region)
+				regions[id] = req.Region
+
+				w.WriteHeader(http.StatusCreated)
+				fmt.Fprintf(w, `{"action": {"id": %d, "status": "in-progress", "type": "transfer"}}`, id)
+			case r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/v2/images/7/actions/"):
+				var id int
+				fmt.Sscanf(r.URL.Path, "/v2/images/7/actions/%d", &id)
+
+				polls++
+				fmt.Fprintf(w, `{"action": {"id": %d, "status": %q, "type": "transfer"}}`, id, test.status(regions[id]))
+			default:
+				t.Errorf("%s: unexpected request %s %s", test.name, r.Method, r.URL)
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		u, _ := url.Parse(server.URL)
+		d := &DoImages{httpClient: &http.Client{Transport: fakeTransport{u}}}
+
+		err := d.CopyImages(context.Background(), &CopyOptions{
+			ImageID:       7,
+			SourceRegions: []string{"nyc3", "ams3"},
+			Wait:          test.wait,
+			WaitTimeout:   time.Minute,
+		})
+		server.Close()
+
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: %s", test.name, err)
+		case test.err != "" && err == nil:
+			t.Errorf("%s: expected error %q", test.name, test.err)
+		case test.err != "" && !strings.Contains(err.Error(), test.err):
+			t.Errorf("%s: error is %q, want %q", test.name, err, test.err)
+		}
+
+		sort.Strings(transfers)
+		if strings.Join(transfers, ",") != "ams3,nyc3" {
+			t.Errorf("%s: image is transferred to %q, want ams3 and nyc3", test.name, transfers)
+		}
+
+		if want := map[bool]int{false: 0, true: 2}[test.wait]; polls != want {
+			t.Errorf("%s: transfers are polled %d times, want %d", test.name, polls, want)
+		}
+	}
+}
@@ -5,10 +5,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strconv"
 	"sync"
 
+	"provider/utils"
+
 	"github.com/fatih/flags"
 	"github.com/hashicorp/go-multierror"
+	"golang.org/x/net/context"
 )
 
 type DeleteOptions struct {
@@ -32,7 +36,7 @@ Options:
   -ids         "123,..."       Images to be deleted with the given ids
 `
 	flagSet.Usage = func() {
-		fmt.Fprintf(os.Stderr, d.helpMsg)
+		fmt.Fprint(os.Stderr, d.helpMsg)
 	}
 
 	flagSet.SetOutput(ioutil.Discard) // don't print anything without my permission
@@ -41,27 +45,34 @@ Options:
 }
 
 // Delete deletes the given images.
-func (d *DoImages) DeleteImages(opts *DeleteOptions) error {
+func (d *DoImages) DeleteImages(ctx context.Context, opts *DeleteOptions) error {
 	var (
-		wg          sync.WaitGroup
 		mu          sync.Mutex // protects multiErrors
 		multiErrors error
 	)
 
-	for _, imageID := range opts.ImageIds {
-		wg.Add(1)
-		go func(id int) {
-			_, err := d.client.Images.Delete(id)
-			if err != nil {
-				mu.Lock()
-				multiErrors = multierror.Append(multiErrors, err)
-				mu.Unlock()
-			}
-
-			wg.Done()
-		}(imageID)
-	}
+	tracker := utils.NewTracker(itoa(opts.ImageIds)...)
+
+	utils.ForEach(ctx, utils.Parallelism(ctx, d.parallelism), len(opts.ImageIds), func(i int) {
+		id := opts.ImageIds[i]
+		if _, err := d.client(ctx).Images.Delete(id); err != nil {
+			mu.Lock()
+			multiErrors = multierror.Append(multiErrors, err)
+			mu.Unlock()
+			return
+		}
+
+		tracker.Done(strconv.Itoa(id))
+	})
 
-	wg.Wait()
-	return multiErrors
+	return tracker.Err(ctx, multiErrors)
+}
+
+// itoa converts the given ids to strings
+func itoa(ids []int) []string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = strconv.Itoa(id)
+	}
+	return s
 }
@@ -0,0 +1,70 @@
+package do
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestDeleteImages(t *testing.T) {
+	var (
+		mu           sync.Mutex
+		active, peak int
+		deleted      []string
+		parallelism  = 2
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "DELETE" || !strings.HasPrefix(r.URL.Path, "/v2/images/") {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			return
+		}
+
+		mu.Lock()
+		if active++; active > peak {
+			peak = active
+		}
+		mu.Unlock()
+
+		time.Sleep(50 * time.Millisecond)
+
+		mu.Lock()
+		active--
+		deleted = append(deleted, strings.TrimPrefix(r.URL.Path, "/v2/images/"))
+		mu.Unlock()
+
+		if r.URL.Path == "/v2/images/3" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"id": "not_found", "message": "The resource you were accessing could not be found."}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	d := &DoImages{httpClient: &http.Client{Transport: fakeTransport{u}}, parallelism: parallelism}
+
+	err := d.DeleteImages(context.Background(), &DeleteOptions{ImageIds: []int{1, 2, 3, 4, 5}})
+	if err == nil || !strings.Contains(err.Error(), "could not be found") {
+		t.Errorf("error is %v, want the error of image 3", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(deleted) != 5 {
+		t.Errorf("deleted images are %v, want all 5", deleted)
+	}
+
+	if peak != parallelism {
+		t.Errorf("%d images are deleted concurrently, want %d", peak, parallelism)
+	}
+}
@@ -3,7 +3,8 @@ package do
 import (
 	"errors"
 	"net/http"
-	"time"
+
+	"provider/utils"
 
 	"github.com/digitalocean/godo"
 	"golang.org/x/net/context"
@@ -12,6 +13,19 @@ import (
 
 type DoConfig struct {
 	Token string `toml:"token" json:"token"`
+
+	// RequestTimeout is the timeout of a single request, i.e: "1m" (default:
+	// "30s"). The duration of a whole command is limited by the global
+	// timeout setting.
+	RequestTimeout string `toml:"request_timeout" json:"request_timeout"`
+
+	// Retries is the maximum number of retries of a failed or rate limited
+	// request (default: 5)
+	Retries int `toml:"retries" json:"retries" default:"5"`
+
+	// Parallelism is the maximum number of concurrent requests (default:
+	// the global parallelism)
+	Parallelism int `toml:"parallelism" json:"parallelism"`
 }
 
 type tokenSource struct {
@@ -27,8 +41,15 @@ func (t *tokenSource) Token() (*oauth2.Token, error) {
 
 // DoImages is responsible of managing DigitalOcean images
 type DoImages struct {
-	client *godo.Client
-	images []godo.Image
+	// httpClient is the authenticated client, which is bound to a context
+	// for each call
+	httpClient *http.Client
+	retry      *utils.Retry
+	images     []godo.Image
+
+	// parallelism is the parallelism setting of the provider, zero uses the
+	// global setting
+	parallelism int
 }
 
 // New returns a new instance of DoImages
@@ -37,13 +58,24 @@ func New(conf *DoConfig) (*DoImages, error) {
 		return nil, errors.New("Access Token is not set. Please check your configuration.")
 	}
 
-	// increase the timeout
-	timeout := time.Second * 30
-	client := &http.Client{
-		Transport: &http.Transport{TLSHandshakeTimeout: timeout},
-		Timeout:   timeout,
+	timeout, err := utils.ParseRequestTimeout(conf.RequestTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	retry, err := utils.NewRetry(conf.Retries)
+	if err != nil {
+		return nil, err
 	}
 
+	if err := utils.ValidateParallelism(conf.Parallelism); err != nil {
+		return nil, err
+	}
+
+	// the oauth2 client uses only the transport of the given client, which
+	// applies the timeout to each attempt of a request
+	client := utils.NewRetryClient(timeout, retry)
+
 	// we need to pass the client with the context itself
 	ctx := context.WithValue(oauth2.NoContext, oauth2.HTTPClient, client)
 
@@ -51,15 +83,27 @@ func New(conf *DoConfig) (*DoImages, error) {
 		AccessToken: conf.Token,
 	})
 
-	godoClient := godo.NewClient(oauthClient)
-
 	return &DoImages{
-		client: godoClient,
-		images: make([]godo.Image, 0),
+		httpClient:  oauthClient,
+		retry:       retry,
+		images:      make([]godo.Image, 0),
+		parallelism: conf.Parallelism,
 	}, nil
 }
 
-func (d *DoImages) UserImages() (Images, error) {
-	images, _, err := d.client.Images.ListUser(nil)
+// client returns a godo client whose requests are canceled once ctx is done.
+// A godo client isn't safe for concurrent use, each goroutine needs its own.
+func (d *DoImages) client(ctx context.Context) *godo.Client {
+	return godo.NewClient(utils.WithContext(ctx, d.httpClient))
+}
+
+// Retries returns the number of retried requests and how many of them were
+// rate limited.
+func (d *DoImages) Retries() (total, throttled int) {
+	return d.retry.Retries()
+}
+
+func (d *DoImages) UserImages(ctx context.Context) (Images, error) {
+	images, _, err := d.client(ctx).Images.ListUser(nil)
 	return Images(images), err
 }
@@ -27,11 +27,11 @@ func newListFlags() *listFlags {
 Options:
 
   -output  "json"              Output mode of images. (default: "simplified")
-                               Available options: "json","table" or "simplified" 
+                               Available options: ` + utils.OutputsHelp + `
 `
 
 	flagSet.Usage = func() {
-		fmt.Fprintf(os.Stderr, l.helpMsg)
+		fmt.Fprint(os.Stderr, l.helpMsg)
 	}
 	flagSet.SetOutput(ioutil.Discard) // don't print anything without my permission
 	l.flagSet = flagSet
@@ -5,11 +5,15 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strconv"
 	"sync"
 
+	"provider/utils"
+
 	"github.com/digitalocean/godo"
 	"github.com/fatih/flags"
 	"github.com/hashicorp/go-multierror"
+	"golang.org/x/net/context"
 )
 
 type RenameOptions struct {
@@ -39,7 +43,7 @@ Options:
   -name        "example"       New name for the images
 `
 	flagSet.Usage = func() {
-		fmt.Fprintf(os.Stderr, r.helpMsg)
+		fmt.Fprint(os.Stderr, r.helpMsg)
 	}
 
 	flagSet.SetOutput(ioutil.Discard) // don't print anything without my permission
@@ -48,27 +52,26 @@ Options:
 }
 
 // RenameImages renames the images to the given new names
-func (d *DoImages) RenameImages(opts *RenameOptions) error {
+func (d *DoImages) RenameImages(ctx context.Context, opts *RenameOptions) error {
 	var (
-		wg          sync.WaitGroup
 		mu          sync.Mutex // protects multiErrors
 		multiErrors error
 	)
 
-	for _, imageID := range opts.ImageIds {
-		wg.Add(1)
-		go func(id int) {
-			_, _, err := d.client.Images.Update(id, &godo.ImageUpdateRequest{Name: opts.Name})
-			if err != nil {
-				mu.Lock()
-				multiErrors = multierror.Append(multiErrors, err)
-				mu.Unlock()
-			}
-
-			wg.Done()
-		}(imageID)
-	}
+	tracker := utils.NewTracker(itoa(opts.ImageIds)...)
+
+	utils.ForEach(ctx, utils.Parallelism(ctx, d.parallelism), len(opts.ImageIds), func(i int) {
+		id := opts.ImageIds[i]
+		_, _, err := d.client(ctx).Images.Update(id, &godo.ImageUpdateRequest{Name: opts.Name})
+		if err != nil {
+			mu.Lock()
+			multiErrors = multierror.Append(multiErrors, err)
+			mu.Unlock()
+			return
+		}
+
+		tracker.Done(strconv.Itoa(id))
+	})
 
-	wg.Wait()
-	return multiErrors
+	return tracker.Err(ctx, multiErrors)
 }
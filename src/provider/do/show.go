@@ -0,0 +1,30 @@
+package do
+
+import (
+	"fmt"
+	"strconv"
+
+	"provider"
+
+	"github.com/digitalocean/godo"
+	"golang.org/x/net/context"
+)
+
+// Describe returns all attributes of the given image, including the regions
+// it's available in and the minimum disk size of droplets created from it.
+func (d *DoImages) Describe(ctx context.Context, image *provider.Image) (*provider.Details, error) {
+	raw, ok := image.Raw.(godo.Image)
+	if !ok {
+		return nil, fmt.Errorf("image '%s' is not a DigitalOcean image", image.ID)
+	}
+
+	details := provider.NewDetails(image)
+	details.Add("Type", raw.Type)
+	details.Add("Distribution", raw.Distribution)
+	details.Add("Slug", raw.Slug)
+	details.Add("Public", strconv.FormatBool(raw.Public))
+	details.Add("Regions", raw.Regions...)
+	details.Add("Min disk size", strconv.Itoa(raw.MinDiskSize)+"GB")
+
+	return details, nil
+}
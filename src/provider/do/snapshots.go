@@ -0,0 +1,164 @@
+package do
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"provider"
+	"provider/utils"
+
+	"github.com/digitalocean/godo"
+	"github.com/hashicorp/go-multierror"
+	"golang.org/x/net/context"
+)
+
+// perPage is the number of items fetched with a single request
+const perPage = 200
+
+// volumeSnapshot is a snapshot of a block storage volume. The godo package
+// doesn't support snapshots yet.
+type volumeSnapshot struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	CreatedAt    time.Time `json:"created_at"`
+	Regions      []string  `json:"regions"`
+	ResourceID   string    `json:"resource_id"`
+	ResourceType string    `json:"resource_type"`
+	MinDiskSize  int64     `json:"min_disk_size"`
+	SizeGB       float64   `json:"size_gigabytes"`
+}
+
+// Orphans returns the volume snapshots whose volume doesn't exist anymore.
+func (d *DoImages) Orphans(ctx context.Context) (provider.Snapshots, error) {
+	client := d.client(ctx)
+
+	volumes := make(map[string]bool)
+	err := listAll(client, "v2/volumes", func(page json.RawMessage) error {
+		var v struct {
+			Volumes []struct {
+				ID string `json:"id"`
+			} `json:"volumes"`
+		}
+		if err := json.Unmarshal(page, &v); err != nil {
+			return err
+		}
+
+		for _, volume := range v.Volumes {
+			volumes[volume.ID] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var orphans provider.Snapshots
+	err = listAll(client, "v2/snapshots?resource_type=volume", func(page json.RawMessage) error {
+		var v struct {
+			Snapshots []volumeSnapshot `json:"snapshots"`
+		}
+		if err := json.Unmarshal(page, &v); err != nil {
+			return err
+		}
+
+		for i, snapshot := range v.Snapshots {
+			if snapshot.ResourceID == "" || volumes[snapshot.ResourceID] {
+				continue
+			}
+
+			orphans = append(orphans, &provider.Snapshot{
+				Provider:  "do",
+				Region:    strings.Join(snapshot.Regions, ","),
+				ID:        snapshot.ID,
+				Name:      snapshot.Name,
+				Source:    snapshot.ResourceID,
+				CreatedAt: snapshot.CreatedAt,
+				Size:      snapshot.MinDiskSize,
+				Raw:       &v.Snapshots[i],
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return orphans, nil
+}
+
+// listAll fetches all pages of the given list endpoint and calls fn with the
+// body of each page.
+func listAll(client *godo.Client, endpoint string, fn func(page json.RawMessage) error) error {
+	sep := "?"
+	if strings.Contains(endpoint, "?") {
+		sep = "&"
+	}
+
+	for page := 1; ; page++ {
+		req, err := client.NewRequest("GET", fmt.Sprintf("%s%spage=%d&per_page=%d", endpoint, sep, page, perPage), nil)
+		if err != nil {
+			return err
+		}
+
+		var body json.RawMessage
+		if _, err := client.Do(req, &body); err != nil {
+			return err
+		}
+
+		if err := fn(body); err != nil {
+			return err
+		}
+
+		var v struct {
+			Links *godo.Links `json:"links"`
+		}
+		if err := json.Unmarshal(body, &v); err != nil {
+			return err
+		}
+
+		if v.Links == nil || v.Links.IsLastPage() {
+			return nil
+		}
+	}
+}
+
+// DeleteSnapshots deletes the given volume snapshots. If ctx is done before
+// all snapshots are deleted, the returned error reports the deleted
+// snapshots.
+func (d *DoImages) DeleteSnapshots(ctx context.Context, snapshots provider.Snapshots) error {
+	var (
+		mu          sync.Mutex // protects multiErrors
+		multiErrors error
+	)
+
+	ids := make([]string, len(snapshots))
+	for i, snapshot := range snapshots {
+		ids[i] = snapshot.ID
+	}
+
+	tracker := utils.NewTracker(ids...)
+
+	utils.ForEach(ctx, utils.Parallelism(ctx, d.parallelism), len(ids), func(i int) {
+		id := ids[i]
+		client := d.client(ctx)
+
+		req, err := client.NewRequest("DELETE", "v2/snapshots/"+id, nil)
+		if err == nil {
+			_, err = client.Do(req, nil)
+		}
+
+		if err != nil {
+			mu.Lock()
+			multiErrors = multierror.Append(multiErrors, err)
+			mu.Unlock()
+			return
+		}
+
+		tracker.Done(id)
+	})
+
+	return tracker.Err(ctx, multiErrors)
+}
@@ -0,0 +1,180 @@
+package do
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"provider/utils"
+
+	"github.com/digitalocean/godo"
+	"github.com/fatih/flags"
+	"github.com/hashicorp/go-multierror"
+	"golang.org/x/net/context"
+)
+
+// actionInProgress is the state of a running DigitalOcean action
+const actionInProgress = "in-progress"
+
+// actionsPerPage is the page size of the image actions
+const actionsPerPage = 200
+
+type waitFlags struct {
+	imageIds []int
+
+	helpMsg string
+	flagSet *flag.FlagSet
+}
+
+func newWaitFlags() *waitFlags {
+	w := &waitFlags{}
+
+	flagSet := flag.NewFlagSet("wait", flag.ContinueOnError)
+	flagSet.Var(flags.NewIntSlice(nil, &w.imageIds), "ids", "Images to wait for with the given ids")
+	w.helpMsg = `Usage: images wait --providers do [options]
+
+  Wait until the actions of images, such as transfers, are completed
+
+Options:
+
+  -ids         "123,..."       Images to wait for with the given ids
+`
+	flagSet.Usage = func() {
+		fmt.Fprint(os.Stderr, w.helpMsg)
+	}
+
+	flagSet.SetOutput(ioutil.Discard) // don't print anything without my permission
+	w.flagSet = flagSet
+	return w
+}
+
+// WaitImages waits until the running actions of the given images are
+// completed and prints the state of each image. It fails once an action of
+// the images errors, unless it already errored before the wait began.
+func (d *DoImages) WaitImages(ctx context.Context, ids ...int) error {
+	progress := utils.NewProgress(os.Stdout, itoa(ids)...)
+	tracker := utils.NewTracker(itoa(ids)...)
+
+	// actions which already errored at the first poll and ended before the
+	// wait began, they don't fail the images. All other errored actions
+	// ended during the wait.
+	errored := make(map[int]bool)
+
+	start := time.Now()
+	first := true
+
+	err := utils.Poll(ctx, 0, func() (bool, error) {
+		actions, err := d.imagesActions(ctx, ids)
+		if err != nil {
+			return false, err
+		}
+
+		inProgress := make(map[int]int)
+		for _, action := range actions {
+			switch action.Status {
+			case actionInProgress:
+				inProgress[action.ResourceID]++
+			case actionErrored:
+				if first && endedBefore(action, start) {
+					errored[action.ID] = true
+				}
+
+				if !errored[action.ID] {
+					return false, fmt.Errorf("%s of %d errored", action.Type, action.ResourceID)
+				}
+			}
+		}
+		first = false
+
+		done := true
+		for _, id := range ids {
+			if n := inProgress[id]; n != 0 {
+				progress.Set(strconv.Itoa(id), "%d action(s) in progress", n)
+				done = false
+				continue
+			}
+
+			progress.Set(strconv.Itoa(id), "%s", actionCompleted)
+			tracker.Done(strconv.Itoa(id))
+		}
+
+		return done, nil
+	})
+
+	return tracker.Err(ctx, err)
+}
+
+// endedBefore returns true if the given action ended before t. Actions without
+// any timestamps are considered to be ended before.
+func endedBefore(action godo.Action, t time.Time) bool {
+	ended := action.CompletedAt
+	if ended == nil {
+		ended = action.StartedAt
+	}
+
+	return ended == nil || ended.Before(t)
+}
+
+// imageActionsRoot is a page of the actions of an image. The image actions
+// can't be listed with the vendored godo.
+type imageActionsRoot struct {
+	Actions []godo.Action `json:"actions"`
+	Links   *godo.Links   `json:"links"`
+}
+
+// imagesActions returns all actions of the given images, which are fetched
+// concurrently.
+func (d *DoImages) imagesActions(ctx context.Context, ids []int) ([]godo.Action, error) {
+	var (
+		mu          sync.Mutex // protects actions and multiErrors
+		actions     []godo.Action
+		multiErrors error
+	)
+
+	utils.ForEach(ctx, utils.Parallelism(ctx, d.parallelism), len(ids), func(i int) {
+		imageActions, err := d.imageActions(ctx, ids[i])
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err != nil {
+			multiErrors = multierror.Append(multiErrors, fmt.Errorf("%d: %s", ids[i], err))
+			return
+		}
+		actions = append(actions, imageActions...)
+	})
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return actions, multiErrors
+}
+
+// imageActions returns all actions of the given image.
+func (d *DoImages) imageActions(ctx context.Context, id int) ([]godo.Action, error) {
+	client := d.client(ctx)
+
+	var actions []godo.Action
+	for page := 1; ; page++ {
+		path := fmt.Sprintf("v2/images/%d/actions?page=%d&per_page=%d", id, page, actionsPerPage)
+		req, err := client.NewRequest("GET", path, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		root := &imageActionsRoot{}
+		if _, err := client.Do(req, root); err != nil {
+			return nil, err
+		}
+
+		actions = append(actions, root.Actions...)
+		if root.Links == nil || root.Links.IsLastPage() || len(root.Actions) == 0 {
+			return actions, nil
+		}
+	}
+}
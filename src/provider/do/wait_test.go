@@ -0,0 +1,149 @@
+package do
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// fakeTransport sends all requests to the fake API
+type fakeTransport struct{ url *url.URL }
+
+func (f fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.URL.Scheme, req.URL.Host = f.url.Scheme, f.url.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func action(id, image int, status string, started, completed time.Time) string {
+	return fmt.Sprintf(`{"id": %d, "status": %q, "type": "transfer", "resource_id": %d, "resource_type": "image", "started_at": %q, "completed_at": %q}`,
+		id, status, image, started.Format(time.RFC3339), completed.Format(time.RFC3339))
+}
+
+func TestWaitImages(t *testing.T) {
+	now := time.Now().UTC()
+	old, later := now.Add(-time.Hour), now.Add(time.Hour)
+
+	tests := []struct {
+		name string
+
+		// pages returns the action pages of the given image for the given
+		// poll, starting with zero
+		pages func(image, poll int) [][]string
+		err   string
+	}{
+		{
+			name: "completed",
+			pages: func(image, poll int) [][]string {
+				return [][]string{{action(image, image, "completed", old, old)}}
+			},
+		},
+		{
+			name: "in progress",
+			pages: func(image, poll int) [][]string {
+				if poll == 0 && image == 7 {
+					return [][]string{{action(1, 7, "in-progress", now, now)}}
+				}
+				return [][]string{{action(1, image, "completed", now, later)}}
+			},
+		},
+		{
+			name: "in progress on the second page",
+			pages: func(image, poll int) [][]string {
+				status := "completed"
+				if poll == 0 {
+					status = "in-progress"
+				}
+				return [][]string{
+					{action(2, image, "completed", old, old)},
+					{action(1, image, status, now, now)},
+				}
+			},
+		},
+		{
+			name: "errored before",
+			pages: func(image, poll int) [][]string {
+				return [][]string{{action(image, image, "errored", old, old)}}
+			},
+		},
+		{
+			name: "errored before the first poll",
+			pages: func(image, poll int) [][]string {
+				return [][]string{{action(1, 8, "errored", old, later)}}
+			},
+			err: "transfer of 8 errored",
+		},
+		{
+			name: "errored during the wait",
+			pages: func(image, poll int) [][]string {
+				status := "errored"
+				if poll == 0 {
+					status = "in-progress"
+				}
+				return [][]string{{action(1, 7, status, old, later)}}
+			},
+			err: "transfer of 7 errored",
+		},
+	}
+
+	for _, test := range tests {
+		var (
+			mu    sync.Mutex
+			polls = make(map[int]int)
+		)
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var image int
+			if _, err := fmt.Sscanf(r.URL.Path, "/v2/images/%d/actions", &image); err != nil {
+				t.Errorf("%s: unexpected request %s", test.name, r.URL)
+				return
+			}
+
+			page := 1
+			fmt.Sscanf(r.URL.Query().Get("page"), "%d", &page)
+
+			mu.Lock()
+			if page == 1 {
+				polls[image]++
+			}
+			poll := polls[image] - 1
+			mu.Unlock()
+
+			pages := test.pages(image, poll)
+			links := `{}`
+			if page < len(pages) {
+				links = fmt.Sprintf(`{"pages": {"next": "http://fake/v2/images/%d/actions?page=%d", "last": "http://fake/v2/images/%d/actions?page=%d"}}`,
+					image, page+1, image, len(pages))
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"actions": [%s], "links": %s}`, strings.Join(pages[page-1], ","), links)
+		}))
+
+		u, _ := url.Parse(server.URL)
+		d := &DoImages{httpClient: &http.Client{Transport: fakeTransport{u}}}
+		err := d.WaitImages(context.Background(), 7, 8)
+		server.Close()
+
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: %s", test.name, err)
+		case test.err != "" && err == nil:
+			t.Errorf("%s: expected error %q", test.name, test.err)
+		case test.err != "" && !strings.Contains(err.Error(), test.err):
+			t.Errorf("%s: error is %q, want %q", test.name, err, test.err)
+		}
+
+		mu.Lock()
+		if test.err == "" && strings.Contains(test.name, "in progress") && polls[7] < 2 {
+			t.Errorf("%s: images are completed after %d poll(s)", test.name, polls[7])
+		}
+		mu.Unlock()
+	}
+}
@@ -0,0 +1,284 @@
+// Package filter implements a small expression language to select images by
+// their provider agnostic fields. An example expression:
+//
+//	tag:env=prod and name~"^base-" and age>30d and state=available
+//
+// An expression consists of comparisons, which are combined with "and", "or",
+// "not" and parentheses. "and" binds stronger than "or". A comparison is in
+// the form of "<field><operator><value>". Values which contain spaces,
+// operators or parentheses must be quoted with double quotes.
+//
+// The following fields are supported:
+//
+//	provider, region, id, name, state   =, !=, ~ (regexp), !~
+//	account                             =, !=, ~, !~ with the account name
+//	family                              =, !=, ~, !~ with the image family
+//	encryption                          =, !=, ~, !~ with "encrypted",
+//	                                    "unencrypted" or "partial"
+//	tag:<key>                           =, !=, ~, !~ or without an operator
+//	                                    to check if the tag exists
+//	age                                 =, !=, <, <=, >, >= with a duration,
+//	                                    i.e: "36h", "30d" or "2w"
+//	created                             =, !=, <, <=, >, >= with a date
+//	                                    ("2006-01-02") or a RFC3339 time
+//	size                                =, !=, <, <=, >, >= in GB
+//
+// Comparisons with a tag which doesn't exist, with the account, family or
+// encryption of an image whose provider doesn't expose it or with the age of
+// an image without a creation time are false, except for "!=" and "!~".
+package filter
+
+import (
+	"fmt"
+	"provider"
+	"provider/utils"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter is a parsed filter expression
+type Filter struct {
+	src  string
+	expr node
+
+	// now returns the current time, used for the age field
+	now func() time.Time
+}
+
+// Parse parses the given filter expression.
+func Parse(s string) (*Filter, error) {
+	p := &parser{lexer: newLexer(s)}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter '%s': %s", s, err)
+	}
+
+	if p.tok.typ != tokEOF {
+		return nil, fmt.Errorf("invalid filter '%s': unexpected %s", s, p.tok)
+	}
+
+	return &Filter{src: s, expr: expr, now: time.Now}, nil
+}
+
+// String returns the source of the filter expression
+func (f *Filter) String() string { return f.src }
+
+// Match returns true if the image matches the filter
+func (f *Filter) Match(image *provider.Image) bool {
+	return f.expr.eval(image, f.now())
+}
+
+// Apply returns the images which match the filter. The order of the images
+// is preserved.
+func (f *Filter) Apply(images provider.Images) provider.Images {
+	now := f.now()
+
+	matched := make(provider.Images, 0, len(images))
+	for _, image := range images {
+		if f.expr.eval(image, now) {
+			matched = append(matched, image)
+		}
+	}
+
+	return matched
+}
+
+// node is a node of the expression tree
+type node interface {
+	eval(image *provider.Image, now time.Time) bool
+}
+
+type andNode struct{ left, right node }
+
+func (n *andNode) eval(image *provider.Image, now time.Time) bool {
+	return n.left.eval(image, now) && n.right.eval(image, now)
+}
+
+type orNode struct{ left, right node }
+
+func (n *orNode) eval(image *provider.Image, now time.Time) bool {
+	return n.left.eval(image, now) || n.right.eval(image, now)
+}
+
+type notNode struct{ node node }
+
+func (n *notNode) eval(image *provider.Image, now time.Time) bool {
+	return !n.node.eval(image, now)
+}
+
+// stringNode compares a string field of an image
+type stringNode struct {
+	field func(image *provider.Image) (string, bool)
+	op    string
+	value string
+	re    *regexp.Regexp
+}
+
+func (n *stringNode) eval(image *provider.Image, now time.Time) bool {
+	val, ok := n.field(image)
+
+	switch n.op {
+	case "":
+		return ok
+	case "=":
+		return ok && val == n.value
+	case "!=":
+		return !ok || val != n.value
+	case "~":
+		return ok && n.re.MatchString(val)
+	case "!~":
+		return !ok || !n.re.MatchString(val)
+	}
+
+	return false
+}
+
+// numberNode compares a numeric field of an image, such as the age or size.
+type numberNode struct {
+	field func(image *provider.Image, now time.Time) (int64, bool)
+	op    string
+	value int64
+}
+
+func (n *numberNode) eval(image *provider.Image, now time.Time) bool {
+	val, ok := n.field(image, now)
+	if !ok {
+		return n.op == "!="
+	}
+
+	switch n.op {
+	case "=":
+		return val == n.value
+	case "!=":
+		return val != n.value
+	case "<":
+		return val < n.value
+	case "<=":
+		return val <= n.value
+	case ">":
+		return val > n.value
+	case ">=":
+		return val >= n.value
+	}
+
+	return false
+}
+
+var stringFields = map[string]func(image *provider.Image) (string, bool){
+	"provider": func(i *provider.Image) (string, bool) { return i.Provider, true },
+	"region":   func(i *provider.Image) (string, bool) { return i.Region, true },
+	"id":       func(i *provider.Image) (string, bool) { return i.ID, true },
+	"name":     func(i *provider.Image) (string, bool) { return i.Name, true },
+	"state":    func(i *provider.Image) (string, bool) { return i.State, true },
+
+	"account":    func(i *provider.Image) (string, bool) { return i.Account, i.Account != "" },
+	"family":     func(i *provider.Image) (string, bool) { return i.Family, i.Family != "" },
+	"encryption": func(i *provider.Image) (string, bool) { return i.Encryption, i.Encryption != "" },
+}
+
+// newComparison returns the node for the given field, operator and value.
+func newComparison(field, op, value string) (node, error) {
+	if strings.HasPrefix(field, "tag:") {
+		key := strings.TrimPrefix(field, "tag:")
+		if key == "" {
+			return nil, fmt.Errorf("tag key is missing in '%s'", field)
+		}
+
+		tag := func(i *provider.Image) (string, bool) {
+			val, ok := i.Tags[key]
+			return val, ok
+		}
+
+		return newStringNode(tag, field, op, value)
+	}
+
+	if fn, ok := stringFields[field]; ok {
+		if op == "" {
+			return nil, fmt.Errorf("operator is missing for field '%s'", field)
+		}
+
+		return newStringNode(fn, field, op, value)
+	}
+
+	switch field {
+	case "age", "created", "size":
+	default:
+		return nil, fmt.Errorf("unknown field '%s'", field)
+	}
+
+	switch op {
+	case "=", "!=", "<", "<=", ">", ">=":
+	case "":
+		return nil, fmt.Errorf("operator is missing for field '%s'", field)
+	default:
+		return nil, fmt.Errorf("operator '%s' is not supported for field '%s'", op, field)
+	}
+
+	switch field {
+	case "age":
+		d, err := utils.ParseDuration(value)
+		if err != nil {
+			return nil, err
+		}
+
+		age := func(i *provider.Image, now time.Time) (int64, bool) {
+			if i.CreatedAt.IsZero() {
+				return 0, false
+			}
+			return int64(now.Sub(i.CreatedAt)), true
+		}
+
+		return &numberNode{field: age, op: op, value: int64(d)}, nil
+	case "created":
+		t, err := utils.ParseDate(value)
+		if err != nil {
+			return nil, err
+		}
+
+		created := func(i *provider.Image, now time.Time) (int64, bool) {
+			if i.CreatedAt.IsZero() {
+				return 0, false
+			}
+			return i.CreatedAt.Unix(), true
+		}
+
+		return &numberNode{field: created, op: op, value: t.Unix()}, nil
+	case "size":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size '%s'", value)
+		}
+
+		size := func(i *provider.Image, now time.Time) (int64, bool) {
+			return i.Size, true
+		}
+
+		return &numberNode{field: size, op: op, value: n}, nil
+	}
+
+	return nil, fmt.Errorf("unknown field '%s'", field)
+}
+
+func newStringNode(fn func(*provider.Image) (string, bool), field, op, value string) (node, error) {
+	n := &stringNode{field: fn, op: op, value: value}
+
+	switch op {
+	case "", "=", "!=":
+	case "~", "!~":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regexp '%s': %s", value, err)
+		}
+		n.re = re
+	default:
+		return nil, fmt.Errorf("operator '%s' is not supported for field '%s'", op, field)
+	}
+
+	return n, nil
+}
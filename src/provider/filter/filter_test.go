@@ -0,0 +1,165 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"provider"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr string
+		err  bool
+	}{
+		{expr: "name=base"},
+		{expr: `name~"^base-"`},
+		{expr: "tag:env"},
+		{expr: "tag:env=prod and not tag:keep"},
+		{expr: "(provider=aws or provider=gce) and age>30d"},
+		{expr: "created<2015-10-01 and size>=20"},
+		{expr: "created>=2015-10-01T12:00:00Z"},
+		{expr: "account=prod and family!=base and encryption=encrypted"},
+		{expr: `name="with spaces and (parens)"`},
+		{expr: "NAME=base"},
+
+		{expr: "", err: true},
+		{expr: "name", err: true},
+		{expr: "name=", err: true},
+		{expr: "unknown=1", err: true},
+		{expr: "tag:=1", err: true},
+		{expr: "name<base", err: true},
+		{expr: "age~30d", err: true},
+		{expr: "age>30x", err: true},
+		{expr: "created>yesterday", err: true},
+		{expr: "created>30d", err: true},
+		{expr: "size>big", err: true},
+		{expr: "name~(", err: true},
+		{expr: `name~"("`, err: true},
+		{expr: `name="base`, err: true},
+		{expr: "(name=base", err: true},
+		{expr: "name=base)", err: true},
+		{expr: "name=base and", err: true},
+		{expr: "name=base or or name=web", err: true},
+		{expr: "name=base name=web", err: true},
+	}
+
+	for _, test := range tests {
+		f, err := Parse(test.expr)
+		if test.err {
+			if err == nil {
+				t.Errorf("Parse(%q): expected an error", test.expr)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Parse(%q): %s", test.expr, err)
+			continue
+		}
+
+		if f.String() != test.expr {
+			t.Errorf("Parse(%q): String() = %q", test.expr, f.String())
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	now := time.Date(2015, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	aws := &provider.Image{
+		Provider:   "aws",
+		Account:    "prod",
+		Region:     "us-east-1",
+		ID:         "ami-1",
+		Name:       "base-ubuntu",
+		State:      "available",
+		CreatedAt:  time.Date(2015, 9, 1, 12, 0, 0, 0, time.UTC),
+		Size:       20,
+		Tags:       map[string]string{"env": "prod"},
+		Encryption: "encrypted",
+	}
+
+	gce := &provider.Image{
+		Provider:  "gce",
+		Region:    "global",
+		ID:        "1",
+		Name:      "web-debian",
+		Family:    "web",
+		State:     "READY",
+		CreatedAt: time.Date(2015, 10, 20, 12, 0, 0, 0, time.UTC),
+		Size:      10,
+	}
+
+	unknown := &provider.Image{
+		Provider: "do",
+		ID:       "2",
+		Name:     "base-unknown",
+	}
+
+	tests := []struct {
+		expr  string
+		match []*provider.Image
+	}{
+		{"name=base-ubuntu", []*provider.Image{aws}},
+		{`name~"^base-"`, []*provider.Image{aws, unknown}},
+		{`name!~"^base-"`, []*provider.Image{gce}},
+		{"provider!=aws", []*provider.Image{gce, unknown}},
+		{"tag:env", []*provider.Image{aws}},
+		{"tag:env=prod", []*provider.Image{aws}},
+		{"tag:env!=prod", []*provider.Image{gce, unknown}},
+		{"not tag:env", []*provider.Image{gce, unknown}},
+		{"tag:ENV", nil},
+		{"account=prod", []*provider.Image{aws}},
+		{"account!=prod", []*provider.Image{gce, unknown}},
+		{"family=web", []*provider.Image{gce}},
+		{"encryption=encrypted", []*provider.Image{aws}},
+		{"encryption~crypt", []*provider.Image{aws}},
+		{"age>30d", []*provider.Image{aws}},
+		{"age<=30d", []*provider.Image{gce}},
+		{"age!=30d", []*provider.Image{aws, gce, unknown}},
+		{"created<2015-10-01", []*provider.Image{aws}},
+		{"created>=2015-10-01T00:00:00Z", []*provider.Image{gce}},
+		{"size>=20", []*provider.Image{aws}},
+		{"size=0", []*provider.Image{unknown}},
+		{"provider=gce or tag:env=prod", []*provider.Image{aws, gce}},
+		{"provider=gce or provider=aws and size>10", []*provider.Image{aws, gce}},
+		{"(provider=gce or provider=aws) and size>10", []*provider.Image{aws}},
+		{"not (provider=gce or provider=aws)", []*provider.Image{unknown}},
+		{"not not provider=do", []*provider.Image{unknown}},
+		{"NAME=base-ubuntu and State=available", []*provider.Image{aws}},
+	}
+
+	images := provider.Images{aws, gce, unknown}
+	for _, test := range tests {
+		f, err := Parse(test.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %s", test.expr, err)
+			continue
+		}
+		f.now = func() time.Time { return now }
+
+		matched := f.Apply(images)
+		if len(matched) != len(test.match) {
+			t.Errorf("%q: matched %d image(s), want %d", test.expr, len(matched), len(test.match))
+			continue
+		}
+
+		for i, image := range matched {
+			if image != test.match[i] {
+				t.Errorf("%q: image #%d is %s, want %s", test.expr, i, image.ID, test.match[i].ID)
+			}
+		}
+
+		for _, image := range images {
+			want := false
+			for _, m := range test.match {
+				want = want || m == image
+			}
+
+			if got := f.Match(image); got != want {
+				t.Errorf("%q: Match(%s) = %t, want %t", test.expr, image.ID, got, want)
+			}
+		}
+	}
+}
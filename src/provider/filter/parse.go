@@ -0,0 +1,248 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenType int
+
+const (
+	tokEOF tokenType = iota
+	tokWord
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	typ tokenType
+	val string
+}
+
+func (t token) String() string {
+	switch t.typ {
+	case tokEOF:
+		return "end of filter"
+	case tokString:
+		return strconv.Quote(t.val)
+	default:
+		return "'" + t.val + "'"
+	}
+}
+
+// lexer splits a filter expression into tokens
+type lexer struct {
+	input string
+	pos   int
+}
+
+func newLexer(input string) *lexer {
+	return &lexer{input: input}
+}
+
+func isOpChar(r byte) bool {
+	return r == '=' || r == '!' || r == '<' || r == '>' || r == '~'
+}
+
+func isWordChar(r byte) bool {
+	return !isOpChar(r) && r != '(' && r != ')' && r != '"' && !unicode.IsSpace(rune(r))
+}
+
+// next returns the next token of the input
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.input) && unicode.IsSpace(rune(l.input[l.pos])) {
+		l.pos++
+	}
+
+	if l.pos >= len(l.input) {
+		return token{typ: tokEOF}, nil
+	}
+
+	start := l.pos
+	c := l.input[l.pos]
+
+	switch {
+	case c == '(':
+		l.pos++
+		return token{typ: tokLParen, val: "("}, nil
+	case c == ')':
+		l.pos++
+		return token{typ: tokRParen, val: ")"}, nil
+	case c == '"':
+		l.pos++
+		for l.pos < len(l.input) {
+			switch l.input[l.pos] {
+			case '\\':
+				l.pos += 2
+				continue
+			case '"':
+				l.pos++
+				s, err := strconv.Unquote(l.input[start:l.pos])
+				if err != nil {
+					return token{}, fmt.Errorf("invalid string %s", l.input[start:l.pos])
+				}
+				return token{typ: tokString, val: s}, nil
+			}
+			l.pos++
+		}
+		return token{}, errors.New("unterminated string")
+	case isOpChar(c):
+		for l.pos < len(l.input) && isOpChar(l.input[l.pos]) {
+			l.pos++
+		}
+		return token{typ: tokOp, val: l.input[start:l.pos]}, nil
+	}
+
+	for l.pos < len(l.input) && isWordChar(l.input[l.pos]) {
+		l.pos++
+	}
+
+	return token{typ: tokWord, val: l.input[start:l.pos]}, nil
+}
+
+// parser is a recursive descent parser of the grammar:
+//
+//	or         = and { "or" and }
+//	and        = not { "and" not }
+//	not        = "not" not | primary
+//	primary    = "(" or ")" | comparison
+//	comparison = field [ operator value ]
+type parser struct {
+	lexer *lexer
+	tok   token
+}
+
+func (p *parser) next() error {
+	tok, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+
+	p.tok = tok
+	return nil
+}
+
+// keyword returns true if the current token is the given keyword
+func (p *parser) keyword(k string) bool {
+	return p.tok.typ == tokWord && strings.EqualFold(p.tok.val, k)
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("or") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &orNode{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("and") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &andNode{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if !p.keyword("not") {
+		return p.parsePrimary()
+	}
+
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	n, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	return &notNode{node: n}, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	if p.tok.typ == tokLParen {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.tok.typ != tokRParen {
+			return nil, fmt.Errorf("expected ')', got %s", p.tok)
+		}
+
+		return n, p.next()
+	}
+
+	if p.tok.typ != tokWord || p.keyword("and") || p.keyword("or") {
+		return nil, fmt.Errorf("expected a field, got %s", p.tok)
+	}
+
+	field := strings.ToLower(p.tok.val)
+	if strings.HasPrefix(field, "tag:") {
+		// tag keys are case sensitive
+		field = "tag:" + p.tok.val[len("tag:"):]
+	}
+
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	if p.tok.typ != tokOp {
+		// fields without an operator, i.e: "tag:env"
+		return newComparison(field, "", "")
+	}
+
+	op := p.tok.val
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	if p.tok.typ != tokWord && p.tok.typ != tokString {
+		return nil, fmt.Errorf("expected a value for '%s%s', got %s", field, op, p.tok)
+	}
+
+	value := p.tok.val
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	return newComparison(field, op, value)
+}
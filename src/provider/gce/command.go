@@ -2,8 +2,13 @@ package gce
 
 import (
 	"errors"
+	"strings"
 
 	"command/loader"
+	"provider"
+	"provider/utils"
+
+	"golang.org/x/net/context"
 )
 
 // GceCommand implements the images various interfaces, such as Fetcher,
@@ -12,6 +17,15 @@ type GceCommand struct {
 	*GceImages
 }
 
+func init() {
+	provider.Register(&provider.Registration{
+		Name:         "gce",
+		New:          newProvider,
+		Capabilities: provider.CanList | provider.CanDelete | provider.CanModify | provider.CanWait | provider.CanSnapshots | provider.CanRollover,
+		Help:         Help,
+	})
+}
+
 // NewCommand returns a new instance of GceImages
 func NewCommand(args []string) (*GceCommand, []string, error) {
 	var conf struct {
@@ -35,22 +49,58 @@ func NewCommand(args []string) (*GceCommand, []string, error) {
 	}, remainingArgs, nil
 }
 
-// List implements the command.Lister interface
-func (g *GceCommand) List(args []string) error {
+// newProvider implements the provider.Factory func type
+func newProvider(args []string) (provider.Provider, []string, error) {
+	cmd, remainingArgs, err := NewCommand(args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cmd, remainingArgs, nil
+}
+
+// Name implements the provider.Provider interface
+func (g *GceCommand) Name() string { return "gce" }
+
+// Fetch implements the provider.Provider interface
+func (g *GceCommand) Fetch(ctx context.Context, args []string) (provider.Images, error) {
 	l := newListFlags()
 	if err := l.flagSet.Parse(args); err != nil {
-		return nil // we don't return error, the usage will be printed instead
+		return nil, err
 	}
 
-	images, err := g.ProjectImages()
+	var images Images
+	var err error
+	if l.family != "" {
+		images, err = g.FamilyImages(ctx, l.family)
+	} else {
+		images, err = g.ProjectImages(ctx)
+	}
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	return images.Normalize(), nil
+}
+
+// SelectArgs implements the command.Selector interface. GCE images are
+// selected by their names.
+func (g *GceCommand) SelectArgs(images provider.Images) []string {
+	names := make([]string, len(images))
+	for i, image := range images {
+		names[i] = image.Name
 	}
 
-	return images.Print(l.output)
+	return []string{"-names", strings.Join(names, ",")}
 }
 
-func (g *GceCommand) Delete(args []string) error {
+// SplitListArgs implements the command.ListArgsSplitter interface. Images are
+// selected from the given family only.
+func (g *GceCommand) SplitListArgs(args []string) (list, rest []string) {
+	return utils.SplitFlags(newListFlags().flagSet, args, "family")
+}
+
+func (g *GceCommand) Delete(ctx context.Context, args []string) error {
 	df := newDeleteOptions()
 	if err := df.flagSet.Parse(args); err != nil {
 		return nil // we don't return error, the usage will be printed instead
@@ -65,11 +115,11 @@ func (g *GceCommand) Delete(args []string) error {
 		return errors.New("no images are passed with [--names]")
 	}
 
-	return g.DeleteImages(df)
+	return g.DeleteImages(ctx, df)
 }
 
 // Modify renames the given images
-func (g *GceCommand) Modify(args []string) error {
+func (g *GceCommand) Modify(ctx context.Context, args []string) error {
 	m := newDeprecateOptions()
 	if err := m.flagSet.Parse(args); err != nil {
 		return nil // we don't return error, the usage will be printed instead
@@ -84,11 +134,54 @@ func (g *GceCommand) Modify(args []string) error {
 		return errors.New("no images are passed with [--names]")
 	}
 
-	return g.DeprecateImages(m)
+	return g.DeprecateImages(ctx, m)
+}
+
+// OrphanedSnapshots returns the snapshots of deleted disks.
+func (g *GceCommand) OrphanedSnapshots(ctx context.Context, args []string) (provider.Snapshots, error) {
+	return g.Orphans(ctx)
+}
+
+// Wait waits until the given images reach a status.
+func (g *GceCommand) Wait(ctx context.Context, args []string) error {
+	w := newWaitFlags()
+	if err := w.flagSet.Parse(args); err != nil {
+		return nil // we don't return error, the usage will be printed instead
+	}
+
+	if len(args) == 0 {
+		w.flagSet.Usage()
+		return nil
+	}
+
+	if len(w.names) == 0 {
+		return errors.New("no images are passed with [--names]")
+	}
+
+	return g.WaitImages(ctx, w.status, w.names...)
+}
+
+// Rollover deprecates the older images of a family in favor of the newest one.
+func (g *GceCommand) Rollover(ctx context.Context, args []string) error {
+	r := newRolloverOptions()
+	if err := r.flagSet.Parse(args); err != nil {
+		return nil // we don't return error, the usage will be printed instead
+	}
+
+	if len(args) == 0 {
+		r.flagSet.Usage()
+		return nil
+	}
+
+	if r.Family == "" {
+		return errors.New("no family is passed with [--family]")
+	}
+
+	return g.RolloverFamily(ctx, r)
 }
 
-// Help prints the help message for the given command
-func (g *GceCommand) Help(command string) string {
+// Help returns the help message for the given command
+func Help(command string) string {
 	var help string
 	switch command {
 	case "delete":
@@ -97,6 +190,10 @@ func (g *GceCommand) Help(command string) string {
 		help = newDeprecateOptions().helpMsg
 	case "list":
 		help = newListFlags().helpMsg
+	case "wait":
+		help = newWaitFlags().helpMsg
+	case "rollover":
+		help = newRolloverOptions().helpMsg
 	default:
 		return "no help found for command " + command
 	}
@@ -104,6 +201,7 @@ func (g *GceCommand) Help(command string) string {
 	global := `
   -project-id      "..."              Project Id (env: IMAGES_GCE_PROJECT_ID)
   -account-file    "..."              Account file (env: IMAGES_GCE_ACCOUNT_FILE)
+  -request-timeout "30s"              Timeout of a single request (env: IMAGES_GCE_REQUEST_TIMEOUT)
 `
 
 	help += global
@@ -7,8 +7,11 @@ import (
 	"os"
 	"sync"
 
+	"provider/utils"
+
 	"github.com/fatih/flags"
 	"github.com/hashicorp/go-multierror"
+	"golang.org/x/net/context"
 )
 
 type DeleteOptions struct {
@@ -32,7 +35,7 @@ Options:
   -names           "myImage,..."      Images to be deleted with the given names
 `
 	flagSet.Usage = func() {
-		fmt.Fprintf(os.Stderr, d.helpMsg)
+		fmt.Fprint(os.Stderr, d.helpMsg)
 	}
 
 	flagSet.SetOutput(ioutil.Discard) // don't print anything without my permission
@@ -41,27 +44,26 @@ Options:
 }
 
 // Delete deletes the given images.
-func (g *GceImages) DeleteImages(opts *DeleteOptions) error {
+func (g *GceImages) DeleteImages(ctx context.Context, opts *DeleteOptions) error {
 	var (
-		wg          sync.WaitGroup
 		mu          sync.Mutex // protects multiErrors
 		multiErrors error
 	)
 
-	for _, n := range opts.Names {
-		wg.Add(1)
-		go func(name string) {
-			_, err := g.svc.Delete(g.config.ProjectID, name).Do()
-			if err != nil {
-				mu.Lock()
-				multiErrors = multierror.Append(multiErrors, err)
-				mu.Unlock()
-			}
-
-			wg.Done()
-		}(n)
-	}
+	svc := g.svc(ctx)
+	tracker := utils.NewTracker(opts.Names...)
+
+	utils.ForEach(ctx, utils.Parallelism(ctx, g.parallelism), len(opts.Names), func(i int) {
+		name := opts.Names[i]
+		if _, err := svc.Delete(g.config.ProjectID, name).Do(); err != nil {
+			mu.Lock()
+			multiErrors = multierror.Append(multiErrors, err)
+			mu.Unlock()
+			return
+		}
+
+		tracker.Done(name)
+	})
 
-	wg.Wait()
-	return multiErrors
+	return tracker.Err(ctx, multiErrors)
 }
@@ -1,10 +1,13 @@
 package gce
 
 import (
+	"encoding/json"
 	"errors"
 	"io/ioutil"
 	"net/http"
-	"time"
+	"net/url"
+
+	"provider/utils"
 
 	"github.com/mitchellh/go-homedir"
 	"golang.org/x/net/context"
@@ -12,17 +15,40 @@ import (
 	"golang.org/x/oauth2/google"
 
 	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
 )
 
 type GCEConfig struct {
 	ProjectID   string `toml:"project_id" json:"project_id"`
 	AccountFile string `toml:"account_file" json:"account_file"`
+
+	// RequestTimeout is the timeout of a single request, i.e: "1m" (default:
+	// "30s"). The duration of a whole command is limited by the global
+	// timeout setting.
+	RequestTimeout string `toml:"request_timeout" json:"request_timeout"`
+
+	// Retries is the maximum number of retries of a failed or rate limited
+	// request (default: 5)
+	Retries int `toml:"retries" json:"retries" default:"5"`
+
+	// Parallelism is the maximum number of concurrent requests (default:
+	// the global parallelism)
+	Parallelism int `toml:"parallelism" json:"parallelism"`
 }
 
 // GceImages is responsible of managing GCE images
 type GceImages struct {
-	svc    *compute.ImagesService
 	config *GCEConfig
+
+	// client is the authenticated client, which is bound to a context for
+	// each call. It's also used directly for fields which are not
+	// supported by the compute package yet.
+	client *http.Client
+	retry  *utils.Retry
+
+	// parallelism is the parallelism setting of the provider, zero uses the
+	// global setting
+	parallelism int
 }
 
 // New returns a new instance of GceImages
@@ -32,12 +58,23 @@ func New(conf *GCEConfig) (*GceImages, error) {
 		return nil, errors.New("ProjectID is not set. Please check your configuration.")
 	}
 
-	// increase the timeout. Also we need to pass the client with the context itself
-	timeout := time.Second * 30
-	ctx := context.WithValue(oauth2.NoContext, oauth2.HTTPClient, &http.Client{
-		Transport: &http.Transport{TLSHandshakeTimeout: timeout},
-		Timeout:   timeout,
-	})
+	timeout, err := utils.ParseRequestTimeout(conf.RequestTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	retry, err := utils.NewRetry(conf.Retries)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := utils.ValidateParallelism(conf.Parallelism); err != nil {
+		return nil, err
+	}
+
+	// the oauth2 client uses only the transport of the given client, which
+	// applies the timeout to each attempt of a request
+	ctx := context.WithValue(oauth2.NoContext, oauth2.HTTPClient, utils.NewRetryClient(timeout, retry))
 
 	var client *http.Client
 
@@ -74,18 +111,112 @@ func New(conf *GCEConfig) (*GceImages, error) {
 		}
 	}
 
-	svc, err := compute.New(client)
+	return &GceImages{
+		config:      conf,
+		client:      client,
+		retry:       retry,
+		parallelism: conf.Parallelism,
+	}, nil
+}
+
+// Retries returns the number of retried requests and how many of them were
+// rate limited.
+func (g *GceImages) Retries() (total, throttled int) {
+	return g.retry.Retries()
+}
+
+// svc returns the images service whose requests are canceled once ctx is
+// done.
+func (g *GceImages) svc(ctx context.Context) *compute.ImagesService {
+	// New fails only for a nil client
+	svc, _ := compute.New(utils.WithContext(ctx, g.client))
+	return compute.NewImagesService(svc)
+}
+
+// ProjectImages returns all images of the project. The images are fetched
+// directly from the API, so the families are decoded too.
+func (g *GceImages) ProjectImages(ctx context.Context) (Images, error) {
+	images := Images{Families: make(map[string]string)}
+	client := utils.WithContext(ctx, g.client)
+
+	pageToken := ""
+	for {
+		u := imagesURL(g.config.ProjectID, "") + "?pageToken=" + url.QueryEscape(pageToken)
+		resp, err := client.Get(u)
+		if err != nil {
+			return Images{}, err
+		}
+
+		var list struct {
+			Items         []json.RawMessage `json:"items"`
+			NextPageToken string            `json:"nextPageToken"`
+		}
+
+		if err := decodeResponse(resp, &list); err != nil {
+			return Images{}, err
+		}
+
+		for _, item := range list.Items {
+			image := &compute.Image{}
+			if err := json.Unmarshal(item, image); err != nil {
+				return Images{}, err
+			}
+
+			var v struct {
+				Family string `json:"family"`
+			}
+			if err := json.Unmarshal(item, &v); err != nil {
+				return Images{}, err
+			}
+
+			images.Items = append(images.Items, image)
+			if v.Family != "" {
+				images.Families[image.Name] = v.Family
+			}
+		}
+
+		if pageToken = list.NextPageToken; pageToken == "" {
+			return images, nil
+		}
+	}
+}
+
+// FamilyImages returns the images of the given family.
+func (g *GceImages) FamilyImages(ctx context.Context, family string) (Images, error) {
+	images, err := g.ProjectImages(ctx)
 	if err != nil {
-		return nil, err
+		return Images{}, err
 	}
 
-	return &GceImages{
-		svc:    compute.NewImagesService(svc),
-		config: conf,
-	}, nil
+	familyImages := Images{Families: make(map[string]string)}
+	for _, image := range images.Items {
+		if images.Families[image.Name] == family {
+			familyImages.Items = append(familyImages.Items, image)
+			familyImages.Families[image.Name] = family
+		}
+	}
+
+	return familyImages, nil
 }
 
-func (g *GceImages) ProjectImages() (Images, error) {
-	images, err := g.svc.List(g.config.ProjectID).Do()
-	return Images(*images), err
+// imagesURL returns the URL of the images of the given project or of a
+// single image if name is set.
+func imagesURL(project, name string) string {
+	u := "https://www.googleapis.com/compute/v1/projects/" + project + "/global/images"
+	if name != "" {
+		u += "/" + name
+	}
+	return u
+}
+
+// decodeResponse decodes the JSON body of the given response into v and
+// closes it. Error responses are returned as *googleapi.Error.
+func decodeResponse(resp *http.Response, v interface{}) error {
+	defer resp.Body.Close()
+
+	if err := googleapi.CheckResponse(resp); err != nil {
+		return err
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
 }
@@ -1,73 +1,47 @@
 package gce
 
 import (
-	"encoding/json"
-	"errors"
-	"fmt"
-	"os"
+	"strconv"
+	"time"
 
-	"provider/utils"
+	"provider"
 
-	"github.com/fatih/color"
-	"github.com/shiena/ansicolor"
 	compute "google.golang.org/api/compute/v1"
 )
 
-type Images compute.ImageList
+// Images are the images of a project. The compute package doesn't support
+// image families, therefore they are decoded separately.
+type Images struct {
+	Items []*compute.Image
 
-// Print prints the stored images to standard output.
-func (i Images) Print(mode utils.OutputMode) error {
-	if len(i.Items) == 0 {
-		return errors.New("no images found")
-	}
-
-	switch mode {
-	case utils.JSON:
-		out, err := i.outputJSON()
-		if err != nil {
-			return err
-		}
-
-		fmt.Println(out)
-		return nil
-	case utils.Simplified:
-		green := color.New(color.FgGreen).SprintfFunc()
-		output := ansicolor.NewAnsiColorWriter(os.Stdout)
-		w := utils.NewImagesTabWriter(output)
-		defer w.Flush()
+	// Families are the families of the images by their names
+	Families map[string]string
+}
 
-		imageDesc := "image"
-		if len(i.Items) > 1 {
-			imageDesc = "images"
+// Normalize returns the provider agnostic representation of the images. GCE
+// images are global resources, therefore the region is always "global".
+func (i Images) Normalize() provider.Images {
+	images := make(provider.Images, len(i.Items))
+	for ix, image := range i.Items {
+		img := &provider.Image{
+			Provider: "gce",
+			Region:   "global",
+			ID:       strconv.FormatUint(image.Id, 10),
+			Name:     image.Name,
+			Family:   i.Families[image.Name],
+			State:    image.Status,
+			Size:     image.DiskSizeGb,
+			Raw:      image,
 		}
 
-		fmt.Fprintln(w, green("GCE (%d %s):", len(i.Items), imageDesc))
-		fmt.Fprintln(w, "    Name\tID\tStatus\tType\tDeprecated\tCreation Timestamp")
+		img.CreatedAt, _ = time.Parse(time.RFC3339, image.CreationTimestamp)
 
-		for ix, image := range i.Items {
-			deprecatedState := ""
-			if image.Deprecated != nil {
-				deprecatedState = image.Deprecated.State
+		if image.Deprecated != nil {
+			if t, err := time.Parse(time.RFC3339, image.Deprecated.Deprecated); err == nil {
+				img.DeprecatedAt = &t
 			}
-
-			fmt.Fprintf(w, "[%d] %s (%s)\t%d\t%s\t%s (%d)\t%s\t%s\n",
-				ix+1, image.Name, image.Description, image.Id,
-				image.Status, image.SourceType, image.DiskSizeGb,
-				deprecatedState, image.CreationTimestamp,
-			)
 		}
-
-		return nil
-	default:
-		return fmt.Errorf("output mode '%s' is not valid", mode)
-	}
-}
-
-// outputJSON returns a JSON formatted output of all images
-func (i Images) outputJSON() (string, error) {
-	out, err := json.MarshalIndent(&i, "", "    ")
-	if err != nil {
-		return "", err
+		images[ix] = img
 	}
-	return string(out), nil
+	return images
 }
@@ -11,6 +11,7 @@ import (
 
 type listFlags struct {
 	output  utils.OutputMode
+	family  string
 	helpMsg string
 	flagSet *flag.FlagSet
 }
@@ -20,6 +21,7 @@ func newListFlags() *listFlags {
 
 	flagSet := flag.NewFlagSet("copy", flag.ContinueOnError)
 	flagSet.Var(utils.NewOutputValue(utils.Simplified, &l.output), "output", "Output mode")
+	flagSet.StringVar(&l.family, "family", "", "List only images of the given family")
 	l.helpMsg = `Usage: images list --providers gce [options]
 
    List images
@@ -27,11 +29,12 @@ func newListFlags() *listFlags {
 Options:
 
   -output  "json"              Output mode of images. (default: "simplified")
-                               Available options: "json","table" or "simplified" 
+                               Available options: ` + utils.OutputsHelp + `
+  -family  "myFamily"          List only images of the given family
 `
 
 	flagSet.Usage = func() {
-		fmt.Fprintf(os.Stderr, l.helpMsg)
+		fmt.Fprint(os.Stderr, l.helpMsg)
 	}
 	flagSet.SetOutput(ioutil.Discard) // don't print anything without my permission
 	l.flagSet = flagSet
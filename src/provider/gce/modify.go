@@ -5,17 +5,31 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 	"sync"
+	"time"
+
+	"provider/utils"
 
 	compute "google.golang.org/api/compute/v1"
 
 	"github.com/fatih/flags"
 	"github.com/hashicorp/go-multierror"
+	"golang.org/x/net/context"
+)
+
+// deprecation states of GCE images
+const (
+	stateActive     = "ACTIVE"
+	stateDeprecated = "DEPRECATED"
 )
 
 type DeprecateOptions struct {
-	Names []string
-	State string
+	Names       []string
+	State       string
+	Replacement string
+	ObsoleteAt  string
+	DeletedAt   string
 
 	helpMsg string
 	flagSet *flag.FlagSet
@@ -25,20 +39,32 @@ func newDeprecateOptions() *DeprecateOptions {
 	m := &DeprecateOptions{}
 
 	flagSet := flag.NewFlagSet("modify", flag.ContinueOnError)
-	flagSet.Var(flags.NewStringSlice(nil, &m.Names), "ids", "Images to be delete with the given names")
+	flagSet.Var(flags.NewStringSlice(nil, &m.Names), "names", "Images to be deprecated with the given names")
 	flagSet.StringVar(&m.State, "state", "", "Image state to be applied")
+	flagSet.StringVar(&m.Replacement, "replacement", "", "Image which replaces the deprecated images")
+	flagSet.StringVar(&m.ObsoleteAt, "obsolete-at", "", "Time when the images become obsolete")
+	flagSet.StringVar(&m.DeletedAt, "deleted-at", "", "Time when the images become deleted")
 	m.helpMsg = `Usage: images modify --providers gce [options]
 
   Depcreate images
 
 Options:
 
-  -names    "myImage,..."   Images to be deprecated
-  -state    "..."           Image state to be applied. Possible values:
-                            DELETED, DEPRECATED, OBSOLETE or "" (to clear state)
+  -names        "myImage,..."   Images to be deprecated
+  -state        "..."           Image state to be applied. Possible values:
+                                DELETED, DEPRECATED, OBSOLETE or "" (to clear state)
+  -replacement  "myImage"       Image which replaces the deprecated images, either
+                                a name of the same project or an image URL
+  -obsolete-at  "2018-06-01"    Time when the images become obsolete. A date, a
+                                RFC3339 time or a duration from now, i.e: "30d"
+  -deleted-at   "90d"           Time when the images become deleted, in the same
+                                format as -obsolete-at
+
+  The state defaults to DEPRECATED if -replacement, -obsolete-at or
+  -deleted-at is given.
 `
 	flagSet.Usage = func() {
-		fmt.Fprintf(os.Stderr, m.helpMsg)
+		fmt.Fprint(os.Stderr, m.helpMsg)
 	}
 
 	flagSet.SetOutput(ioutil.Discard) // don't print anything without my permission
@@ -47,31 +73,82 @@ Options:
 }
 
 // Modify renames the given images
-func (g *GceImages) DeprecateImages(opts *DeprecateOptions) error {
+func (g *GceImages) DeprecateImages(ctx context.Context, opts *DeprecateOptions) error {
 	var (
-		wg          sync.WaitGroup
 		mu          sync.Mutex // protects multiErrors
 		multiErrors error
 	)
 
-	for _, n := range opts.Names {
-		wg.Add(1)
-		go func(name string) {
-			st := &compute.DeprecationStatus{
-				State: opts.State,
-			}
-
-			_, err := g.svc.Deprecate(g.config.ProjectID, name, st).Do()
-			if err != nil {
-				mu.Lock()
-				multiErrors = multierror.Append(multiErrors, err)
-				mu.Unlock()
-			}
-
-			wg.Done()
-		}(n)
+	st, err := opts.status(g.config.ProjectID)
+	if err != nil {
+		return err
+	}
+
+	svc := g.svc(ctx)
+	tracker := utils.NewTracker(opts.Names...)
+
+	utils.ForEach(ctx, utils.Parallelism(ctx, g.parallelism), len(opts.Names), func(i int) {
+		name := opts.Names[i]
+		if _, err := svc.Deprecate(g.config.ProjectID, name, st).Do(); err != nil {
+			mu.Lock()
+			multiErrors = multierror.Append(multiErrors, err)
+			mu.Unlock()
+			return
+		}
+
+		tracker.Done(name)
+	})
+
+	return tracker.Err(ctx, multiErrors)
+}
+
+// status returns the deprecation status described by the options. The
+// replacement is resolved to an image URL of the given project if it's just a
+// name.
+func (d *DeprecateOptions) status(project string) (*compute.DeprecationStatus, error) {
+	st := &compute.DeprecationStatus{
+		State:       d.State,
+		Replacement: replacementURL(project, d.Replacement),
+	}
+
+	var err error
+	if st.Obsolete, err = formatTime(d.ObsoleteAt); err != nil {
+		return nil, err
+	}
+
+	if st.Deleted, err = formatTime(d.DeletedAt); err != nil {
+		return nil, err
+	}
+
+	if st.State == "" && (st.Replacement != "" || st.Obsolete != "" || st.Deleted != "") {
+		st.State = stateDeprecated
+	}
+
+	return st, nil
+}
+
+// replacementURL returns the URL of the replacement image. Names are
+// resolved within the given project, URLs are returned as they are.
+func replacementURL(project, replacement string) string {
+	if replacement == "" || strings.Contains(replacement, "/") {
+		return replacement
+	}
+
+	return imagesURL(project, replacement)
+}
+
+// formatTime parses the given time in the format of utils.ParseTime and
+// returns it in RFC3339, as expected by the deprecation status. An empty
+// string is returned as it is.
+func formatTime(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+
+	t, err := utils.ParseTime(s)
+	if err != nil {
+		return "", err
 	}
 
-	wg.Wait()
-	return multiErrors
+	return t.UTC().Format(time.RFC3339), nil
 }
@@ -0,0 +1,74 @@
+package gce
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+	compute "google.golang.org/api/compute/v1"
+)
+
+func TestDeprecateOptionsStatus(t *testing.T) {
+	tests := []struct {
+		opts *DeprecateOptions
+		want compute.DeprecationStatus
+	}{
+		{
+			opts: &DeprecateOptions{},
+			want: compute.DeprecationStatus{},
+		},
+		{
+			opts: &DeprecateOptions{State: "OBSOLETE"},
+			want: compute.DeprecationStatus{State: "OBSOLETE"},
+		},
+		{
+			opts: &DeprecateOptions{Replacement: "base-2"},
+			want: compute.DeprecationStatus{State: "DEPRECATED", Replacement: imagesURL("project", "base-2")},
+		},
+		{
+			opts: &DeprecateOptions{Replacement: "projects/other/global/images/base", DeletedAt: "2030-06-01"},
+			want: compute.DeprecationStatus{
+				State:       "DEPRECATED",
+				Replacement: "projects/other/global/images/base",
+				Deleted:     "2030-06-01T00:00:00Z",
+			},
+		},
+		{
+			opts: &DeprecateOptions{State: "DELETED", ObsoleteAt: "2030-06-01T12:00:00+02:00"},
+			want: compute.DeprecationStatus{State: "DELETED", Obsolete: "2030-06-01T10:00:00Z"},
+		},
+	}
+
+	for _, test := range tests {
+		st, err := test.opts.status("project")
+		if err != nil {
+			t.Errorf("%+v: %s", test.opts, err)
+			continue
+		}
+
+		if st.State != test.want.State || st.Replacement != test.want.Replacement ||
+			st.Obsolete != test.want.Obsolete || st.Deleted != test.want.Deleted {
+			t.Errorf("%+v: status is %+v, want %+v", test.opts, st, test.want)
+		}
+	}
+
+	if _, err := (&DeprecateOptions{ObsoleteAt: "someday"}).status("project"); err == nil {
+		t.Error("no error for an invalid obsolete time")
+	}
+}
+
+func TestDeprecateImages(t *testing.T) {
+	f, g := newFakeGCE(t)
+	defer f.Close()
+
+	opts := &DeprecateOptions{Names: []string{"base-1", "base-2"}, Replacement: "base-3"}
+	if err := g.DeprecateImages(context.Background(), opts); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range opts.Names {
+		st := f.deprecations[name]
+		if st == nil || st.State != "DEPRECATED" || st.Replacement != imagesURL("project", "base-3") {
+			t.Errorf("status of %s is %+v, want deprecated in favor of base-3", name, st)
+		}
+	}
+}
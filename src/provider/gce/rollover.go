@@ -0,0 +1,236 @@
+package gce
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"provider/utils"
+
+	compute "google.golang.org/api/compute/v1"
+
+	"github.com/hashicorp/go-multierror"
+	"golang.org/x/net/context"
+)
+
+type RolloverOptions struct {
+	Family     string
+	ObsoleteAt string
+	DeletedAt  string
+
+	// DryRun doesn't run the command, but shows the action
+	DryRun bool
+
+	helpMsg string
+	flagSet *flag.FlagSet
+}
+
+func newRolloverOptions() *RolloverOptions {
+	r := &RolloverOptions{}
+
+	flagSet := flag.NewFlagSet("rollover", flag.ContinueOnError)
+	flagSet.StringVar(&r.Family, "family", "", "Family of the images")
+	flagSet.StringVar(&r.ObsoleteAt, "obsolete-at", "", "Time when the older images become obsolete")
+	flagSet.StringVar(&r.DeletedAt, "deleted-at", "", "Time when the older images become deleted")
+	flagSet.BoolVar(&r.DryRun, "dry-run", false, "Don't run command, but show the action")
+	r.helpMsg = `Usage: images gce rollover [options]
+
+  Deprecate the older images of a family with the newest image as replacement.
+  The newest image is the latest ready image which isn't deprecated. Already
+  deprecated images keep their state, but point to the new replacement.
+
+Options:
+
+  -family       "myFamily"      Family of the images
+  -obsolete-at  "30d"           Time when the older images become obsolete. A
+                                date, a RFC3339 time or a duration from now
+  -deleted-at   "90d"           Time when the older images become deleted, in
+                                the same format as -obsolete-at
+  -dry-run                      Don't run command, but show the action
+`
+	flagSet.Usage = func() {
+		fmt.Fprint(os.Stderr, r.helpMsg)
+	}
+
+	flagSet.SetOutput(ioutil.Discard) // don't print anything without my permission
+	r.flagSet = flagSet
+	return r
+}
+
+// rollover is the deprecation status change of a single image.
+type rollover struct {
+	image  *compute.Image
+	status *compute.DeprecationStatus
+}
+
+// RolloverFamily deprecates all images of the family which are older than the
+// newest image and sets the newest image as their replacement.
+func (g *GceImages) RolloverFamily(ctx context.Context, opts *RolloverOptions) error {
+	obsolete, err := formatTime(opts.ObsoleteAt)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := formatTime(opts.DeletedAt)
+	if err != nil {
+		return err
+	}
+
+	images, err := g.FamilyImages(ctx, opts.Family)
+	if err != nil {
+		return err
+	}
+
+	if len(images.Items) == 0 {
+		return fmt.Errorf("no images found in family '%s'", opts.Family)
+	}
+
+	newest, created := newestImage(images.Items)
+	if newest == nil {
+		return fmt.Errorf("family '%s' has no ready image which isn't deprecated", opts.Family)
+	}
+
+	replacement := imagesURL(g.config.ProjectID, newest.Name)
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	var rollovers []*rollover
+	for _, image := range images.Items {
+		if image == newest || !createdAt(image).Before(created) {
+			continue
+		}
+
+		st := &compute.DeprecationStatus{
+			State:       stateDeprecated,
+			Deprecated:  now,
+			Replacement: replacement,
+			Obsolete:    obsolete,
+			Deleted:     deleted,
+		}
+
+		if d := image.Deprecated; d != nil && d.State != "" && d.State != stateActive {
+			// obsolete and deleted images are already past the rollover
+			if d.State != stateDeprecated {
+				continue
+			}
+
+			if d.Replacement == replacement && obsolete == "" && deleted == "" {
+				continue
+			}
+
+			st.Deprecated = d.Deprecated
+			if st.Obsolete == "" {
+				st.Obsolete = d.Obsolete
+			}
+			if st.Deleted == "" {
+				st.Deleted = d.Deleted
+			}
+		}
+
+		rollovers = append(rollovers, &rollover{image: image, status: st})
+	}
+
+	if len(rollovers) == 0 {
+		fmt.Printf("All older images of family '%s' are already replaced by '%s'\n", opts.Family, newest.Name)
+		return nil
+	}
+
+	sort.Slice(rollovers, func(i, j int) bool {
+		return createdAt(rollovers[i].image).After(createdAt(rollovers[j].image))
+	})
+
+	printRollovers(opts.Family, newest, rollovers)
+	if opts.DryRun {
+		return nil
+	}
+
+	var (
+		mu          sync.Mutex // protects multiErrors
+		multiErrors error
+	)
+
+	svc := g.svc(ctx)
+	names := make([]string, len(rollovers))
+	for i, r := range rollovers {
+		names[i] = r.image.Name
+	}
+	tracker := utils.NewTracker(names...)
+
+	utils.ForEach(ctx, utils.Parallelism(ctx, g.parallelism), len(rollovers), func(i int) {
+		r := rollovers[i]
+		if _, err := svc.Deprecate(g.config.ProjectID, r.image.Name, r.status).Do(); err != nil {
+			mu.Lock()
+			multiErrors = multierror.Append(multiErrors, err)
+			mu.Unlock()
+			return
+		}
+
+		tracker.Done(r.image.Name)
+	})
+
+	return tracker.Err(ctx, multiErrors)
+}
+
+// newestImage returns the latest ready image which isn't deprecated and its
+// creation time.
+func newestImage(images []*compute.Image) (*compute.Image, time.Time) {
+	var (
+		newest  *compute.Image
+		created time.Time
+	)
+
+	for _, image := range images {
+		if image.Status != statusReady {
+			continue
+		}
+
+		if d := image.Deprecated; d != nil && d.State != "" && d.State != stateActive {
+			continue
+		}
+
+		if t := createdAt(image); newest == nil || t.After(created) {
+			newest, created = image, t
+		}
+	}
+
+	return newest, created
+}
+
+func createdAt(image *compute.Image) time.Time {
+	t, _ := time.Parse(time.RFC3339, image.CreationTimestamp)
+	return t
+}
+
+func printRollovers(family string, newest *compute.Image, rollovers []*rollover) {
+	fmt.Printf("Deprecating %d image(s) of family '%s' in favor of '%s':\n\n",
+		len(rollovers), family, newest.Name)
+
+	w := utils.NewImagesTabWriter(os.Stdout)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "    NAME\tCREATED\tSTATE\tOBSOLETE\tDELETED")
+	for _, r := range rollovers {
+		state := stateActive
+		if d := r.image.Deprecated; d != nil && d.State != "" {
+			state = d.State
+		}
+
+		fmt.Fprintf(w, "    %s\t%s\t%s -> %s\t%s\t%s\n",
+			r.image.Name,
+			createdAt(r.image).Format(time.RFC3339),
+			state, r.status.State,
+			orDash(r.status.Obsolete),
+			orDash(r.status.Deleted),
+		)
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
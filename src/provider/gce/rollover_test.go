@@ -0,0 +1,174 @@
+package gce
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/context"
+	compute "google.golang.org/api/compute/v1"
+)
+
+// fakeTransport sends all requests to the fake API
+type fakeTransport struct{ url *url.URL }
+
+func (f fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.URL.Scheme, req.URL.Host = f.url.Scheme, f.url.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// fakeGCE is a fake images API of the project "project", which lists the
+// given images and records the deprecation status of each deprecated image.
+type fakeGCE struct {
+	server *httptest.Server
+
+	mu           sync.Mutex
+	deprecations map[string]*compute.DeprecationStatus
+}
+
+func newFakeGCE(t *testing.T, images ...string) (*fakeGCE, *GceImages) {
+	f := &fakeGCE{deprecations: make(map[string]*compute.DeprecationStatus)}
+	f.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const prefix = "/compute/v1/projects/project/global/images"
+
+		switch {
+		case r.Method == "GET" && r.URL.Path == prefix:
+			fmt.Fprintf(w, `{"items": [%s]}`, strings.Join(images, ","))
+		case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/deprecate"):
+			name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, prefix+"/"), "/deprecate")
+
+			st := &compute.DeprecationStatus{}
+			if err := json.NewDecoder(r.Body).Decode(st); err != nil {
+				t.Errorf("decoding the status of %s: %s", name, err)
+			}
+
+			f.mu.Lock()
+			f.deprecations[name] = st
+			f.mu.Unlock()
+
+			fmt.Fprint(w, `{"name": "operation"}`)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			http.NotFound(w, r)
+		}
+	}))
+
+	u, _ := url.Parse(f.server.URL)
+	return f, &GceImages{
+		config: &GCEConfig{ProjectID: "project"},
+		client: &http.Client{Transport: fakeTransport{u}},
+	}
+}
+
+func (f *fakeGCE) Close() { f.server.Close() }
+
+// image returns the JSON of an image of the given family, created at the
+// first of the given month of 2030, with an optional deprecation status.
+func image(name, family, status string, month int, deprecated string) string {
+	s := fmt.Sprintf(`{"name": %q, "family": %q, "status": %q, "creationTimestamp": "2030-%02d-01T00:00:00Z"`,
+		name, family, status, month)
+	if deprecated != "" {
+		s += `, "deprecated": ` + deprecated
+	}
+	return s + "}"
+}
+
+func TestRolloverFamily(t *testing.T) {
+	replacement := imagesURL("project", "base-3")
+
+	f, g := newFakeGCE(t,
+		image("base-4", "base", "PENDING", 4, ""),
+		image("base-3", "base", "READY", 3, ""),
+		image("base-2", "base", "READY", 2, `{"state": "ACTIVE"}`),
+		image("base-1", "base", "READY", 1,
+			`{"state": "DEPRECATED", "deprecated": "2030-02-05T00:00:00Z", "replacement": "old", "deleted": "2031-01-01T00:00:00Z"}`),
+		image("base-0", "base", "READY", 1, `{"state": "OBSOLETE"}`),
+		image("other-1", "other", "READY", 1, ""),
+		image("unrelated", "", "READY", 1, ""),
+	)
+	defer f.Close()
+
+	err := g.RolloverFamily(context.Background(), &RolloverOptions{Family: "base", ObsoleteAt: "2030-06-01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.deprecations) != 2 {
+		t.Fatalf("deprecated images are %v, want base-1 and base-2", f.deprecations)
+	}
+
+	st := f.deprecations["base-2"]
+	if st == nil || st.State != "DEPRECATED" || st.Replacement != replacement ||
+		st.Obsolete != "2030-06-01T00:00:00Z" || st.Deleted != "" || st.Deprecated == "" {
+		t.Errorf("status of base-2 is %+v, want deprecated now in favor of base-3", st)
+	}
+
+	// an already deprecated image keeps its deprecation time and the
+	// timestamps which aren't given
+	st = f.deprecations["base-1"]
+	if st == nil || st.State != "DEPRECATED" || st.Replacement != replacement ||
+		st.Deprecated != "2030-02-05T00:00:00Z" || st.Obsolete != "2030-06-01T00:00:00Z" ||
+		st.Deleted != "2031-01-01T00:00:00Z" {
+		t.Errorf("status of base-1 is %+v, want the new replacement and obsolete time", st)
+	}
+}
+
+func TestRolloverFamilyReplaced(t *testing.T) {
+	f, g := newFakeGCE(t,
+		image("base-2", "base", "READY", 2, ""),
+		image("base-1", "base", "READY", 1,
+			fmt.Sprintf(`{"state": "DEPRECATED", "replacement": %q}`, imagesURL("project", "base-2"))),
+	)
+	defer f.Close()
+
+	if err := g.RolloverFamily(context.Background(), &RolloverOptions{Family: "base"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.deprecations) != 0 {
+		t.Errorf("deprecated images are %v, want none", f.deprecations)
+	}
+}
+
+func TestRolloverFamilyDryRun(t *testing.T) {
+	f, g := newFakeGCE(t,
+		image("base-2", "base", "READY", 2, ""),
+		image("base-1", "base", "READY", 1, ""),
+	)
+	defer f.Close()
+
+	if err := g.RolloverFamily(context.Background(), &RolloverOptions{Family: "base", DryRun: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.deprecations) != 0 {
+		t.Errorf("deprecated images are %v, want none for a dry run", f.deprecations)
+	}
+}
+
+func TestRolloverFamilyErrors(t *testing.T) {
+	f, g := newFakeGCE(t,
+		image("base-2", "base", "PENDING", 2, ""),
+		image("base-1", "base", "READY", 1, `{"state": "DEPRECATED"}`),
+	)
+	defer f.Close()
+
+	for _, test := range []struct {
+		opts *RolloverOptions
+		err  string
+	}{
+		{&RolloverOptions{Family: "base"}, "family 'base' has no ready image which isn't deprecated"},
+		{&RolloverOptions{Family: "other"}, "no images found in family 'other'"},
+		{&RolloverOptions{Family: "base", DeletedAt: "someday"}, "someday"},
+	} {
+		err := g.RolloverFamily(context.Background(), test.opts)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%+v: error is %v, want %q", test.opts, err, test.err)
+		}
+	}
+}
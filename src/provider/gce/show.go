@@ -0,0 +1,44 @@
+package gce
+
+import (
+	"fmt"
+	"strconv"
+
+	"provider"
+
+	"golang.org/x/net/context"
+	compute "google.golang.org/api/compute/v1"
+)
+
+// Describe returns all attributes of the given image, including its family,
+// licenses and deprecation status.
+func (g *GceImages) Describe(ctx context.Context, image *provider.Image) (*provider.Details, error) {
+	raw, ok := image.Raw.(*compute.Image)
+	if !ok {
+		return nil, fmt.Errorf("image '%s' is not a GCE image", image.Name)
+	}
+
+	d := provider.NewDetails(image)
+	d.Add("Description", raw.Description)
+	d.Add("Family", image.Family)
+	d.Add("Licenses", raw.Licenses...)
+	if raw.ArchiveSizeBytes != 0 {
+		d.Add("Archive size", strconv.FormatInt(raw.ArchiveSizeBytes, 10)+" bytes")
+	}
+	d.Add("Source type", raw.SourceType)
+	d.Add("Source disk", raw.SourceDisk)
+	if raw.RawDisk != nil {
+		d.Add("Raw disk", raw.RawDisk.Source)
+	}
+
+	if dep := raw.Deprecated; dep != nil {
+		d.Add("Deprecation state", dep.State)
+		d.Add("Replacement", dep.Replacement)
+		d.Add("Deprecated at", dep.Deprecated)
+		d.Add("Obsolete at", dep.Obsolete)
+		d.Add("Deleted at", dep.Deleted)
+	}
+
+	d.Add("Self link", raw.SelfLink)
+	return d, nil
+}
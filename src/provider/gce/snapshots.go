@@ -0,0 +1,116 @@
+package gce
+
+import (
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"provider"
+	"provider/utils"
+
+	"github.com/hashicorp/go-multierror"
+	"golang.org/x/net/context"
+	compute "google.golang.org/api/compute/v1"
+)
+
+// Orphans returns the snapshots whose source disk doesn't exist anymore and
+// which aren't used by any disk. Snapshots are global resources, therefore
+// the region is always "global".
+func (g *GceImages) Orphans(ctx context.Context) (provider.Snapshots, error) {
+	// New fails only for a nil client
+	svc, _ := compute.New(utils.WithContext(ctx, g.client))
+
+	disks := make(map[string]bool)
+	used := make(map[string]bool)
+
+	pageToken := ""
+	for {
+		list, err := compute.NewDisksService(svc).AggregatedList(g.config.ProjectID).PageToken(pageToken).Do()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, scoped := range list.Items {
+			for _, disk := range scoped.Disks {
+				disks[strconv.FormatUint(disk.Id, 10)] = true
+				if disk.SourceSnapshotId != "" {
+					used[disk.SourceSnapshotId] = true
+				}
+			}
+		}
+
+		if pageToken = list.NextPageToken; pageToken == "" {
+			break
+		}
+	}
+
+	var orphans provider.Snapshots
+	for {
+		list, err := compute.NewSnapshotsService(svc).List(g.config.ProjectID).PageToken(pageToken).Do()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, snapshot := range list.Items {
+			id := strconv.FormatUint(snapshot.Id, 10)
+			if snapshot.SourceDiskId == "" || disks[snapshot.SourceDiskId] || used[id] {
+				continue
+			}
+
+			orphan := &provider.Snapshot{
+				Provider: "gce",
+				Region:   "global",
+				ID:       id,
+				Name:     snapshot.Name,
+				Size:     snapshot.DiskSizeGb,
+				Raw:      snapshot,
+			}
+
+			if snapshot.SourceDisk != "" {
+				orphan.Source = path.Base(snapshot.SourceDisk)
+			}
+
+			orphan.CreatedAt, _ = time.Parse(time.RFC3339, snapshot.CreationTimestamp)
+			orphans = append(orphans, orphan)
+		}
+
+		if pageToken = list.NextPageToken; pageToken == "" {
+			return orphans, nil
+		}
+	}
+}
+
+// DeleteSnapshots deletes the given snapshots by their names. If ctx is done
+// before all snapshots are deleted, the returned error reports the deleted
+// snapshots.
+func (g *GceImages) DeleteSnapshots(ctx context.Context, snapshots provider.Snapshots) error {
+	var (
+		mu          sync.Mutex // protects multiErrors
+		multiErrors error
+	)
+
+	names := make([]string, len(snapshots))
+	for i, snapshot := range snapshots {
+		names[i] = snapshot.Name
+	}
+
+	// New fails only for a nil client
+	svc, _ := compute.New(utils.WithContext(ctx, g.client))
+	snapshotsSvc := compute.NewSnapshotsService(svc)
+	tracker := utils.NewTracker(names...)
+
+	utils.ForEach(ctx, utils.Parallelism(ctx, g.parallelism), len(names), func(i int) {
+		name := names[i]
+		if _, err := snapshotsSvc.Delete(g.config.ProjectID, name).Do(); err != nil {
+			mu.Lock()
+			multiErrors = multierror.Append(multiErrors, err)
+			mu.Unlock()
+			return
+		}
+
+		tracker.Done(name)
+	})
+
+	return tracker.Err(ctx, multiErrors)
+}
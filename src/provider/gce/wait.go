@@ -0,0 +1,97 @@
+package gce
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"provider/utils"
+
+	"github.com/fatih/flags"
+	"golang.org/x/net/context"
+)
+
+// states of GCE images
+const (
+	statusPending = "PENDING"
+	statusReady   = "READY"
+	statusFailed  = "FAILED"
+)
+
+type waitFlags struct {
+	names  []string
+	status string
+
+	helpMsg string
+	flagSet *flag.FlagSet
+}
+
+func newWaitFlags() *waitFlags {
+	w := &waitFlags{}
+
+	flagSet := flag.NewFlagSet("wait", flag.ContinueOnError)
+	flagSet.Var(flags.NewStringSlice(nil, &w.names), "names", "Images to wait for with the given names")
+	flagSet.StringVar(&w.status, "state", statusReady, "Status to wait for")
+	w.helpMsg = `Usage: images wait --providers gce [options]
+
+  Wait until images reach a status
+
+Options:
+
+  -names           "myImage,..."      Images to wait for with the given names
+  -state           "READY"            Status to wait for (default: "READY")
+`
+	flagSet.Usage = func() {
+		fmt.Fprint(os.Stderr, w.helpMsg)
+	}
+
+	flagSet.SetOutput(ioutil.Discard) // don't print anything without my permission
+	w.flagSet = flagSet
+	return w
+}
+
+// WaitImages waits until all given images reach the given status and prints
+// the status of each image. It fails once an image fails.
+func (g *GceImages) WaitImages(ctx context.Context, status string, names ...string) error {
+	status = strings.ToUpper(status)
+	switch status {
+	case statusPending, statusReady, statusFailed:
+	default:
+		return fmt.Errorf("invalid image status '%s'", status)
+	}
+
+	svc := g.svc(ctx)
+	progress := utils.NewProgress(os.Stdout, names...)
+	tracker := utils.NewTracker(names...)
+
+	pending := names
+	err := utils.Poll(ctx, 0, func() (bool, error) {
+		var remaining []string
+		for _, name := range pending {
+			image, err := svc.Get(g.config.ProjectID, name).Do()
+			if err != nil {
+				return false, err
+			}
+
+			progress.Set(name, "%s", image.Status)
+
+			if image.Status == status {
+				tracker.Done(name)
+				continue
+			}
+
+			if image.Status == statusFailed {
+				return false, fmt.Errorf("%s is %s", name, image.Status)
+			}
+
+			remaining = append(remaining, name)
+		}
+
+		pending = remaining
+		return len(pending) == 0, nil
+	})
+
+	return tracker.Err(ctx, err)
+}
@@ -0,0 +1,366 @@
+package provider
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"provider/utils"
+
+	"github.com/fatih/color"
+	"github.com/shiena/ansicolor"
+)
+
+// Sort sorts the images by provider, account, region, family, creation time
+// and ID, so the order doesn't depend on which provider answered first and
+// the images of a family are grouped together.
+func (i Images) Sort() {
+	sort.Sort(byProvider(i))
+}
+
+// Print prints the images to standard output. Images of multiple providers
+// are printed as a single table or a single document. An empty list of images
+// is an error for the human readable modes, the other modes print an empty
+// document.
+func (i Images) Print(mode utils.OutputMode) error {
+	if len(i) == 0 && (mode == utils.Simplified || mode == utils.Table) {
+		return errors.New("no images found")
+	}
+
+	switch mode {
+	case utils.JSON:
+		out, err := json.MarshalIndent(i, "", "    ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(out))
+		return nil
+	case utils.NDJSON:
+		enc := json.NewEncoder(os.Stdout)
+		for _, image := range i {
+			if err := enc.Encode(image); err != nil {
+				return err
+			}
+		}
+		return nil
+	case utils.YAML:
+		return i.printYAML(os.Stdout)
+	case utils.CSV:
+		return i.printCSV(os.Stdout)
+	case utils.Table:
+		w := utils.NewImagesTabWriter(os.Stdout)
+		defer w.Flush()
+
+		account, encryption, deprecation := i.hasAccount(), i.hasEncryption(), i.hasDeprecation()
+		family := i.hasFamily()
+		header := "PROVIDER\tREGION\tNAME\tID\tSTATE\tCREATED\tSIZE\tTAGS"
+		if account {
+			header = "PROVIDER\tACCOUNT\tREGION\tNAME\tID\tSTATE\tCREATED\tSIZE\tTAGS"
+		}
+		if family {
+			header += "\tFAMILY"
+		}
+		if encryption {
+			header += "\tENCRYPTION"
+		}
+		if deprecation {
+			header += "\tDEPRECATED"
+		}
+		fmt.Fprintln(w, header)
+
+		for _, image := range i {
+			fmt.Fprintf(w, "%s\t", image.Provider)
+			if account {
+				fmt.Fprintf(w, "%s\t", image.account())
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s",
+				image.Region, image.Name, image.ID, image.State,
+				image.created(), image.size(), image.tags())
+			if family {
+				fmt.Fprintf(w, "\t%s", image.family())
+			}
+			if encryption {
+				fmt.Fprintf(w, "\t%s", image.encryption())
+			}
+			if deprecation {
+				fmt.Fprintf(w, "\t%s", image.deprecated())
+			}
+			fmt.Fprintln(w)
+		}
+
+		return nil
+	case utils.Simplified:
+		green := color.New(color.FgGreen).SprintfFunc()
+		w := utils.NewImagesTabWriter(ansicolor.NewAnsiColorWriter(os.Stdout))
+		defer w.Flush()
+
+		for _, images := range i.groups() {
+			name := images[0].Provider
+			imageDesc := "image"
+			if len(images) > 1 {
+				imageDesc = "images"
+			}
+
+			fmt.Fprintln(w, green("%s (%d %s):", strings.ToUpper(name), len(images), imageDesc))
+
+			account, encryption, deprecation := images.hasAccount(), images.hasEncryption(), images.hasDeprecation()
+			family := images.hasFamily()
+			header := "    Name\tID\tRegion\tState\tCreated\tTags"
+			if account {
+				header = "    Name\tID\tAccount\tRegion\tState\tCreated\tTags"
+			}
+			if family {
+				header += "\tFamily"
+			}
+			if encryption {
+				header += "\tEncryption"
+			}
+			if deprecation {
+				header += "\tDeprecated"
+			}
+			fmt.Fprintln(w, header)
+
+			for ix, image := range images {
+				fmt.Fprintf(w, "[%d] %s\t%s\t", ix+1, image.Name, image.ID)
+				if account {
+					fmt.Fprintf(w, "%s\t", image.account())
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s",
+					image.Region, image.State, image.created(), image.tags())
+				if family {
+					fmt.Fprintf(w, "\t%s", image.family())
+				}
+				if encryption {
+					fmt.Fprintf(w, "\t%s", image.encryption())
+				}
+				if deprecation {
+					fmt.Fprintf(w, "\t%s", image.deprecated())
+				}
+				fmt.Fprintln(w)
+			}
+
+			fmt.Fprintln(w, "")
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("output mode '%s' is not valid", mode)
+	}
+}
+
+// PrintTemplate executes the given Go template for each image and prints the
+// result, followed by a newline, to standard output, i.e:
+//
+//	{{.ID}} {{.Region}} {{index .Tags "env"}}
+//
+// In addition to the builtin functions, "json" returns the JSON encoding of
+// its argument.
+func (i Images) PrintTemplate(format string) error {
+	funcs := template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			out, err := json.Marshal(v)
+			return string(out), err
+		},
+	}
+
+	tmpl, err := template.New("format").Funcs(funcs).Parse(format)
+	if err != nil {
+		return fmt.Errorf("invalid format: %s", err)
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	for _, image := range i {
+		if err := tmpl.Execute(w, image); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+	}
+
+	return nil
+}
+
+// printCSV prints the images as CSV with a header. Tags are in the form of
+// "key1=val1;key2=val2".
+func (i Images) printCSV(out io.Writer) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{"provider", "region", "id", "name", "state", "created_at", "size", "tags", "encryption", "account", "deprecated_at", "family"})
+
+	for _, image := range i {
+		tags := make([]string, 0, len(image.Tags))
+		for key, val := range image.Tags {
+			tags = append(tags, key+"="+val)
+		}
+		sort.Strings(tags)
+
+		created := ""
+		if !image.CreatedAt.IsZero() {
+			created = image.CreatedAt.Format(time.RFC3339)
+		}
+
+		deprecated := ""
+		if image.DeprecatedAt != nil {
+			deprecated = image.DeprecatedAt.Format(time.RFC3339)
+		}
+
+		w.Write([]string{
+			image.Provider, image.Region, image.ID, image.Name, image.State,
+			created, strconv.FormatInt(image.Size, 10), strings.Join(tags, ";"),
+			image.Encryption, image.Account, deprecated, image.Family,
+		})
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+// hasEncryption returns true if the encryption of any image is known
+func (i Images) hasEncryption() bool {
+	for _, image := range i {
+		if image.Encryption != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAccount returns true if any image belongs to a named account
+func (i Images) hasAccount() bool {
+	for _, image := range i {
+		if image.Account != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasFamily returns true if any image belongs to a family
+func (i Images) hasFamily() bool {
+	for _, image := range i {
+		if image.Family != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasDeprecation returns true if any image is scheduled for deprecation
+func (i Images) hasDeprecation() bool {
+	for _, image := range i {
+		if image.DeprecatedAt != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// groups splits the images into groups of the same provider. It
+// preserves the order of the images.
+func (i Images) groups() []Images {
+	groups := make([]Images, 0)
+	for _, image := range i {
+		last := len(groups) - 1
+		if last >= 0 && groups[last][0].Provider == image.Provider {
+			groups[last] = append(groups[last], image)
+			continue
+		}
+
+		groups = append(groups, Images{image})
+	}
+	return groups
+}
+
+func (i *Image) size() string {
+	if i.Size == 0 {
+		return "-"
+	}
+	return strconv.FormatInt(i.Size, 10) + "GB"
+}
+
+func (i *Image) encryption() string {
+	if i.Encryption == "" {
+		return "-"
+	}
+	return i.Encryption
+}
+
+func (i *Image) account() string {
+	if i.Account == "" {
+		return "-"
+	}
+	return i.Account
+}
+
+func (i *Image) family() string {
+	if i.Family == "" {
+		return "-"
+	}
+	return i.Family
+}
+
+func (i *Image) deprecated() string {
+	if i.DeprecatedAt == nil {
+		return "-"
+	}
+	return i.DeprecatedAt.Format(time.RFC3339)
+}
+
+func (i *Image) created() string {
+	if i.CreatedAt.IsZero() {
+		return "-"
+	}
+	return i.CreatedAt.Format(time.RFC3339)
+}
+
+// tags returns the tags in the form of "[key1:val1 key2:val2]" sorted by
+// their keys.
+func (i *Image) tags() string {
+	tags := make([]string, 0, len(i.Tags))
+	for key, val := range i.Tags {
+		tags = append(tags, key+":"+val)
+	}
+	sort.Strings(tags)
+
+	return "[" + strings.Join(tags, " ") + "]"
+}
+
+// byProvider implements sort.Interface for Images based on the Provider,
+// Account, Region, Family, CreatedAt and ID fields.
+type byProvider Images
+
+func (a byProvider) Len() int      { return len(a) }
+func (a byProvider) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byProvider) Less(i, j int) bool {
+	if a[i].Provider != a[j].Provider {
+		return a[i].Provider < a[j].Provider
+	}
+
+	if a[i].Account != a[j].Account {
+		return a[i].Account < a[j].Account
+	}
+
+	if a[i].Region != a[j].Region {
+		return a[i].Region < a[j].Region
+	}
+
+	if a[i].Family != a[j].Family {
+		return a[i].Family < a[j].Family
+	}
+
+	if !a[i].CreatedAt.Equal(a[j].CreatedAt) {
+		return a[i].CreatedAt.Before(a[j].CreatedAt)
+	}
+
+	return a[i].ID < a[j].ID
+}
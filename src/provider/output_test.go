@@ -0,0 +1,174 @@
+package provider
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"provider/utils"
+)
+
+// captureStdout returns everything fn prints to standard output.
+func captureStdout(t *testing.T, fn func() error) (string, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	out := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		out <- buf.String()
+	}()
+
+	err = fn()
+	w.Close()
+	return <-out, err
+}
+
+func testImages() Images {
+	created := time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+	deprecated := time.Date(2016, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	return Images{
+		{
+			Provider:     "aws",
+			Account:      "prod",
+			Region:       "us-east-1",
+			ID:           "ami-1",
+			Name:         `web "v1", final`,
+			Family:       "web",
+			State:        "available",
+			CreatedAt:    created,
+			Size:         8,
+			Tags:         map[string]string{"env": "prod", "team": "a=b;c"},
+			Encryption:   "encrypted",
+			DeprecatedAt: &deprecated,
+		},
+		{
+			Provider: "do",
+			ID:       "2",
+			Name:     "empty",
+		},
+	}
+}
+
+func TestPrint(t *testing.T) {
+	tests := []struct {
+		mode utils.OutputMode
+		out  string
+	}{
+		{
+			mode: utils.CSV,
+			out: `provider,region,id,name,state,created_at,size,tags,encryption,account,deprecated_at,family
+aws,us-east-1,ami-1,"web ""v1"", final",available,2016-01-02T03:04:05Z,8,env=prod;team=a=b;c,encrypted,prod,2016-02-01T00:00:00Z,web
+do,,2,empty,,,0,,,,,
+`,
+		},
+		{
+			mode: utils.NDJSON,
+			out: `{"provider":"aws","account":"prod","region":"us-east-1","id":"ami-1","name":"web \"v1\", final","family":"web","state":"available","created_at":"2016-01-02T03:04:05Z","size":8,"tags":{"env":"prod","team":"a=b;c"},"encryption":"encrypted","deprecated_at":"2016-02-01T00:00:00Z"}
+{"provider":"do","id":"2","name":"empty","created_at":"0001-01-01T00:00:00Z"}
+`,
+		},
+		{
+			mode: utils.YAML,
+			out: `- account: "prod"
+  created_at: "2016-01-02T03:04:05Z"
+  deprecated_at: "2016-02-01T00:00:00Z"
+  encryption: "encrypted"
+  family: "web"
+  id: "ami-1"
+  name: "web \"v1\", final"
+  provider: "aws"
+  region: "us-east-1"
+  size: 8
+  state: "available"
+  tags:
+    env: "prod"
+    team: "a=b;c"
+- created_at: "0001-01-01T00:00:00Z"
+  id: "2"
+  name: "empty"
+  provider: "do"
+`,
+		},
+		{
+			mode: utils.Table,
+			out: "PROVIDER\tACCOUNT\t\tREGION\t\tNAME\t\tID\t\tSTATE\t\tCREATED\t\t\tSIZE\t\tTAGS\t\t\tFAMILY\t\tENCRYPTION\tDEPRECATED\n" +
+				"aws\t\tprod\t\tus-east-1\tweb \"v1\", final\tami-1\t\tavailable\t2016-01-02T03:04:05Z\t8GB\t\t[env:prod team:a=b;c]\tweb\t\tencrypted\t2016-02-01T00:00:00Z\n" +
+				"do\t\t-\t\t\t\tempty\t\t2\t\t\t\t-\t\t\t-\t\t[]\t\t\t-\t\t-\t\t-\n",
+		},
+	}
+
+	for _, test := range tests {
+		out, err := captureStdout(t, func() error { return testImages().Print(test.mode) })
+		if err != nil {
+			t.Errorf("%s: %s", test.mode, err)
+			continue
+		}
+
+		if out != test.out {
+			t.Errorf("%s: output is\n%s\nwant\n%s", test.mode, out, test.out)
+		}
+	}
+}
+
+func TestPrintEmpty(t *testing.T) {
+	tests := []struct {
+		mode utils.OutputMode
+		out  string
+		err  bool
+	}{
+		{mode: utils.Simplified, err: true},
+		{mode: utils.Table, err: true},
+		{mode: utils.JSON, out: "[]\n"},
+		{mode: utils.NDJSON, out: ""},
+		{mode: utils.YAML, out: "[]\n"},
+		{mode: utils.CSV, out: "provider,region,id,name,state,created_at,size,tags,encryption,account,deprecated_at,family\n"},
+	}
+
+	for _, test := range tests {
+		out, err := captureStdout(t, func() error { return Images{}.Print(test.mode) })
+		if test.err != (err != nil) {
+			t.Errorf("%s: error is %v, want an error: %t", test.mode, err, test.err)
+		}
+
+		if out != test.out {
+			t.Errorf("%s: output is %q, want %q", test.mode, out, test.out)
+		}
+	}
+}
+
+func TestPrintTemplate(t *testing.T) {
+	tests := []struct {
+		format string
+		out    string
+		err    bool
+	}{
+		{format: `{{.ID}} {{.Region}}`, out: "ami-1 us-east-1\n2 \n"},
+		{format: `{{index .Tags "team"}}`, out: "a=b;c\n\n"},
+		{format: `{{json .Name}}`, out: "\"web \\\"v1\\\", final\"\n\"empty\"\n"},
+		{format: `{{.ID`, err: true},
+		{format: `{{.Unknown}}`, err: true},
+	}
+
+	for _, test := range tests {
+		out, err := captureStdout(t, func() error { return testImages().PrintTemplate(test.format) })
+		if test.err != (err != nil) {
+			t.Errorf("%s: error is %v, want an error: %t", test.format, err, test.err)
+			continue
+		}
+
+		if !test.err && out != test.out {
+			t.Errorf("%s: output is %q, want %q", test.format, out, test.out)
+		}
+	}
+}
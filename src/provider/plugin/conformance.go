@@ -0,0 +1,133 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"provider"
+
+	"github.com/hashicorp/go-multierror"
+	"golang.org/x/net/context"
+)
+
+// listTimeout is the time a plugin has to list its images during the
+// conformance checks.
+const listTimeout = time.Minute
+
+// Verify runs the conformance checks against the plugin executable at the
+// given path. The checks don't modify any images, only the "capabilities",
+// "help" and "list" methods and the error handling are exercised. The
+// returned error contains every failed check.
+func Verify(path string) error {
+	name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), Prefix), ".exe")
+	if name == "" || name == "all" {
+		return fmt.Errorf("plugin executable name must be in the form of '%s<name>'", Prefix)
+	}
+
+	p := New(name, path)
+
+	ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+	c, err := p.Capabilities(ctx)
+	cancel()
+	if err != nil {
+		// nothing else can be checked without the capabilities
+		return fmt.Errorf("capabilities: %s", err)
+	}
+
+	var multiErrors error
+	fail := func(format string, args ...interface{}) {
+		multiErrors = multierror.Append(multiErrors, fmt.Errorf(format, args...))
+	}
+
+	if c&provider.CanList == 0 {
+		fail("capabilities: the mandatory list command is not supported")
+	}
+
+	for _, command := range strings.Split(c.String(), ",") {
+		ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+		help, err := p.Help(ctx, command)
+		cancel()
+		if err != nil {
+			fail("help %s: %s", command, err)
+			continue
+		}
+
+		if strings.TrimSpace(help) == "" {
+			fail("help %s: help message is empty", command)
+		}
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), listTimeout)
+	images, err := p.Fetch(ctx, nil)
+	cancel()
+	if err != nil {
+		fail("list: %s", err)
+	}
+
+	for i, image := range images {
+		if image == nil {
+			fail("list: image #%d is null", i)
+			continue
+		}
+
+		if image.ID == "" {
+			fail("list: image #%d has no id", i)
+		}
+	}
+
+	if code, err := rawCall(path, []byte(`{"jsonrpc": "2.0", "id": 1, "method": "images.unknown"}`)); err != nil {
+		fail("unknown method: %s", err)
+	} else if code != CodeMethodNotFound {
+		fail("unknown method: expected error code %d, got %d", CodeMethodNotFound, code)
+	}
+
+	if code, err := rawCall(path, []byte(`{"jsonrpc": "2.0", "id": 1, "method": `)); err != nil {
+		fail("malformed request: %s", err)
+	} else if code != CodeParseError {
+		fail("malformed request: expected error code %d, got %d", CodeParseError, code)
+	}
+
+	return multiErrors
+}
+
+// rawCall sends the given raw request to the plugin and returns the error
+// code of the response. A non error response returns an error. The plugin is
+// killed if it doesn't respond within discoveryTimeout, error responses don't
+// require any work.
+func rawCall(path string, in []byte) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+	defer cancel()
+
+	var stdout bytes.Buffer
+	cmd := exec.Command(path)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = &stdout
+	cmd.Stderr = ioutil.Discard
+
+	// the exit code doesn't matter as long as a valid response is written
+	run(ctx, cmd, 0)
+	if ctx.Err() != nil {
+		return 0, fmt.Errorf("no response within %s", discoveryTimeout)
+	}
+
+	var resp Response
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return 0, fmt.Errorf("invalid response: %s", err)
+	}
+
+	if resp.Version != "2.0" {
+		return 0, fmt.Errorf("invalid jsonrpc version %q", resp.Version)
+	}
+
+	if resp.Error == nil {
+		return 0, fmt.Errorf("expected an error response, got %s", resp.Result)
+	}
+
+	return resp.Error.Code, nil
+}
@@ -0,0 +1,34 @@
+package plugin
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	plugins := fakePlugins(t, "fake", "ok", "crash")
+
+	if err := Verify(plugins[0].path); err != nil {
+		t.Errorf("fake: %s", err)
+	}
+
+	err := Verify(plugins[1].path)
+	if err == nil {
+		t.Fatal("ok: expected the error checks to fail")
+	}
+
+	for _, want := range []string{"unknown method: expected an error response", "malformed request: expected an error response"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("ok: error %q doesn't contain %q", err, want)
+		}
+	}
+
+	if err := Verify(plugins[2].path); err == nil || !strings.HasPrefix(err.Error(), "capabilities:") {
+		t.Errorf("crash: error is %v, want a capabilities error", err)
+	}
+
+	if err := Verify(filepath.Join(filepath.Dir(plugins[0].path), Prefix)); err == nil {
+		t.Error("expected an error for a plugin without a name")
+	}
+}
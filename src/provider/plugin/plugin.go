@@ -0,0 +1,431 @@
+// Package plugin implements external providers. A plugin is an executable
+// named "images-provider-<name>" which is found on PATH. Once discovered it's
+// registered as the provider "<name>" and can be used like any other provider,
+// i.e: "images list --providers <name>".
+//
+// # Protocol
+//
+// For every call images starts the plugin, writes a single JSON-RPC 2.0
+// request to its stdin and reads a single JSON-RPC 2.0 response from its
+// stdout. Stdin is closed after the request is written. Stderr is passed
+// through to the user and can be used for progress or log messages. A non
+// zero exit code without a response is treated as an error.
+//
+// If a call is canceled, i.e. with Ctrl-C or once the --timeout of images is
+// exceeded, the plugin receives an interrupt signal and is killed if it
+// doesn't exit within 5 seconds. An error response which is written before
+// exiting, such as a list of the finished items, is still reported.
+//
+//	--> {"jsonrpc": "2.0", "id": 1, "method": "list", "params": {"args": ["-output", "json"]}}
+//	<-- {"jsonrpc": "2.0", "id": 1, "result": {"images": [{"provider": "example", "id": "1", "name": "base"}]}}
+//
+// The following methods are defined:
+//
+//	capabilities  params: none
+//	              result: {"protocol": 1, "capabilities": ["list", "delete", "modify", "copy", "wait"]}
+//
+//	help          params: {"command": "list"}
+//	              result: {"help": "Usage: images list --providers example ..."}
+//
+//	list          params: {"args": [...]}
+//	              result: {"images": [<image>, ...]}
+//
+//	delete        params: {"args": [...]}
+//	modify        result: {}
+//	copy
+//	wait
+//
+// An image has the same fields as the JSON representation of provider.Image:
+// "provider", "region", "id", "name", "state", "created_at" (RFC3339),
+// "size" (in GB), "tags" (an object of strings), "encryption" and "raw" (any
+// JSON value).
+//
+// Commands which select images themselves, such as "prune", call "delete"
+// and "modify" with the "-ids" flag and a comma separated list of the image
+// ids, i.e: ["-ids", "1,2"]. Plugins which support these methods must accept
+// it. With the --filter flag of "delete" and "modify" the images are selected
+// from a "list" call with the same arguments, before "-ids" is appended, so
+// "list" has to ignore the flags of these commands.
+//
+// The "capabilities" method is called once during discovery. It has to
+// respond within a few seconds, like "help". "list" is mandatory, "delete",
+// "modify", "copy" and "wait" are optional. Other capabilities are rejected. Errors are reported with the standard JSON-RPC error
+// object, i.e:
+//
+//	<-- {"jsonrpc": "2.0", "id": 1, "error": {"code": -32601, "message": "method not found"}}
+//
+// Plugins written in Go can use Serve, which implements the protocol for a
+// given Handler.
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"provider"
+
+	"github.com/hashicorp/go-multierror"
+	"golang.org/x/net/context"
+)
+
+// Prefix is the prefix of the plugin executables
+const Prefix = "images-provider-"
+
+// Capabilities are the commands which can be implemented by a plugin. Other
+// commands, such as "snapshots" or "rollover", are not part of the protocol.
+const Capabilities = provider.CanList | provider.CanDelete | provider.CanModify |
+	provider.CanCopy | provider.CanWait
+
+// Plugin is an external provider which runs as a subprocess. It implements
+// the provider.Provider interface and all commands supported by the protocol.
+type Plugin struct {
+	name string
+	path string
+}
+
+// New returns a new plugin with the given name for the executable at path.
+func New(name, path string) *Plugin {
+	return &Plugin{
+		name: name,
+		path: path,
+	}
+}
+
+// discoveryTimeout is the time a plugin has to report its capabilities or a
+// help message. Capabilities are queried whenever images needs a provider
+// which isn't builtin, so a hanging plugin must not block it.
+const discoveryTimeout = 3 * time.Second
+
+// Discover looks up plugins on PATH and registers them as providers. Plugins
+// which have the same name as an already registered provider are ignored. The
+// plugins are queried concurrently, each one at most for discoveryTimeout or
+// until ctx is done. The returned error contains all plugins which failed to
+// register. It's supposed to be called from the discovery of the registry,
+// see provider.SetDiscovery.
+func Discover(ctx context.Context) error {
+	found := lookPath()
+
+	// Discover usually runs as the discovery of the registry, a Lookup miss
+	// would run it again
+	registered := make(map[string]bool)
+	for _, name := range provider.Registered(0) {
+		registered[name] = true
+	}
+
+	plugins := make([]*Plugin, 0, len(found))
+	for name, path := range found {
+		if registered[name] {
+			continue
+		}
+
+		plugins = append(plugins, New(name, path))
+	}
+	sort.Sort(byName(plugins))
+
+	var (
+		wg           sync.WaitGroup
+		capabilities = make([]provider.Capability, len(plugins))
+		errs         = make([]error, len(plugins))
+	)
+
+	for i, p := range plugins {
+		wg.Add(1)
+		go func(i int, p *Plugin) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, discoveryTimeout)
+			defer cancel()
+
+			capabilities[i], errs[i] = p.Capabilities(ctx)
+		}(i, p)
+	}
+
+	wg.Wait()
+
+	var multiErrors error
+	for i, p := range plugins {
+		if errs[i] == nil {
+			errs[i] = register(ctx, p, capabilities[i])
+		}
+
+		if errs[i] != nil {
+			multiErrors = multierror.Append(multiErrors, errs[i])
+		}
+	}
+
+	return multiErrors
+}
+
+// register registers the given plugin as a provider with the given
+// capabilities, which are reported by the plugin. Help messages are queried
+// with ctx, at most for discoveryTimeout.
+func register(ctx context.Context, p *Plugin, c provider.Capability) error {
+	if c&provider.CanList == 0 {
+		return fmt.Errorf("plugin '%s' doesn't support the mandatory list command", p.name)
+	}
+
+	provider.Register(&provider.Registration{
+		Name:         p.name,
+		Capabilities: c,
+		New: func(args []string) (provider.Provider, []string, error) {
+			// plugins parse their own configuration, pass all arguments
+			return p, args, nil
+		},
+		Help: func(command string) string {
+			ctx, cancel := context.WithTimeout(ctx, discoveryTimeout)
+			defer cancel()
+
+			help, err := p.Help(ctx, command)
+			if err != nil {
+				return err.Error()
+			}
+			return help
+		},
+	})
+
+	return nil
+}
+
+// lookPath returns the plugins found on PATH as a map of names to paths. If a
+// plugin exists multiple times, the first one on PATH is used.
+func lookPath() map[string]string {
+	plugins := make(map[string]string)
+
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" {
+			dir = "."
+		}
+
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, f := range files {
+			if f.IsDir() || !strings.HasPrefix(f.Name(), Prefix) {
+				continue
+			}
+
+			name := strings.TrimPrefix(f.Name(), Prefix)
+			if runtime.GOOS == "windows" {
+				name = strings.TrimSuffix(name, ".exe")
+			} else if f.Mode()&0111 == 0 {
+				continue // not executable
+			}
+
+			if name == "" || name == "all" {
+				continue
+			}
+
+			if _, ok := plugins[name]; ok {
+				continue
+			}
+
+			plugins[name] = filepath.Join(dir, f.Name())
+		}
+	}
+
+	return plugins
+}
+
+// Name implements the provider.Provider interface
+func (p *Plugin) Name() string { return p.name }
+
+// Capabilities returns the capabilities reported by the plugin.
+func (p *Plugin) Capabilities(ctx context.Context) (provider.Capability, error) {
+	var result CapabilitiesResult
+	if err := p.call(ctx, MethodCapabilities, nil, &result); err != nil {
+		return 0, err
+	}
+
+	if result.Protocol != ProtocolVersion {
+		return 0, fmt.Errorf("plugin '%s' implements protocol version %d, expected %d",
+			p.name, result.Protocol, ProtocolVersion)
+	}
+
+	c, err := provider.ParseCapability(strings.Join(result.Capabilities, ","))
+	if err != nil {
+		return 0, fmt.Errorf("plugin '%s': %s", p.name, err)
+	}
+
+	if unsupported := c &^ Capabilities; unsupported != 0 {
+		return 0, fmt.Errorf("plugin '%s' reports capabilities which are not part of the protocol: %s",
+			p.name, unsupported)
+	}
+
+	return c, nil
+}
+
+// Help returns the help message of the plugin for the given command.
+func (p *Plugin) Help(ctx context.Context, command string) (string, error) {
+	var result HelpResult
+	if err := p.call(ctx, MethodHelp, &HelpParams{Command: command}, &result); err != nil {
+		return "", err
+	}
+
+	return result.Help, nil
+}
+
+// Fetch implements the provider.Provider interface
+func (p *Plugin) Fetch(ctx context.Context, args []string) (provider.Images, error) {
+	var result ListResult
+	if err := p.call(ctx, MethodList, &ArgsParams{Args: args}, &result); err != nil {
+		return nil, err
+	}
+
+	for _, image := range result.Images {
+		image.Provider = p.name
+	}
+
+	return result.Images, nil
+}
+
+// SelectArgs implements the command.Selector interface. Plugins select images
+// with the "-ids" flag.
+func (p *Plugin) SelectArgs(images provider.Images) []string {
+	return []string{"-ids", strings.Join(images.IDs(), ",")}
+}
+
+// Delete implements the command.Deleter interface
+func (p *Plugin) Delete(ctx context.Context, args []string) error {
+	return p.call(ctx, MethodDelete, &ArgsParams{Args: args}, nil)
+}
+
+// Modify implements the command.Modifier interface
+func (p *Plugin) Modify(ctx context.Context, args []string) error {
+	return p.call(ctx, MethodModify, &ArgsParams{Args: args}, nil)
+}
+
+// Copy implements the command.Copier interface
+func (p *Plugin) Copy(ctx context.Context, args []string) error {
+	return p.call(ctx, MethodCopy, &ArgsParams{Args: args}, nil)
+}
+
+// Wait implements the command.Waiter interface
+func (p *Plugin) Wait(ctx context.Context, args []string) error {
+	return p.call(ctx, MethodWait, &ArgsParams{Args: args}, nil)
+}
+
+// call executes the plugin with the given method and params and decodes the
+// response into result. A nil result discards the response. Once ctx is done
+// the plugin is interrupted and killed if it doesn't exit within killDelay.
+// Plugins are killed right away for the capabilities and help methods, they
+// have nothing to report.
+func (p *Plugin) call(ctx context.Context, method string, params, result interface{}) error {
+	req := &Request{
+		Version: "2.0",
+		ID:      1,
+		Method:  method,
+	}
+
+	if params != nil {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		req.Params = raw
+	}
+
+	in, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.Command(p.path)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+
+	delay := killDelay
+	if method == MethodCapabilities || method == MethodHelp {
+		delay = 0
+	}
+
+	runErr := run(ctx, cmd, delay)
+
+	var resp Response
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("plugin '%s' is stopped: %s", p.name, ctx.Err())
+		}
+
+		if runErr != nil {
+			return fmt.Errorf("plugin '%s' failed: %s", p.name, runErr)
+		}
+		return fmt.Errorf("plugin '%s' returned an invalid response: %s", p.name, err)
+	}
+
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if runErr != nil {
+		return fmt.Errorf("plugin '%s' failed: %s", p.name, runErr)
+	}
+
+	if result == nil || len(resp.Result) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return fmt.Errorf("plugin '%s' returned an invalid result: %s", p.name, err)
+	}
+
+	return nil
+}
+
+// killDelay is the time a plugin has to exit after it's interrupted
+const killDelay = 5 * time.Second
+
+// run runs the given command until it exits. Once ctx is done the command is
+// interrupted, so it can report which items are finished, and killed after
+// the given delay. A zero delay kills the command immediately.
+func run(ctx context.Context, cmd *exec.Cmd, delay time.Duration) error {
+	// children of the plugin might keep stdout open after the plugin exits,
+	// don't wait for them
+	cmd.WaitDelay = time.Second
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	if delay > 0 {
+		cmd.Process.Signal(os.Interrupt)
+
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(delay):
+		}
+	}
+
+	cmd.Process.Kill()
+	return <-done
+}
+
+// byName implements sort.Interface for plugins based on their names.
+type byName []*Plugin
+
+func (a byName) Len() int           { return len(a) }
+func (a byName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byName) Less(i, j int) bool { return a[i].name < a[j].name }
@@ -0,0 +1,264 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"provider"
+
+	"golang.org/x/net/context"
+)
+
+// The test binary doubles as a fake plugin. With IMAGES_TEST_PLUGIN set it
+// serves a single request instead of running the tests. The behaviour is
+// given by the executable name, i.e. "images-provider-hang", so tests can put
+// several fake plugins on PATH as symlinks to the test binary.
+func TestMain(m *testing.M) {
+	if os.Getenv("IMAGES_TEST_PLUGIN") == "" {
+		os.Exit(m.Run())
+	}
+
+	os.Exit(fakePlugin(strings.TrimPrefix(filepath.Base(os.Args[0]), Prefix)))
+}
+
+var fakeImages = provider.Images{
+	{ID: "1", Name: "base", Region: "local", State: "available"},
+	{ID: "2", Name: "base", Region: "local", State: "pending"},
+}
+
+// fake is a handler which fails every command with the "-fail" argument.
+type fake struct{}
+
+func (fake) Help(command string) string {
+	return "Usage: images " + command + " --providers fake"
+}
+
+func (fake) List(args []string) (provider.Images, error) {
+	if len(args) != 0 && args[0] == "-fail" {
+		return nil, errors.New("listing failed")
+	}
+	return fakeImages, nil
+}
+
+func (fake) Delete(args []string) error {
+	if len(args) != 0 && args[0] == "-fail" {
+		return errors.New("deleting failed")
+	}
+	return nil
+}
+
+func fakePlugin(mode string) int {
+	switch mode {
+	case "fake":
+		if err := Serve(fake{}); err != nil {
+			return 1
+		}
+	case "hang":
+		// report the interrupt like a plugin which stops its in-flight work
+		interrupts := make(chan os.Signal, 1)
+		signal.Notify(interrupts, os.Interrupt)
+
+		select {
+		case <-interrupts:
+			fmt.Print(`{"jsonrpc": "2.0", "id": 1, "error": {"code": -32603, "message": "interrupted, 1 image deleted"}}`)
+		case <-time.After(time.Minute):
+		}
+	case "snapshots":
+		fmt.Print(`{"jsonrpc": "2.0", "id": 1, "result": {"protocol": 1, "capabilities": ["list", "snapshots"]}}`)
+	case "future":
+		fmt.Print(`{"jsonrpc": "2.0", "id": 1, "result": {"protocol": 2, "capabilities": ["list"]}}`)
+	case "nolist":
+		fmt.Print(`{"jsonrpc": "2.0", "id": 1, "result": {"protocol": 1, "capabilities": ["delete"]}}`)
+	case "garbage":
+		fmt.Print("garbage")
+	case "crash":
+		return 2
+	case "ok":
+		// answers everything with an empty result
+		fmt.Print(`{"jsonrpc": "2.0", "id": 1, "result": {"protocol": 1, "capabilities": ["list"], "images": []}}`)
+	}
+
+	return 0
+}
+
+// fakePlugins puts the fake plugins with the given modes on PATH and returns
+// them.
+func fakePlugins(t *testing.T, modes ...string) []*Plugin {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	t.Setenv("PATH", dir)
+	t.Setenv("IMAGES_TEST_PLUGIN", "1")
+
+	plugins := make([]*Plugin, len(modes))
+	for i, mode := range modes {
+		path := filepath.Join(dir, Prefix+mode)
+		if err := os.Symlink(exe, path); err != nil {
+			t.Fatal(err)
+		}
+		plugins[i] = New(mode, path)
+	}
+
+	return plugins
+}
+
+func TestCapabilities(t *testing.T) {
+	plugins := fakePlugins(t, "fake", "snapshots", "future", "garbage", "crash")
+
+	tests := []struct {
+		plugin       *Plugin
+		capabilities provider.Capability
+		err          string
+	}{
+		{plugin: plugins[0], capabilities: provider.CanList | provider.CanDelete},
+		{plugin: plugins[1], err: "not part of the protocol: snapshots"},
+		{plugin: plugins[2], err: "protocol version 2, expected 1"},
+		{plugin: plugins[3], err: "invalid response"},
+		{plugin: plugins[4], err: "plugin 'crash' failed: exit status 2"},
+	}
+
+	for _, test := range tests {
+		c, err := test.plugin.Capabilities(context.Background())
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: error is %v, want %q", test.plugin.Name(), err, test.err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %s", test.plugin.Name(), err)
+			continue
+		}
+
+		if c != test.capabilities {
+			t.Errorf("%s: capabilities are %s, want %s", test.plugin.Name(), c, test.capabilities)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	p := fakePlugins(t, "fake")[0]
+
+	images, err := p.Fetch(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(images) != len(fakeImages) {
+		t.Fatalf("listed %d images, want %d", len(images), len(fakeImages))
+	}
+
+	for i, image := range images {
+		if image.ID != fakeImages[i].ID || image.State != fakeImages[i].State {
+			t.Errorf("image #%d is %+v, want %+v", i, image, fakeImages[i])
+		}
+
+		if image.Provider != "fake" {
+			t.Errorf("image #%d has the provider %q, want %q", i, image.Provider, "fake")
+		}
+	}
+
+	help, err := p.Help(context.Background(), "list")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "Usage: images list --providers fake"; help != want {
+		t.Errorf("help is %q, want %q", help, want)
+	}
+}
+
+func TestError(t *testing.T) {
+	p := fakePlugins(t, "fake")[0]
+
+	if _, err := p.Fetch(context.Background(), []string{"-fail"}); err == nil || err.Error() != "listing failed (code=-32603)" {
+		t.Errorf("list error is %v, want %q", err, "listing failed (code=-32603)")
+	}
+
+	if err := p.Delete(context.Background(), []string{"-fail"}); err == nil || err.Error() != "deleting failed (code=-32603)" {
+		t.Errorf("delete error is %v, want %q", err, "deleting failed (code=-32603)")
+	}
+
+	if err := p.Delete(context.Background(), []string{"-ids", "1"}); err != nil {
+		t.Errorf("delete: %s", err)
+	}
+
+	err := p.Copy(context.Background(), nil)
+	if rpcErr, ok := err.(*Error); !ok || rpcErr.Code != CodeMethodNotFound {
+		t.Errorf("copy error is %v, want the code %d", err, CodeMethodNotFound)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	p := fakePlugins(t, "hang")[0]
+
+	// capabilities are killed right away
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := p.Capabilities(ctx)
+	if err == nil || !strings.Contains(err.Error(), "plugin 'hang' is stopped") {
+		t.Errorf("capabilities error is %v, want a stopped plugin", err)
+	}
+
+	if d := time.Since(start); d > killDelay {
+		t.Errorf("capabilities returned after %s, want the plugin to be killed right away", d)
+	}
+
+	// commands are interrupted and can report their state
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	err = p.Delete(ctx, []string{"-ids", "1,2"})
+	if err == nil || !strings.Contains(err.Error(), "interrupted, 1 image deleted") {
+		t.Errorf("delete error is %v, want the error of the interrupted plugin", err)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	fakePlugins(t, "fake", "nolist", "snapshots")
+
+	err := Discover(context.Background())
+	if err == nil {
+		t.Fatal("expected errors for the plugins which can't be registered")
+	}
+
+	for _, want := range []string{
+		"plugin 'nolist' doesn't support the mandatory list command",
+		"plugin 'snapshots' reports capabilities",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't contain %q", err, want)
+		}
+	}
+
+	r, ok := provider.Lookup("fake")
+	if !ok {
+		t.Fatal("plugin 'fake' is not registered")
+	}
+
+	if r.Capabilities != provider.CanList|provider.CanDelete {
+		t.Errorf("capabilities are %s, want list,delete", r.Capabilities)
+	}
+
+	if help := r.Help("delete"); help != "Usage: images delete --providers fake" {
+		t.Errorf("help is %q", help)
+	}
+
+	for _, name := range []string{"nolist", "snapshots"} {
+		if _, ok := provider.Lookup(name); ok {
+			t.Errorf("plugin '%s' is registered", name)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"provider"
+)
+
+// ProtocolVersion is the version of the protocol spoken between images and
+// its plugins. Plugins report the version they implement with the
+// "capabilities" method.
+const ProtocolVersion = 1
+
+// Methods which are part of the protocol.
+const (
+	MethodCapabilities = "capabilities"
+	MethodHelp         = "help"
+	MethodList         = "list"
+	MethodDelete       = "delete"
+	MethodModify       = "modify"
+	MethodCopy         = "copy"
+	MethodWait         = "wait"
+)
+
+// Error codes as defined by the JSON-RPC 2.0 specification.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// Request is a single JSON-RPC 2.0 request sent to the plugin's stdin.
+type Request struct {
+	Version string          `json:"jsonrpc"`
+	ID      int             `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// Response is a single JSON-RPC 2.0 response written to the plugin's stdout.
+type Response struct {
+	Version string          `json:"jsonrpc"`
+	ID      int             `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error is a JSON-RPC 2.0 error object.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error implements the builtin error interface.
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (code=%d)", e.Message, e.Code)
+}
+
+// CapabilitiesResult is the result of the "capabilities" method.
+type CapabilitiesResult struct {
+	// Protocol is the protocol version implemented by the plugin
+	Protocol int `json:"protocol"`
+
+	// Capabilities are the supported commands, i.e: ["list", "delete"]
+	Capabilities []string `json:"capabilities"`
+}
+
+// HelpParams are the parameters of the "help" method.
+type HelpParams struct {
+	Command string `json:"command"`
+}
+
+// HelpResult is the result of the "help" method.
+type HelpResult struct {
+	Help string `json:"help"`
+}
+
+// ArgsParams are the parameters of the "list", "delete", "modify" and "copy"
+// methods. Args are the command line arguments which are left after images
+// parsed its own global flags.
+type ArgsParams struct {
+	Args []string `json:"args"`
+}
+
+// ListResult is the result of the "list" method.
+type ListResult struct {
+	Images provider.Images `json:"images"`
+}
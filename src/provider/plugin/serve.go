@@ -0,0 +1,164 @@
+package plugin
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"provider"
+)
+
+// Handler implements a plugin. The list command is mandatory, all other
+// commands are supported by implementing the Deleter, Modifier, Copier or
+// Waiter interfaces.
+type Handler interface {
+	// Help returns the help message for the given command
+	Help(command string) string
+
+	// List returns the images for the given arguments
+	List(args []string) (provider.Images, error)
+}
+
+// Deleter is implemented by handlers which support the delete command.
+type Deleter interface {
+	Delete(args []string) error
+}
+
+// Modifier is implemented by handlers which support the modify command.
+type Modifier interface {
+	Modify(args []string) error
+}
+
+// Copier is implemented by handlers which support the copy command.
+type Copier interface {
+	Copy(args []string) error
+}
+
+// Waiter is implemented by handlers which support the wait command.
+type Waiter interface {
+	Wait(args []string) error
+}
+
+// Serve reads a single request from stdin, dispatches it to the given handler
+// and writes the response to stdout. It's supposed to be called from the main
+// function of a plugin.
+func Serve(h Handler) error {
+	return serve(os.Stdin, os.Stdout, h)
+}
+
+func serve(r io.Reader, w io.Writer, h Handler) error {
+	resp := &Response{Version: "2.0"}
+
+	result, rpcErr := dispatch(r, h, resp)
+	if rpcErr != nil {
+		resp.Error = rpcErr
+	} else {
+		raw, err := json.Marshal(result)
+		if err != nil {
+			resp.Error = &Error{Code: CodeInternalError, Message: err.Error()}
+		} else {
+			resp.Result = raw
+		}
+	}
+
+	return json.NewEncoder(w).Encode(resp)
+}
+
+// dispatch decodes the request and calls the handler. It sets the id of the
+// response once the request is decoded.
+func dispatch(r io.Reader, h Handler, resp *Response) (interface{}, *Error) {
+	in, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, &Error{Code: CodeParseError, Message: err.Error()}
+	}
+
+	var req Request
+	if err := json.Unmarshal(in, &req); err != nil {
+		return nil, &Error{Code: CodeParseError, Message: err.Error()}
+	}
+	resp.ID = req.ID
+
+	if req.Version != "2.0" || req.Method == "" {
+		return nil, &Error{Code: CodeInvalidRequest, Message: "invalid request"}
+	}
+
+	var args ArgsParams
+	switch req.Method {
+	case MethodList, MethodDelete, MethodModify, MethodCopy, MethodWait:
+		if len(req.Params) != 0 {
+			if err := json.Unmarshal(req.Params, &args); err != nil {
+				return nil, &Error{Code: CodeInvalidParams, Message: err.Error()}
+			}
+		}
+	}
+
+	switch req.Method {
+	case MethodCapabilities:
+		return &CapabilitiesResult{
+			Protocol:     ProtocolVersion,
+			Capabilities: capabilities(h),
+		}, nil
+	case MethodHelp:
+		var params HelpParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &Error{Code: CodeInvalidParams, Message: err.Error()}
+		}
+		return &HelpResult{Help: h.Help(params.Command)}, nil
+	case MethodList:
+		images, err := h.List(args.Args)
+		if err != nil {
+			return nil, &Error{Code: CodeInternalError, Message: err.Error()}
+		}
+
+		if images == nil {
+			images = provider.Images{}
+		}
+		return &ListResult{Images: images}, nil
+	case MethodDelete:
+		if d, ok := h.(Deleter); ok {
+			return struct{}{}, handlerError(d.Delete(args.Args))
+		}
+	case MethodModify:
+		if m, ok := h.(Modifier); ok {
+			return struct{}{}, handlerError(m.Modify(args.Args))
+		}
+	case MethodCopy:
+		if c, ok := h.(Copier); ok {
+			return struct{}{}, handlerError(c.Copy(args.Args))
+		}
+	case MethodWait:
+		if wt, ok := h.(Waiter); ok {
+			return struct{}{}, handlerError(wt.Wait(args.Args))
+		}
+	}
+
+	return nil, &Error{Code: CodeMethodNotFound, Message: "method not found: " + req.Method}
+}
+
+// capabilities returns the names of the commands the handler supports
+func capabilities(h Handler) []string {
+	c := provider.CanList
+	if _, ok := h.(Deleter); ok {
+		c |= provider.CanDelete
+	}
+	if _, ok := h.(Modifier); ok {
+		c |= provider.CanModify
+	}
+	if _, ok := h.(Copier); ok {
+		c |= provider.CanCopy
+	}
+	if _, ok := h.(Waiter); ok {
+		c |= provider.CanWait
+	}
+
+	return strings.Split(c.String(), ",")
+}
+
+func handlerError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Code: CodeInternalError, Message: err.Error()}
+}
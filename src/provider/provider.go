@@ -0,0 +1,82 @@
+// Package provider defines the provider agnostic types which are shared by all
+// images backends, such as "aws" or "do".
+package provider
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Image is a provider agnostic representation of a single machine image.
+type Image struct {
+	// Provider is the name of the provider the image belongs to, i.e: "aws"
+	Provider string `json:"provider"`
+
+	// Account is the name of the account the image belongs to, if the
+	// provider is configured with multiple accounts
+	Account string `json:"account,omitempty"`
+
+	// Region is the region or location of the image. Images which are
+	// available in multiple locations have them joined with a comma.
+	Region string `json:"region,omitempty"`
+
+	// ID uniquely identifies the image within the provider
+	ID string `json:"id"`
+
+	// Name is the human readable name of the image
+	Name string `json:"name"`
+
+	// Family is the image family the image belongs to, i.e: "debian-12". It's
+	// empty if the provider doesn't support families.
+	Family string `json:"family,omitempty"`
+
+	// State is the provider specific state of the image, i.e: "available"
+	State string `json:"state,omitempty"`
+
+	// CreatedAt is the creation time of the image. It's zero if the provider
+	// doesn't expose it.
+	CreatedAt time.Time `json:"created_at"`
+
+	// Size is the size of the image in GB
+	Size int64 `json:"size,omitempty"`
+
+	// Tags are the tags or labels associated with the image
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// Encryption describes whether the storage of the image is encrypted,
+	// either "encrypted", "unencrypted" or "partial" if only some of it is
+	// encrypted. It's empty if the provider doesn't expose it.
+	Encryption string `json:"encryption,omitempty"`
+
+	// DeprecatedAt is the time the image is or was deprecated at. It's nil
+	// if the image isn't scheduled for deprecation.
+	DeprecatedAt *time.Time `json:"deprecated_at,omitempty"`
+
+	// Raw is the original payload returned by the provider
+	Raw interface{} `json:"raw,omitempty"`
+}
+
+// Images defines and represents a list of provider agnostic images
+type Images []*Image
+
+// IDs returns the IDs of the images.
+func (i Images) IDs() []string {
+	ids := make([]string, len(i))
+	for ix, image := range i {
+		ids[ix] = image.ID
+	}
+	return ids
+}
+
+// Provider is implemented by every images backend. Additional capabilities,
+// such as deleting or copying images, are implemented separately.
+type Provider interface {
+	// Name returns the name of the provider, i.e: "aws"
+	Name() string
+
+	// Fetch returns the images for the given list arguments. The arguments
+	// are the same ones the "list" command of the provider accepts. All
+	// requests are canceled once ctx is done.
+	Fetch(ctx context.Context, args []string) (Images, error)
+}
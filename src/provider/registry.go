@@ -0,0 +1,198 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Capability defines a single action a provider supports. Capabilities can be
+// combined, i.e: CanList | CanDelete
+type Capability int
+
+const (
+	CanList Capability = 1 << iota
+	CanDelete
+	CanModify
+	CanCopy
+	CanWait
+	CanSnapshots
+	CanRollover
+)
+
+var capabilityNames = []struct {
+	c    Capability
+	name string
+}{
+	{CanList, "list"},
+	{CanDelete, "delete"},
+	{CanModify, "modify"},
+	{CanCopy, "copy"},
+	{CanWait, "wait"},
+	{CanSnapshots, "snapshots"},
+	{CanRollover, "rollover"},
+}
+
+func (c Capability) String() string {
+	names := make([]string, 0)
+	for _, cn := range capabilityNames {
+		if c&cn.c != 0 {
+			names = append(names, cn.name)
+		}
+	}
+
+	if len(names) == 0 {
+		return "none"
+	}
+
+	return strings.Join(names, ",")
+}
+
+// ParseCapability parses the given comma separated capability names, i.e:
+// "list,delete", and returns the combined capabilities.
+func ParseCapability(names string) (Capability, error) {
+	var c Capability
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		found := false
+		for _, cn := range capabilityNames {
+			if cn.name == name {
+				c |= cn.c
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return 0, fmt.Errorf("unknown capability '%s'", name)
+		}
+	}
+
+	return c, nil
+}
+
+// Factory creates a new provider from the given arguments. It returns the
+// remaining arguments which are not consumed by the provider configuration.
+type Factory func(args []string) (Provider, []string, error)
+
+// Registration describes a provider which can be used with images
+type Registration struct {
+	// Name is the name of the provider used with the "--providers" flag
+	Name string
+
+	// New creates a new instance of the provider
+	New Factory
+
+	// Capabilities are the commands supported by the provider
+	Capabilities Capability
+
+	// Help returns the help message of the provider for the given command,
+	// i.e: "list". It shouldn't depend on any configuration.
+	Help func(command string) string
+}
+
+// Can returns true if the provider supports all the given capabilities.
+func (r *Registration) Can(c Capability) bool {
+	return r.Capabilities&c == c
+}
+
+var (
+	mu            sync.RWMutex // protects registrations and discovery
+	registrations = make(map[string]*Registration)
+
+	discovery     func()
+	discoveryOnce sync.Once
+)
+
+// SetDiscovery sets the function which registers providers that are not
+// builtin, such as plugins. It's called at most once, the first time Lookup
+// misses a provider or Names is called, so commands which don't need these
+// providers don't pay for their discovery.
+func SetDiscovery(fn func()) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	discovery = fn
+}
+
+// discover runs the discovery once, if it's set.
+func discover() {
+	mu.RLock()
+	fn := discovery
+	mu.RUnlock()
+
+	if fn != nil {
+		discoveryOnce.Do(fn)
+	}
+}
+
+// Register makes a provider available with the given registration. It panics
+// if the registration is incomplete or a provider with the same name is
+// already registered.
+func Register(r *Registration) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if r == nil || r.Name == "" || r.New == nil {
+		panic("provider: Register registration is not valid")
+	}
+
+	if r.Name == "all" {
+		panic("provider: Register called with the reserved name 'all'")
+	}
+
+	if _, ok := registrations[r.Name]; ok {
+		panic(fmt.Sprintf("provider: Register called twice for provider '%s'", r.Name))
+	}
+
+	registrations[r.Name] = r
+}
+
+// Lookup returns the registration for the provider with the given name. If
+// the provider isn't registered, the discovery is run before giving up.
+func Lookup(name string) (*Registration, bool) {
+	if r, ok := lookup(name); ok {
+		return r, ok
+	}
+
+	discover()
+	return lookup(name)
+}
+
+func lookup(name string) (*Registration, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	r, ok := registrations[name]
+	return r, ok
+}
+
+// Names returns the sorted names of all providers which support the given
+// capabilities, including the discovered ones. Pass zero to return all
+// providers.
+func Names(c Capability) []string {
+	discover()
+	return Registered(c)
+}
+
+// Registered is like Names, but returns only the providers which are
+// registered already, without running the discovery.
+func Registered(c Capability) []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(registrations))
+	for name, r := range registrations {
+		if r.Can(c) {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,44 @@
+package provider
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestLookupDiscovery(t *testing.T) {
+	defer func() {
+		mu.Lock()
+		delete(registrations, "builtin")
+		delete(registrations, "discovered")
+		discovery, discoveryOnce = nil, sync.Once{}
+		mu.Unlock()
+	}()
+
+	Register(&Registration{Name: "builtin", New: func(args []string) (Provider, []string, error) { return nil, args, nil }})
+
+	calls := 0
+	SetDiscovery(func() {
+		calls++
+		Register(&Registration{Name: "discovered", Capabilities: CanList, New: func(args []string) (Provider, []string, error) { return nil, args, nil }})
+	})
+
+	if _, ok := Lookup("builtin"); !ok || calls != 0 {
+		t.Fatalf("builtin: found %t after %d discoveries, want found without discovery", ok, calls)
+	}
+
+	if names := Registered(CanList); len(names) != 0 || calls != 0 {
+		t.Fatalf("registered: %v after %d discoveries, want none without discovery", names, calls)
+	}
+
+	if _, ok := Lookup("discovered"); !ok || calls != 1 {
+		t.Fatalf("discovered: found %t after %d discoveries, want found after one", ok, calls)
+	}
+
+	if _, ok := Lookup("unknown"); ok || calls != 1 {
+		t.Fatalf("unknown: found %t after %d discoveries, want not found after one", ok, calls)
+	}
+
+	if names := Names(CanList); len(names) != 1 || names[0] != "discovered" || calls != 1 {
+		t.Fatalf("names: %v after %d discoveries, want [discovered] after one", names, calls)
+	}
+}
@@ -4,6 +4,11 @@ import (
 	"command/loader"
 	"errors"
 	"strings"
+
+	"provider"
+	"provider/utils"
+
+	"golang.org/x/net/context"
 )
 
 // SLCommand implements the images various interfaces, such as Fetcher,
@@ -12,6 +17,15 @@ type SLCommand struct {
 	*SLImages
 }
 
+func init() {
+	provider.Register(&provider.Registration{
+		Name:         "sl",
+		New:          newProvider,
+		Capabilities: provider.CanList | provider.CanDelete | provider.CanModify | provider.CanCopy | provider.CanWait,
+		Help:         Help,
+	})
+}
+
 // NewCommand returns a new instance of SLCommand
 func NewCommand(args []string) (*SLCommand, []string, error) {
 	var conf struct {
@@ -36,36 +50,66 @@ func NewCommand(args []string) (*SLCommand, []string, error) {
 	}, remainingArgs, nil
 }
 
-// List implements the command.Lister interface
-func (cmd *SLCommand) List(args []string) error {
+// newProvider implements the provider.Factory func type
+func newProvider(args []string) (provider.Provider, []string, error) {
+	cmd, remainingArgs, err := NewCommand(args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cmd, remainingArgs, nil
+}
+
+// Name implements the provider.Provider interface
+func (cmd *SLCommand) Name() string { return "sl" }
+
+// Fetch implements the provider.Provider interface
+func (cmd *SLCommand) Fetch(ctx context.Context, args []string) (provider.Images, error) {
 	l := newListFlags()
 	if err := l.flagSet.Parse(args); err != nil {
-		return nil // we don't return error, the usage will be printed instead
+		return nil, err
+	}
+
+	images, err := cmd.listImages(ctx, l)
+	if err != nil {
+		return nil, err
 	}
 
+	return images.Normalize(), nil
+}
+
+// SelectArgs implements the command.Selector interface
+func (cmd *SLCommand) SelectArgs(images provider.Images) []string {
+	return []string{"-ids", strings.Join(images.IDs(), ",")}
+}
+
+// SplitListArgs implements the command.ListArgsSplitter interface. System and
+// not taggable images are selected from only with the all flag.
+func (cmd *SLCommand) SplitListArgs(args []string) (list, rest []string) {
+	return utils.SplitFlags(newListFlags().flagSet, args, "all")
+}
+
+// listImages returns the images matching the given list flags
+func (cmd *SLCommand) listImages(ctx context.Context, l *listFlags) (Images, error) {
 	if len(l.imageIds) == 1 {
-		image, err := cmd.ImageByID(l.imageIds[0])
+		image, err := cmd.ImageByID(ctx, l.imageIds[0])
 		if err != nil {
-			return err
+			return nil, err
 		}
-		return (Images{image}).Print(l.output)
+		return Images{image}, nil
 	}
 
 	if len(l.imageIds) != 0 {
-		images, err := cmd.ImagesByIDs(l.imageIds...)
-		if err != nil {
-			return err
-		}
-		return images.Print(l.output)
+		return cmd.ImagesByIDs(ctx, l.imageIds...)
 	}
 
-	images, err := cmd.Images()
+	images, err := cmd.Images(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if l.all {
-		return images.Print(l.output)
+		return images, nil
 	}
 
 	var filtered Images
@@ -77,12 +121,12 @@ func (cmd *SLCommand) List(args []string) error {
 		}
 		filtered = append(filtered, img)
 	}
-	return filtered.Print(l.output)
+	return filtered, nil
 }
 
 // Modify manages the tags of the given images. It can create, override or
 // delete tags associated with the given Template ids.
-func (cmd *SLCommand) Modify(args []string) error {
+func (cmd *SLCommand) Modify(ctx context.Context, args []string) error {
 	l := newModifyFlags()
 	if err := l.flagSet.Parse(args); err != nil {
 		return nil // we don't return error, the usage will be printed instead
@@ -103,17 +147,17 @@ func (cmd *SLCommand) Modify(args []string) error {
 				delete(orig, k)
 			}
 		}
-		return cmd.patchTags(patchFn, l.force, l.imageIds...)
+		return cmd.patchTags(ctx, patchFn, l.force, l.imageIds...)
 	} else if len(createTags) != 0 {
-		return cmd.createTags(createTags, l.force, l.imageIds...)
+		return cmd.createTags(ctx, createTags, l.force, l.imageIds...)
 	} else if len(deleteTags) != 0 {
-		return cmd.deleteTags(deleteTags, l.force, l.imageIds...)
+		return cmd.deleteTags(ctx, deleteTags, l.force, l.imageIds...)
 	}
 	return errors.New("neither -create-tags nor -delete-tags flag was specified")
 }
 
 // Delete deletes Block Device Templates by the given ids.
-func (cmd *SLCommand) Delete(args []string) error {
+func (cmd *SLCommand) Delete(ctx context.Context, args []string) error {
 	l := newModifyFlags()
 	if err := l.flagSet.Parse(args); err != nil {
 		return nil // we don't return error, the usage will be printed instead
@@ -123,26 +167,41 @@ func (cmd *SLCommand) Delete(args []string) error {
 		return errors.New("no value for -ids flag")
 	}
 
-	return cmd.DeleteImages(l.imageIds...)
+	return cmd.DeleteImages(ctx, l.imageIds...)
+}
+
+// Wait waits until the transactions of the given images are finished.
+func (cmd *SLCommand) Wait(ctx context.Context, args []string) error {
+	l := newWaitFlags()
+	if err := l.flagSet.Parse(args); err != nil {
+		return nil // we don't return error, the usage will be printed instead
+	}
+
+	if len(l.imageIds) == 0 {
+		return errors.New("no value for -ids flag")
+	}
+
+	return cmd.WaitImages(ctx, l.imageIds...)
 }
 
 // Copy copies the image to different datacenters.
-func (cmd *SLCommand) Copy(args []string) error {
+func (cmd *SLCommand) Copy(ctx context.Context, args []string) error {
 	l := newCopyFlags()
 	if err := l.flagSet.Parse(args); err != nil {
 		return nil // we don't return error, the usage will be printed instead
 	}
 
-	return cmd.CopyToDatacenters(l.imageID, l.datacenters...)
+	return cmd.copyImage(ctx, l)
 }
 
-// Help prints the help message for the given command
-func (a *SLCommand) Help(command string) string {
+// Help returns the help message for the given command
+func Help(command string) string {
 	var help string
 
 	global := `
   -username        "..."       Sofleyer Username (env: IMAGES_SL_USERNAME)
   -api-key         "..."       Softlayer API Key (env: IMAGES_SL_API_KEY)
+  -request-timeout "30s"       Timeout of a single request (env: IMAGES_SL_REQUEST_TIMEOUT)
 `
 	switch command {
 	case "modify":
@@ -153,6 +212,8 @@ func (a *SLCommand) Help(command string) string {
 		help = newDeleteFlags().helpMsg
 	case "copy":
 		help = newCopyFlags().helpMsg
+	case "wait":
+		help = newWaitFlags().helpMsg
 	default:
 		return "no help found for command " + command
 	}
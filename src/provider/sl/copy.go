@@ -9,12 +9,17 @@ import (
 	"os"
 	"time"
 
+	"provider/utils"
+
 	"github.com/fatih/flags"
+	"golang.org/x/net/context"
 )
 
 type copyFlags struct {
 	imageID     int
 	datacenters []string
+	wait        bool
+	waitTimeout time.Duration
 
 	helpMsg string
 	flagSet *flag.FlagSet
@@ -26,6 +31,8 @@ func newCopyFlags() *copyFlags {
 	flagSet := flag.NewFlagSet("copy", flag.ContinueOnError)
 	flagSet.IntVar(&c.imageID, "id", 0, "Image to be copied with the given id")
 	flagSet.Var(flags.NewStringSlice(nil, &c.datacenters), "to", "Images to be copied to the given datacenters")
+	flagSet.BoolVar(&c.wait, "wait", false, "Wait until the copies are finished")
+	flagSet.Var(utils.NewDurationValue(time.Hour, &c.waitTimeout), "wait-timeout", "Maximum duration of waiting")
 
 	c.helpMsg = `Usage: images copy --providers sl [options]
 
@@ -35,10 +42,12 @@ Options:
 
   -id      "123"           Image to be copied with the given id
   -to      "dal05,..."     Image to be copied to the given datacenters
+  -wait                    Wait until the copies are finished
+  -wait-timeout "1h"       Maximum duration of waiting (default: "1h")
 `
 
 	flagSet.Usage = func() {
-		fmt.Fprintf(os.Stderr, c.helpMsg)
+		fmt.Fprint(os.Stderr, c.helpMsg)
 	}
 
 	flagSet.SetOutput(ioutil.Discard) // don't print anything without my permission
@@ -46,8 +55,8 @@ Options:
 	return c
 }
 
-func (img *SLImages) datacentersByName(names ...string) ([]*Datacenter, error) {
-	p, err := img.client.DoRawHttpRequest("SoftLayer_Location/getDatacenters.json", "GET", empty)
+func (img *SLImages) datacentersByName(ctx context.Context, names ...string) ([]*Datacenter, error) {
+	p, err := img.doRequest(ctx, "SoftLayer_Location/getDatacenters.json", "GET", empty)
 	if err != nil {
 		return nil, err
 	}
@@ -80,19 +89,48 @@ func (img *SLImages) datacentersByName(names ...string) ([]*Datacenter, error) {
 	return filtered, nil
 }
 
-func (img *SLImages) CopyToDatacenters(id int, datacenters ...string) error {
-	image, err := img.ImageByID(id)
+// copyImage copies the image to the datacenters given by the flags and prints
+// the progress. SoftLayer keeps the id of the image in all datacenters.
+func (img *SLImages) copyImage(ctx context.Context, c *copyFlags) error {
+	progress := utils.NewProgress(os.Stdout, c.datacenters...)
+	setAll := func(format string, args ...interface{}) {
+		for _, datacenter := range c.datacenters {
+			progress.Set(datacenter, format, args...)
+		}
+	}
+
+	if err := img.CopyToDatacenters(ctx, c.imageID, c.datacenters...); err != nil {
+		setAll("failed")
+		return err
+	}
+
+	setAll("%d  copying", c.imageID)
+	if !c.wait {
+		return nil
+	}
+
+	if err := img.WaitReady(ctx, c.imageID, c.waitTimeout); err != nil {
+		setAll("%d  failed", c.imageID)
+		return err
+	}
+
+	setAll("%d  available", c.imageID)
+	return nil
+}
+
+func (img *SLImages) CopyToDatacenters(ctx context.Context, id int, datacenters ...string) error {
+	image, err := img.ImageByID(ctx, id)
 	if err != nil {
 		return err
 	}
 
 	datacenters = append(datacenters, image.datacenters()...)
-	d, err := img.datacentersByName(datacenters...)
+	d, err := img.datacentersByName(ctx, datacenters...)
 	if err != nil {
 		return err
 	}
 
-	if err := img.WaitReady(id, 2*time.Minute); err != nil {
+	if err := img.WaitReady(ctx, id, 2*time.Minute); err != nil {
 		return err
 	}
 
@@ -107,7 +145,7 @@ func (img *SLImages) CopyToDatacenters(id int, datacenters ...string) error {
 
 	path := fmt.Sprintf("%s/%d/addLocations.json", img.block.GetName(), id)
 
-	p, err = img.client.DoRawHttpRequest(path, "POST", bytes.NewBuffer(p))
+	p, err = img.doRequest(ctx, path, "POST", bytes.NewBuffer(p))
 	if err != nil {
 		return err
 	}
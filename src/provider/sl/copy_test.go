@@ -0,0 +1,154 @@
+package sl
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// fakeCopy returns a handler of a fake API with image 1 in dal05, which
+// responds to addLocations with the given body.
+func fakeCopy(t *testing.T, addLocations string, mu *sync.Mutex, added *[]string, transactions *int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/")
+		method := parts[len(parts)-1]
+
+		switch method {
+		case "getObject.json":
+			fmt.Fprint(w, `{"id": 1, "name": "base", "datacenter": {"id": 10, "name": "dal05"}}`)
+		case "getDatacenters.json":
+			fmt.Fprint(w, `[{"id": 10, "name": "dal05"}, {"id": 20, "name": "ams01"}, {"id": 30, "name": "sjc01"}]`)
+		case "getParent.json":
+			fmt.Fprint(w, "null")
+		case "getBlockDevices.json":
+			fmt.Fprint(w, "[]")
+		case "getTransaction.json":
+			mu.Lock()
+			*transactions++
+			mu.Unlock()
+			fmt.Fprint(w, "null")
+		case "addLocations.json":
+			var req struct {
+				Parameters [][]*Datacenter `json:"parameters"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Parameters) != 1 {
+				t.Errorf("addLocations parameters are %+v, %v", req, err)
+			}
+
+			mu.Lock()
+			for _, d := range req.Parameters[0] {
+				*added = append(*added, fmt.Sprintf("%d:%s", d.ID, d.Name))
+			}
+			mu.Unlock()
+
+			fmt.Fprint(w, addLocations)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+	}
+}
+
+func TestCopyImage(t *testing.T) {
+	for _, wait := range []bool{false, true} {
+		var (
+			mu           sync.Mutex
+			added        []string
+			transactions int
+		)
+
+		img, closeFn := newFakeImages(t, fakeCopy(t, "true", &mu, &added, &transactions), 0)
+
+		err := img.copyImage(context.Background(), &copyFlags{
+			imageID:     1,
+			datacenters: []string{"ams01"},
+			wait:        wait,
+			waitTimeout: time.Minute,
+		})
+		closeFn()
+
+		if err != nil {
+			t.Errorf("wait %t: %s", wait, err)
+			continue
+		}
+
+		// the existing datacenter of the image is kept
+		sort.Strings(added)
+		if strings.Join(added, ",") != "10:dal05,20:ams01" {
+			t.Errorf("wait %t: added locations are %q, want dal05 and ams01", wait, added)
+		}
+
+		// the transactions are checked once before the copy and once more
+		// while waiting
+		want := 1
+		if wait {
+			want = 2
+		}
+		if transactions != want {
+			t.Errorf("wait %t: transactions are queried %d times, want %d", wait, transactions, want)
+		}
+	}
+}
+
+func TestCopyImageErrors(t *testing.T) {
+	tests := []struct {
+		addLocations string
+		err          string
+	}{
+		{
+			addLocations: `{"error": "Image is in use", "code": "SoftLayer_Exception"}`,
+			err:          "Image is in use",
+		},
+		{
+			addLocations: "false",
+			err:          "failed copying image=1",
+		},
+	}
+
+	for _, test := range tests {
+		var (
+			mu           sync.Mutex
+			added        []string
+			transactions int
+		)
+
+		img, closeFn := newFakeImages(t, fakeCopy(t, test.addLocations, &mu, &added, &transactions), 0)
+
+		err := img.copyImage(context.Background(), &copyFlags{imageID: 1, datacenters: []string{"ams01"}})
+		closeFn()
+
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: error is %v, want %q", test.addLocations, err, test.err)
+		}
+	}
+}
+
+func TestDatacentersByName(t *testing.T) {
+	var (
+		mu           sync.Mutex
+		added        []string
+		transactions int
+	)
+
+	img, closeFn := newFakeImages(t, fakeCopy(t, "true", &mu, &added, &transactions), 0)
+	defer closeFn()
+
+	d, err := img.datacentersByName(context.Background(), "sjc01", "unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(d) != 1 || d[0].ID != 30 {
+		t.Errorf("datacenters are %+v, want only sjc01", d)
+	}
+
+	if _, err := img.datacentersByName(context.Background(), "unknown"); err == nil {
+		t.Error("no error for an unknown datacenter")
+	}
+}
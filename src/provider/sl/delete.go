@@ -5,9 +5,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strconv"
+	"sync"
+
+	"provider/utils"
 
 	"github.com/fatih/flags"
 	"github.com/hashicorp/go-multierror"
+	"golang.org/x/net/context"
 )
 
 type deleteFlags struct {
@@ -31,7 +36,7 @@ Options:
   -ids         "123,..."   Images to be deleted with the given ids
 `
 	flagSet.Usage = func() {
-		fmt.Fprintf(os.Stderr, d.helpMsg)
+		fmt.Fprint(os.Stderr, d.helpMsg)
 	}
 
 	flagSet.SetOutput(ioutil.Discard) // don't print anything without my permission
@@ -39,20 +44,40 @@ Options:
 	return d
 }
 
-// Delete deletes the given images.
-func (img *SLImages) DeleteImages(ids ...int) error {
-	var err error
-	for _, id := range ids {
+// Delete deletes the given images, at most parallelism of them concurrently.
+func (img *SLImages) DeleteImages(ctx context.Context, ids ...int) error {
+	var (
+		mu  sync.Mutex // protects err
+		err error
+	)
+
+	tracker := utils.NewTracker(itoa(ids)...)
+	utils.ForEach(ctx, utils.Parallelism(ctx, img.parallelism), len(ids), func(i int) {
+		id := ids[i]
 		path := fmt.Sprintf("%s/%d.json", img.block.GetName(), id)
-		p, e := img.client.DoRawHttpRequest(path, "DELETE", empty)
-		if e != nil {
-			err = multierror.Append(err, fmt.Errorf("error deleting %d: %s", e))
-			continue
+		p, e := img.doRequest(ctx, path, "DELETE", empty)
+		if e == nil {
+			e = newError(p)
 		}
 
-		if e := newError(p); e != nil {
-			err = multierror.Append(err, fmt.Errorf("error deleting %d: %s", e))
+		if e != nil {
+			mu.Lock()
+			err = multierror.Append(err, fmt.Errorf("error deleting %d: %s", id, e))
+			mu.Unlock()
+			return
 		}
+
+		tracker.Done(strconv.Itoa(id))
+	})
+
+	return tracker.Err(ctx, err)
+}
+
+// itoa converts the given ids to strings
+func itoa(ids []int) []string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = strconv.Itoa(id)
 	}
-	return err
+	return s
 }
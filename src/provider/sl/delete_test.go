@@ -0,0 +1,67 @@
+package sl
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestDeleteImages(t *testing.T) {
+	var (
+		mu           sync.Mutex
+		active, peak int
+		deleted      []string
+		parallelism  = 2
+	)
+
+	img, closeFn := newFakeImages(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "DELETE" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			return
+		}
+
+		parts := strings.Split(r.URL.Path, "/")
+		id := strings.TrimSuffix(parts[len(parts)-1], ".json")
+
+		mu.Lock()
+		if active++; active > peak {
+			peak = active
+		}
+		mu.Unlock()
+
+		time.Sleep(50 * time.Millisecond)
+
+		mu.Lock()
+		active--
+		deleted = append(deleted, id)
+		mu.Unlock()
+
+		if id == "3" {
+			fmt.Fprint(w, `{"error": "Object not found", "code": "SoftLayer_Exception_ObjectNotFound"}`)
+			return
+		}
+		fmt.Fprint(w, "true")
+	}, parallelism)
+	defer closeFn()
+
+	err := img.DeleteImages(context.Background(), 1, 2, 3, 4, 5)
+	if err == nil || !strings.Contains(err.Error(), "error deleting 3: Object not found") {
+		t.Errorf("error is %v, want the error of image 3", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(deleted) != 5 {
+		t.Errorf("deleted images are %v, want all 5", deleted)
+	}
+
+	if peak != parallelism {
+		t.Errorf("%d images are deleted concurrently, want %d", peak, parallelism)
+	}
+}
@@ -2,16 +2,12 @@ package sl
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
-	"os"
+	"strconv"
 	"strings"
 	"time"
 
-	"provider/utils"
-
-	"github.com/fatih/color"
-	"github.com/shiena/ansicolor"
+	"provider"
 )
 
 // Transaction represents an ongoing resource transaction.
@@ -41,13 +37,6 @@ type Image struct {
 	NotTaggable bool `json:"-"`
 }
 
-func (img *Image) globalID() string {
-	if img.GlobalID != "" {
-		return img.GlobalID
-	}
-	return "-"
-}
-
 func (img *Image) datacenters() []string {
 	var names []string
 	if img.Datacenter != nil {
@@ -62,13 +51,6 @@ func (img *Image) datacenters() []string {
 	return names
 }
 
-func (img *Image) tags() string {
-	if img.NotTaggable {
-		return "-"
-	}
-	return img.Tags.String()
-}
-
 // decode unmarshals tags from description or mark as non taggable when decoding fails.
 func (img *Image) decode() {
 	if err := json.Unmarshal([]byte(img.Note), &img.Tags); err != nil {
@@ -107,42 +89,27 @@ func (img Images) Len() int           { return len(img) }
 func (img Images) Less(i, j int) bool { return img[i].ID < img[i].ID }
 func (img Images) Swap(i, j int)      { img[i], img[j] = img[j], img[i] }
 
-// Print prints the images to standard output.
-func (img Images) Print(mode utils.OutputMode) error {
-	if len(img) == 0 {
-		return errors.New("no images found (use -all flag)")
-	}
-
-	switch mode {
-	case utils.JSON:
-		p, err := json.MarshalIndent(img, "", "    ")
-		if err != nil {
-			return err
+// Normalize returns the provider agnostic representation of the images.
+func (img Images) Normalize() provider.Images {
+	images := make(provider.Images, len(img))
+	for i, image := range img {
+		normalized := &provider.Image{
+			Provider:  "sl",
+			Region:    strings.Join(image.datacenters(), ","),
+			ID:        strconv.Itoa(image.ID),
+			Name:      image.Name,
+			CreatedAt: image.CreateDate,
+			Raw:       image,
 		}
 
-		fmt.Println(string(p))
-		return nil
-	case utils.Simplified:
-		green := color.New(color.FgGreen).SprintfFunc()
-		w := utils.NewImagesTabWriter(ansicolor.NewAnsiColorWriter(os.Stdout))
-		defer w.Flush()
-
-		fmt.Fprintln(w, green("Softlayer (%d images):", len(img)))
-		fmt.Fprintln(w, "    Name\tID\tGlobalID\tCreated\tDatacenters\tTags")
-
-		for i, image := range img {
-			created := "-"
-			if !image.CreateDate.IsZero() {
-				created = image.CreateDate.Format(time.RFC3339)
+		if !image.NotTaggable && len(image.Tags) != 0 {
+			normalized.Tags = make(map[string]string, len(image.Tags))
+			for k, v := range image.Tags {
+				normalized.Tags[k] = v
 			}
-			fmt.Fprintf(w, "[%d] %s\t%d\t%s\t%s\t%s\t%s\n", i, image.Name, image.ID,
-				image.globalID(), created, strings.Join(image.datacenters(), ","), image.tags())
 		}
 
-		fmt.Fprintln(w)
-		return nil
-	default:
-		return fmt.Errorf("output mode %q is not valid", mode)
+		images[i] = normalized
 	}
-	return nil
+	return images
 }
@@ -13,6 +13,7 @@ import (
 	"provider/utils"
 
 	"github.com/fatih/flags"
+	"golang.org/x/net/context"
 )
 
 type listFlags struct {
@@ -42,11 +43,11 @@ Options:
                        and not taggable ones as well.
                        By default only taggable images are displayed.
   -output  "json"      Output mode of images. (default: "simplified")
-                       Available options: "json" or "simplified"
+                       Available options: ` + utils.OutputsHelp + `
 `
 
 	flagSet.Usage = func() {
-		fmt.Fprintf(os.Stderr, l.helpMsg)
+		fmt.Fprint(os.Stderr, l.helpMsg)
 	}
 	flagSet.SetOutput(ioutil.Discard) // don't print anything without my permission
 	l.flagSet = flagSet
@@ -57,10 +58,10 @@ Options:
 var empty = &bytes.Buffer{}
 
 // Images returns all images. If not images are found, it returns non-nil error.
-func (img *SLImages) Images() (Images, error) {
+func (img *SLImages) Images(ctx context.Context) (Images, error) {
 	var images []*Image
 	path := fmt.Sprintf("%s/getBlockDeviceTemplateGroups.json", img.account.GetName())
-	p, err := img.client.DoRawHttpRequestWithObjectMask(path, imageMask, "GET", empty)
+	p, err := img.doRequest(ctx, path, "GET", empty, imageMask...)
 	if err != nil {
 		return nil, err
 	}
@@ -87,8 +88,8 @@ func (img *SLImages) Images() (Images, error) {
 
 // ImagesByIDs looks up all images and then it filters them by the given
 // IDs. If at least one image is not found, it returns non-nil error.
-func (img *SLImages) ImagesByIDs(ids ...int) (Images, error) {
-	images, err := img.Images()
+func (img *SLImages) ImagesByIDs(ctx context.Context, ids ...int) (Images, error) {
+	images, err := img.Images(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -125,9 +126,9 @@ func (img *SLImages) ImagesByIDs(ids ...int) (Images, error) {
 }
 
 // ImageByID looks up an image by the given ID.
-func (img *SLImages) ImageByID(id int) (*Image, error) {
+func (img *SLImages) ImageByID(ctx context.Context, id int) (*Image, error) {
 	path := fmt.Sprintf("%s/%d/getObject.json", img.block.GetName(), id)
-	p, err := img.client.DoRawHttpRequestWithObjectMask(path, imageMask, "GET", empty)
+	p, err := img.doRequest(ctx, path, "GET", empty, imageMask...)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,54 @@
+package sl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"provider"
+
+	"golang.org/x/net/context"
+)
+
+// Describe returns all attributes of the given image, including its
+// datacenters, child images and the ongoing transaction.
+func (img *SLImages) Describe(ctx context.Context, image *provider.Image) (*provider.Details, error) {
+	raw, ok := image.Raw.(*Image)
+	if !ok {
+		return nil, fmt.Errorf("image '%s' is not a SoftLayer image", image.ID)
+	}
+
+	d := provider.NewDetails(image)
+	d.Add("Global ID", raw.GlobalID)
+	if raw.ParentID != 0 {
+		d.Add("Parent ID", strconv.Itoa(raw.ParentID))
+	}
+	if raw.NotTaggable {
+		d.Add("Note", raw.Note)
+	}
+	d.Add("Datacenters", raw.datacenters()...)
+
+	children, err := img.Children(ctx, raw.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	childs := make([]string, 0, len(children))
+	for _, child := range children {
+		childs = append(childs, strings.TrimSpace(strconv.Itoa(child.ID)+" "+strings.Join(child.datacenters(), ",")))
+	}
+	d.Add("Children", childs...)
+
+	t, err := img.Transaction(ctx, raw.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if t != nil && t.ID != 0 {
+		d.Add("Transaction", strconv.Itoa(t.ID))
+	} else {
+		d.Add("Transaction", "none")
+	}
+
+	return d, nil
+}
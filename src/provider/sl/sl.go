@@ -4,22 +4,52 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
 	"os"
+	"strings"
 	"time"
 
+	"provider/utils"
+
 	slclient "github.com/maximilien/softlayer-go/client"
 	"github.com/maximilien/softlayer-go/softlayer"
+	"golang.org/x/net/context"
 )
 
 // SLConfig represents a configuration section of .imagesrc for sl provider.
 type SLConfig struct {
 	Username string `toml:"username" json:"username"`
 	APIKey   string `toml:"api_key" json:"api_key"`
+
+	// RequestTimeout is the timeout of a single request, i.e: "1m" (default:
+	// "30s"). The duration of a whole command is limited by the global
+	// timeout setting.
+	RequestTimeout string `toml:"request_timeout" json:"request_timeout"`
+
+	// Retries is the maximum number of retries of a failed or rate limited
+	// request (default: 5)
+	Retries int `toml:"retries" json:"retries" default:"5"`
+
+	// Parallelism is the maximum number of concurrent requests (default:
+	// the global parallelism)
+	Parallelism int `toml:"parallelism" json:"parallelism"`
 }
 
 // SLImages is responsible of managing Softlayer Virtual Disk Images.
 type SLImages struct {
-	client softlayer.Client
+	username string
+	apiKey   string
+
+	// httpClient sends the requests instead of the softlayer-go client,
+	// which doesn't support timeouts or canceling requests.
+	httpClient *http.Client
+	retry      *utils.Retry
+
+	// parallelism is the parallelism setting of the provider, zero uses the
+	// global setting
+	parallelism int
 
 	account softlayer.SoftLayer_Account_Service
 	block   softlayer.SoftLayer_Virtual_Guest_Block_Device_Template_Group_Service
@@ -31,6 +61,21 @@ func New(conf *SLConfig) (*SLImages, error) {
 	if err := os.Setenv("SL_GO_NON_VERBOSE", "YES"); err != nil {
 		return nil, err
 	}
+
+	timeout, err := utils.ParseRequestTimeout(conf.RequestTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	retry, err := utils.NewRetry(conf.Retries)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := utils.ValidateParallelism(conf.Parallelism); err != nil {
+		return nil, err
+	}
+
 	client := slclient.NewSoftLayerClient(conf.Username, conf.APIKey)
 	account, err := client.GetSoftLayer_Account_Service()
 	if err != nil {
@@ -43,20 +88,59 @@ func New(conf *SLConfig) (*SLImages, error) {
 	}
 
 	return &SLImages{
-		client:  client,
-		account: account,
-		block:   block,
+		username:    conf.Username,
+		apiKey:      conf.APIKey,
+		httpClient:  utils.NewRetryClient(timeout, retry),
+		retry:       retry,
+		parallelism: conf.Parallelism,
+		account:     account,
+		block:       block,
 	}, nil
 }
 
+// Retries returns the number of retried requests and how many of them were
+// rate limited.
+func (img *SLImages) Retries() (total, throttled int) {
+	return img.retry.Retries()
+}
+
+// doRequest sends a request to the SoftLayer REST API and returns the
+// response body. The given object masks are added to the request. The
+// request is canceled once ctx is done.
+func (img *SLImages) doRequest(ctx context.Context, path, method string, body *bytes.Buffer, masks ...string) ([]byte, error) {
+	url := fmt.Sprintf("https://%s/%s", slclient.SOFTLAYER_API_URL, path)
+	if len(masks) != 0 {
+		url += "?objectMask=" + strings.Join(masks, ";")
+	}
+
+	var r io.Reader
+	if body != nil {
+		r = body
+	}
+
+	req, err := http.NewRequest(method, url, r)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(img.username, img.apiKey)
+
+	resp, err := img.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return ioutil.ReadAll(resp.Body)
+}
+
 // Transaction returns an ongoing transaction for the image given by the id.
 //
 // It returns non-nil error when querying the service failed.
 // It return nil Transaction and nil error when there are no ongoing
 // transactions.
-func (img *SLImages) Transaction(id int) (*Transaction, error) {
+func (img *SLImages) Transaction(ctx context.Context, id int) (*Transaction, error) {
 	path := fmt.Sprintf("%s/%d/getTransaction.json", img.block.GetName(), id)
-	p, err := img.client.DoRawHttpRequest(path, "GET", empty)
+	p, err := img.doRequest(ctx, path, "GET", empty)
 	if err != nil {
 		return nil, err
 	}
@@ -78,10 +162,10 @@ func (img *SLImages) Transaction(id int) (*Transaction, error) {
 }
 
 // WaitReady waits at most d until all ongoing transactions for image
-// given by the id are finished.
-func (img *SLImages) WaitReady(id int, d time.Duration) error {
+// given by the id are finished. It stops waiting once ctx is done.
+func (img *SLImages) WaitReady(ctx context.Context, id int, d time.Duration) error {
 	timeout := time.After(d)
-	parent, err := img.Parent(id)
+	parent, err := img.Parent(ctx, id)
 	if err != nil {
 		return err
 	}
@@ -90,7 +174,7 @@ func (img *SLImages) WaitReady(id int, d time.Duration) error {
 		id = parent.ID
 	}
 
-	children, err := img.Children(id)
+	children, err := img.Children(ctx, id)
 	if err != nil {
 		return err
 	}
@@ -109,7 +193,7 @@ func (img *SLImages) WaitReady(id int, d time.Duration) error {
 			var ongoing bool
 
 			for _, id := range ids {
-				t, err := img.Transaction(id)
+				t, err := img.Transaction(ctx, id)
 				if err != nil {
 					return err
 				}
@@ -124,7 +208,9 @@ func (img *SLImages) WaitReady(id int, d time.Duration) error {
 				return nil
 			}
 
-			time.Sleep(5 * time.Second)
+			if err := utils.Sleep(ctx, 5*time.Second); err != nil {
+				return err
+			}
 		}
 	}
 }
@@ -132,9 +218,9 @@ func (img *SLImages) WaitReady(id int, d time.Duration) error {
 // Parent returns a parent image for the one given by the id.
 //
 // It returns nil Image and nil error if the image has no parent.
-func (img *SLImages) Parent(id int) (*Image, error) {
+func (img *SLImages) Parent(ctx context.Context, id int) (*Image, error) {
 	path := fmt.Sprintf("%s/%d/getParent.json", img.block.GetName(), id)
-	p, err := img.client.DoRawHttpRequest(path, "GET", empty)
+	p, err := img.doRequest(ctx, path, "GET", empty)
 	if err != nil {
 		return nil, err
 	}
@@ -159,9 +245,9 @@ func (img *SLImages) Parent(id int) (*Image, error) {
 // Children returns children images for the one given by the id.
 //
 // It returns nil Images and nil error if the images has no children.
-func (img *SLImages) Children(id int) (Images, error) {
+func (img *SLImages) Children(ctx context.Context, id int) (Images, error) {
 	path := fmt.Sprintf("%s/%d/getBlockDevices.json", img.block.GetName(), id)
-	p, err := img.client.DoRawHttpRequest(path, "GET", empty)
+	p, err := img.doRequest(ctx, path, "GET", empty)
 	if err != nil {
 		return nil, err
 	}
@@ -183,7 +269,7 @@ func (img *SLImages) Children(id int) (Images, error) {
 }
 
 // EditImage edits non-zero fields of the image given by the id.
-func (img *SLImages) EditImage(id int, fields *Image) error {
+func (img *SLImages) EditImage(ctx context.Context, id int, fields *Image) error {
 	if fields == nil {
 		return nil
 	}
@@ -201,7 +287,7 @@ func (img *SLImages) EditImage(id int, fields *Image) error {
 	}
 
 	path := fmt.Sprintf("%s/%d/editObject.json", img.block.GetName(), id)
-	p, err = img.client.DoRawHttpRequest(path, "POST", bytes.NewBuffer(p))
+	p, err = img.doRequest(ctx, path, "POST", bytes.NewBuffer(p))
 	if err != nil {
 		return err
 	}
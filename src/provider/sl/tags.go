@@ -7,10 +7,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strconv"
 	"strings"
 
+	"provider/utils"
+
 	"github.com/fatih/flags"
 	"github.com/hashicorp/go-multierror"
+	"golang.org/x/net/context"
 )
 
 // Tags holds key-value tags for an image.
@@ -76,7 +80,7 @@ Options:
   -f                           Force creation of tags on not taggable image.
 `
 	flagSet.Usage = func() {
-		fmt.Fprintf(os.Stderr, m.helpMsg)
+		fmt.Fprint(os.Stderr, m.helpMsg)
 	}
 
 	flagSet.SetOutput(ioutil.Discard) // don't print anything without my permission
@@ -84,7 +88,7 @@ Options:
 	return m
 }
 
-func (img *SLImages) createTags(tags Tags, force bool, imageIDs ...int) error {
+func (img *SLImages) createTags(ctx context.Context, tags Tags, force bool, imageIDs ...int) error {
 	if len(tags) == 0 {
 		return errors.New("not tags to create")
 	}
@@ -94,10 +98,10 @@ func (img *SLImages) createTags(tags Tags, force bool, imageIDs ...int) error {
 			orig[k] = v
 		}
 	}
-	return img.patchTags(patchFn, force, imageIDs...)
+	return img.patchTags(ctx, patchFn, force, imageIDs...)
 }
 
-func (img *SLImages) deleteTags(tags Tags, force bool, imageIDs ...int) error {
+func (img *SLImages) deleteTags(ctx context.Context, tags Tags, force bool, imageIDs ...int) error {
 	if len(tags) == 0 {
 		return errors.New("not tags to delete")
 	}
@@ -107,15 +111,20 @@ func (img *SLImages) deleteTags(tags Tags, force bool, imageIDs ...int) error {
 			delete(orig, k)
 		}
 	}
-	return img.patchTags(patchFn, force, imageIDs...)
+	return img.patchTags(ctx, patchFn, force, imageIDs...)
 }
 
-func (img *SLImages) patchTags(patchFn func(orig Tags), force bool, imageIDs ...int) error {
-	images, err := img.ImagesByIDs(imageIDs...)
+func (img *SLImages) patchTags(ctx context.Context, patchFn func(orig Tags), force bool, imageIDs ...int) error {
+	images, err := img.ImagesByIDs(ctx, imageIDs...)
 	if err != nil {
 		return err
 	}
+	tracker := utils.NewTracker(itoa(imageIDs)...)
 	for _, image := range images {
+		if ctx.Err() != nil {
+			break
+		}
+
 		if image.NotTaggable && !force {
 			err = multierror.Append(err, fmt.Errorf("unable to patch not taggable image with id=%d (use -f to override)", image.ID))
 			continue
@@ -133,6 +142,7 @@ func (img *SLImages) patchTags(patchFn func(orig Tags), force bool, imageIDs ...
 		if len(fields.Tags) == 0 {
 			if oldNum == 0 {
 				// The patch is a nop, ignore.
+				tracker.Done(strconv.Itoa(image.ID))
 				continue
 			}
 			// EditImage edits only non-zero-value fields.
@@ -141,10 +151,13 @@ func (img *SLImages) patchTags(patchFn func(orig Tags), force bool, imageIDs ...
 			fields.Tags = nil
 		}
 
-		if e := img.EditImage(image.ID, fields); e != nil {
+		if e := img.EditImage(ctx, image.ID, fields); e != nil {
 			err = multierror.Append(err, fmt.Errorf("failed to patch image with id=%d: %s", image.ID, e))
+			continue
 		}
+
+		tracker.Done(strconv.Itoa(image.ID))
 	}
-	return err
+	return tracker.Err(ctx, err)
 
 }
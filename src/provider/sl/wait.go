@@ -0,0 +1,87 @@
+package sl
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"provider/utils"
+
+	"github.com/fatih/flags"
+	"github.com/hashicorp/go-multierror"
+	"golang.org/x/net/context"
+)
+
+type waitFlags struct {
+	imageIds []int
+
+	helpMsg string
+	flagSet *flag.FlagSet
+}
+
+func newWaitFlags() *waitFlags {
+	w := &waitFlags{}
+
+	flagSet := flag.NewFlagSet("wait", flag.ContinueOnError)
+	flagSet.Var(flags.NewIntSlice(nil, &w.imageIds), "ids", "Images to wait for with the given ids")
+	w.helpMsg = `Usage: images wait --providers sl [options]
+
+  Wait until the transactions of Block Device Templates are finished.
+
+Options:
+
+  -ids         "123,..."   Images to wait for with the given ids
+`
+	flagSet.Usage = func() {
+		fmt.Fprint(os.Stderr, w.helpMsg)
+	}
+
+	flagSet.SetOutput(ioutil.Discard) // don't print anything without my permission
+	w.flagSet = flagSet
+	return w
+}
+
+// WaitImages waits until the ongoing transactions of the given images are
+// finished and prints the state of each image, waiting for at most
+// parallelism of them concurrently. It waits until ctx is done, but at most an
+// hour without a deadline.
+func (img *SLImages) WaitImages(ctx context.Context, ids ...int) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Hour)
+		defer cancel()
+	}
+	deadline, _ := ctx.Deadline()
+
+	var (
+		mu  sync.Mutex // protects err
+		err error
+	)
+
+	progress := utils.NewProgress(os.Stdout, itoa(ids)...)
+	tracker := utils.NewTracker(itoa(ids)...)
+
+	utils.ForEach(ctx, utils.Parallelism(ctx, img.parallelism), len(ids), func(i int) {
+		id := ids[i]
+
+		// images which wait for a free slot share the same deadline
+		progress.Set(strconv.Itoa(id), "waiting")
+		if e := img.WaitReady(ctx, id, deadline.Sub(time.Now())); e != nil {
+			progress.Set(strconv.Itoa(id), "failed")
+
+			mu.Lock()
+			err = multierror.Append(err, fmt.Errorf("error waiting for %d: %s", id, e))
+			mu.Unlock()
+			return
+		}
+
+		progress.Set(strconv.Itoa(id), "ready")
+		tracker.Done(strconv.Itoa(id))
+	})
+
+	return tracker.Err(ctx, err)
+}
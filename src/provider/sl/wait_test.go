@@ -0,0 +1,157 @@
+package sl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"provider/utils"
+
+	slclient "github.com/maximilien/softlayer-go/client"
+	"golang.org/x/net/context"
+)
+
+// fakeTransport sends all requests to the fake API
+type fakeTransport struct{ url *url.URL }
+
+func (f fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.URL.Scheme, req.URL.Host = f.url.Scheme, f.url.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// newFakeImages returns images which send their requests to the given
+// handler.
+func newFakeImages(t *testing.T, handler http.HandlerFunc, parallelism int) (*SLImages, func()) {
+	server := httptest.NewServer(handler)
+	u, _ := url.Parse(server.URL)
+
+	block, err := slclient.NewSoftLayerClient("user", "key").GetSoftLayer_Virtual_Guest_Block_Device_Template_Group_Service()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	retry, _ := utils.NewRetry(0)
+	return &SLImages{
+		username:    "user",
+		apiKey:      "key",
+		httpClient:  &http.Client{Transport: fakeTransport{u}},
+		retry:       retry,
+		parallelism: parallelism,
+		block:       block,
+	}, server.Close
+}
+
+func TestWaitImagesParallelism(t *testing.T) {
+	var (
+		mu           sync.Mutex
+		active, peak int
+		transactions = make(map[string]int)
+		parallelism  = 2
+		ids          = []int{1, 2, 3, 4, 5}
+	)
+
+	img, closeFn := newFakeImages(t, func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/")
+		id, method := parts[len(parts)-2], parts[len(parts)-1]
+
+		switch method {
+		case "getParent.json":
+			mu.Lock()
+			active++
+			if active > peak {
+				peak = active
+			}
+			mu.Unlock()
+
+			time.Sleep(50 * time.Millisecond)
+			fmt.Fprint(w, "null")
+		case "getBlockDevices.json":
+			fmt.Fprint(w, "[]")
+		case "getTransaction.json":
+			mu.Lock()
+			transactions[id]++
+			active--
+			mu.Unlock()
+			fmt.Fprint(w, "null")
+		default:
+			t.Errorf("unexpected request %s", r.URL)
+		}
+	}, parallelism)
+	defer closeFn()
+
+	if err := img.WaitImages(context.Background(), ids...); err != nil {
+		t.Fatal(err)
+	}
+
+	if peak > parallelism {
+		t.Errorf("waited for %d images concurrently, want at most %d", peak, parallelism)
+	}
+
+	for _, id := range ids {
+		if n := transactions[fmt.Sprint(id)]; n != 1 {
+			t.Errorf("transactions of %d were queried %d times, want once", id, n)
+		}
+	}
+}
+
+func TestWaitImagesTransaction(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		polls int
+	)
+
+	img, closeFn := newFakeImages(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/getParent.json"):
+			fmt.Fprint(w, "null")
+		case strings.HasSuffix(r.URL.Path, "/getBlockDevices.json"):
+			fmt.Fprint(w, "[]")
+		case strings.HasSuffix(r.URL.Path, "/getTransaction.json"):
+			mu.Lock()
+			polls++
+			n := polls
+			mu.Unlock()
+
+			if n == 1 {
+				fmt.Fprint(w, `{"id": 100}`)
+				return
+			}
+			fmt.Fprint(w, "null")
+		}
+	}, 0)
+	defer closeFn()
+
+	if err := img.WaitImages(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if polls != 2 {
+		t.Errorf("transaction was polled %d times, want 2", polls)
+	}
+}
+
+func TestWaitImagesTimeout(t *testing.T) {
+	img, closeFn := newFakeImages(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/getParent.json"):
+			fmt.Fprint(w, "null")
+		case strings.HasSuffix(r.URL.Path, "/getBlockDevices.json"):
+			fmt.Fprint(w, "[]")
+		case strings.HasSuffix(r.URL.Path, "/getTransaction.json"):
+			fmt.Fprint(w, `{"id": 100}`)
+		}
+	}, 1)
+	defer closeFn()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	if err := img.WaitImages(ctx, 1, 2); err == nil {
+		t.Fatal("expected an error for ongoing transactions")
+	}
+}
@@ -0,0 +1,169 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"provider/utils"
+
+	"github.com/fatih/color"
+	"github.com/shiena/ansicolor"
+)
+
+// Snapshot is the provider agnostic representation of a disk snapshot, such
+// as an EBS snapshot.
+type Snapshot struct {
+	// Provider is the name of the provider the snapshot belongs to
+	Provider string `json:"provider"`
+
+	// Account is the name of the account the snapshot belongs to, if the
+	// provider is configured with multiple accounts
+	Account string `json:"account,omitempty"`
+
+	// Region is the region of the snapshot
+	Region string `json:"region,omitempty"`
+
+	// ID uniquely identifies the snapshot within the provider
+	ID string `json:"id"`
+
+	// Name is the human readable name of the snapshot
+	Name string `json:"name,omitempty"`
+
+	// Source describes what the snapshot was created for or from, i.e: the
+	// AMI or the volume
+	Source string `json:"source,omitempty"`
+
+	// CreatedAt is the creation time of the snapshot
+	CreatedAt time.Time `json:"created_at"`
+
+	// Size is the size of the snapshot in GB
+	Size int64 `json:"size,omitempty"`
+
+	// Raw is the original payload returned by the provider
+	Raw interface{} `json:"raw,omitempty"`
+}
+
+// Snapshots defines and represents a list of provider agnostic snapshots
+type Snapshots []*Snapshot
+
+// Size returns the total size of the snapshots in GB
+func (s Snapshots) Size() int64 {
+	var size int64
+	for _, snapshot := range s {
+		size += snapshot.Size
+	}
+	return size
+}
+
+// Sort sorts the snapshots by provider, account, region, creation time and ID.
+func (s Snapshots) Sort() {
+	sort.Sort(snapshotsByProvider(s))
+}
+
+// Print prints the snapshots and their total size to standard output. Only
+// the simplified and JSON output modes are supported.
+func (s Snapshots) Print(mode utils.OutputMode) error {
+	switch mode {
+	case utils.JSON:
+		out, err := json.MarshalIndent(s, "", "    ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(out))
+		return nil
+	case utils.Simplified:
+		green := color.New(color.FgGreen).SprintfFunc()
+		w := utils.NewImagesTabWriter(ansicolor.NewAnsiColorWriter(os.Stdout))
+		defer w.Flush()
+
+		for _, snapshots := range s.groups() {
+			fmt.Fprintln(w, green("%s (%d snapshot(s), %dGB):",
+				strings.ToUpper(snapshots[0].Provider), len(snapshots), snapshots.Size()))
+			account := snapshots.hasAccount()
+			if account {
+				fmt.Fprintln(w, "    ID\tName\tAccount\tRegion\tCreated\tSize\tSource")
+			} else {
+				fmt.Fprintln(w, "    ID\tName\tRegion\tCreated\tSize\tSource")
+			}
+
+			for ix, snapshot := range snapshots {
+				created := "-"
+				if !snapshot.CreatedAt.IsZero() {
+					created = snapshot.CreatedAt.Format(time.RFC3339)
+				}
+
+				fmt.Fprintf(w, "[%d] %s\t%s\t", ix+1, snapshot.ID, snapshot.Name)
+				if account {
+					fmt.Fprintf(w, "%s\t", snapshot.Account)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", snapshot.Region, created,
+					strconv.FormatInt(snapshot.Size, 10)+"GB", snapshot.Source)
+			}
+
+			fmt.Fprintln(w, "")
+		}
+
+		fmt.Fprintf(w, "Total: %d snapshot(s), %dGB\n", len(s), s.Size())
+		return nil
+	default:
+		return fmt.Errorf("output mode '%s' is not supported, use \"simplified\" or \"json\"", mode)
+	}
+}
+
+// hasAccount returns true if any snapshot belongs to a named account
+func (s Snapshots) hasAccount() bool {
+	for _, snapshot := range s {
+		if snapshot.Account != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// groups splits the snapshots into groups of the same provider. It preserves
+// the order of the snapshots.
+func (s Snapshots) groups() []Snapshots {
+	groups := make([]Snapshots, 0)
+	for _, snapshot := range s {
+		last := len(groups) - 1
+		if last >= 0 && groups[last][0].Provider == snapshot.Provider {
+			groups[last] = append(groups[last], snapshot)
+			continue
+		}
+
+		groups = append(groups, Snapshots{snapshot})
+	}
+	return groups
+}
+
+// snapshotsByProvider implements sort.Interface for Snapshots based on the
+// Provider, Account, Region, CreatedAt and ID fields.
+type snapshotsByProvider Snapshots
+
+func (a snapshotsByProvider) Len() int      { return len(a) }
+func (a snapshotsByProvider) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a snapshotsByProvider) Less(i, j int) bool {
+	if a[i].Provider != a[j].Provider {
+		return a[i].Provider < a[j].Provider
+	}
+
+	if a[i].Account != a[j].Account {
+		return a[i].Account < a[j].Account
+	}
+
+	if a[i].Region != a[j].Region {
+		return a[i].Region < a[j].Region
+	}
+
+	if !a[i].CreatedAt.Equal(a[j].CreatedAt) {
+		return a[i].CreatedAt.Before(a[j].CreatedAt)
+	}
+
+	return a[i].ID < a[j].ID
+}
@@ -0,0 +1,208 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// DefaultRequestTimeout is the timeout of a single request to a provider API
+// if the "request_timeout" setting of the provider isn't set.
+const DefaultRequestTimeout = 30 * time.Second
+
+// ParseRequestTimeout parses the "request_timeout" setting of a provider
+// configuration. An empty setting returns DefaultRequestTimeout.
+func ParseRequestTimeout(s string) (time.Duration, error) {
+	if s == "" {
+		return DefaultRequestTimeout, nil
+	}
+
+	d, err := ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid request_timeout setting: %s", err)
+	}
+
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid request_timeout setting '%s': must be positive", s)
+	}
+
+	return d, nil
+}
+
+// NewHTTPClient returns a client which uses the proxy of the environment and
+// aborts every request which takes longer than timeout.
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			TLSHandshakeTimeout: timeout,
+		},
+		Timeout: timeout,
+	}
+}
+
+// WithContext returns a copy of the given client which cancels all of its
+// requests once ctx is done.
+func WithContext(ctx context.Context, client *http.Client) *http.Client {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	c := *client
+	c.Transport = &contextTransport{ctx: ctx, base: base}
+	return &c
+}
+
+// contextTransport attaches a context to every request
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(t.ctx))
+}
+
+// Sleep pauses the current goroutine for the given duration. It returns the
+// error of ctx if ctx is done before.
+func Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+const (
+	// pollMinInterval is the interval between the first calls of Poll
+	pollMinInterval = 2 * time.Second
+
+	// pollMaxInterval is the upper limit of the interval of Poll
+	pollMaxInterval = 30 * time.Second
+)
+
+// Poll calls fn until it reports done or fails. The interval between the
+// calls grows from a few seconds up to half a minute. It fails if fn isn't
+// done within timeout, or with the error of ctx once ctx is done. A zero
+// timeout polls until ctx is done.
+func Poll(ctx context.Context, timeout time.Duration, fn func() (done bool, err error)) error {
+	deadline := time.Now().Add(timeout)
+	interval := pollMinInterval
+
+	for {
+		done, err := fn()
+		if err != nil || done {
+			return err
+		}
+
+		wait := interval
+		if timeout > 0 {
+			remaining := deadline.Sub(time.Now())
+			if remaining <= 0 {
+				return fmt.Errorf("timed out after %s", timeout)
+			}
+
+			// the last call is made right at the deadline
+			if wait > remaining {
+				wait = remaining
+			}
+		}
+
+		if err := Sleep(ctx, wait); err != nil {
+			return err
+		}
+
+		if interval = interval * 3 / 2; interval > pollMaxInterval {
+			interval = pollMaxInterval
+		}
+	}
+}
+
+// InterruptedError is returned by operations on multiple items, which are
+// canceled before all items are processed. It reports which items are
+// finished, so the operation can be continued for the remaining ones.
+type InterruptedError struct {
+	// Err is the error of the context, either context.Canceled or
+	// context.DeadlineExceeded
+	Err error
+
+	Finished []string
+	Pending  []string
+}
+
+func (e *InterruptedError) Error() string {
+	reason := "interrupted"
+	if e.Err == context.DeadlineExceeded {
+		reason = "timed out"
+	}
+
+	msg := fmt.Sprintf("%s after %d of %d item(s) finished", reason,
+		len(e.Finished), len(e.Finished)+len(e.Pending))
+
+	if len(e.Finished) != 0 {
+		msg += "\n  finished: " + strings.Join(e.Finished, ", ")
+	}
+
+	if len(e.Pending) != 0 {
+		msg += "\n  not finished: " + strings.Join(e.Pending, ", ")
+	}
+
+	return msg
+}
+
+// Tracker tracks which items of an operation are finished. It's safe for
+// concurrent use.
+type Tracker struct {
+	mu    sync.Mutex
+	items []string
+	done  map[string]bool
+}
+
+// NewTracker returns a new tracker for the given items.
+func NewTracker(items ...string) *Tracker {
+	return &Tracker{
+		items: items,
+		done:  make(map[string]bool, len(items)),
+	}
+}
+
+// Done marks the given items as finished.
+func (t *Tracker) Done(items ...string) {
+	t.mu.Lock()
+	for _, item := range items {
+		t.done[item] = true
+	}
+	t.mu.Unlock()
+}
+
+// Err returns an *InterruptedError if ctx is done, otherwise err is returned
+// as it is. Errors of canceled requests aren't interesting once the whole
+// operation is interrupted, therefore err is replaced.
+func (t *Tracker) Err(ctx context.Context, err error) error {
+	if ctx.Err() == nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e := &InterruptedError{Err: ctx.Err()}
+	for _, item := range t.items {
+		if t.done[item] {
+			e.Finished = append(e.Finished, item)
+		} else {
+			e.Pending = append(e.Pending, item)
+		}
+	}
+
+	return e
+}
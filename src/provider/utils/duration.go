@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseDuration parses a duration string. In addition to the units supported
+// by time.ParseDuration it accepts whole days ("d") and weeks ("w"), i.e:
+// "90d", "2w" or "36h".
+func ParseDuration(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	} {
+		if !strings.HasSuffix(s, suffix) {
+			continue
+		}
+
+		n, err := strconv.Atoi(strings.TrimSuffix(s, suffix))
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration '%s'", s)
+		}
+
+		return time.Duration(n) * unit, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration '%s'", s)
+	}
+
+	return d, nil
+}
+
+// DurationValue satisfies the flag.Value interface and accepts durations in
+// the format of ParseDuration.
+type DurationValue time.Duration
+
+// NewDurationValue returns a new DurationValue which stores the parsed
+// duration in p.
+func NewDurationValue(val time.Duration, p *time.Duration) *DurationValue {
+	*p = val
+	return (*DurationValue)(p)
+}
+
+func (d *DurationValue) Set(val string) error {
+	v, err := ParseDuration(val)
+	if err != nil {
+		return err
+	}
+
+	*d = DurationValue(v)
+	return nil
+}
+
+func (d *DurationValue) Get() interface{} { return time.Duration(*d) }
+
+func (d *DurationValue) String() string { return time.Duration(*d).String() }
+
+// ParseDate parses a date ("2006-01-02") or a RFC3339 time.
+func ParseDate(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time '%s', use YYYY-MM-DD or RFC3339", s)
+	}
+
+	return t, nil
+}
+
+// ParseTime parses a point in time, either a date or a RFC3339 time as
+// accepted by ParseDate, or a duration from now in the format of
+// ParseDuration, i.e: "90d".
+func ParseTime(s string) (time.Time, error) {
+	if t, err := ParseDate(s); err == nil {
+		return t, nil
+	}
+
+	d, err := ParseDuration(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time '%s', use YYYY-MM-DD, RFC3339 or a duration from now", s)
+	}
+
+	return time.Now().Add(d), nil
+}
@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		s   string
+		d   time.Duration
+		err bool
+	}{
+		{s: "90d", d: 90 * 24 * time.Hour},
+		{s: "0d", d: 0},
+		{s: "2w", d: 14 * 24 * time.Hour},
+		{s: "36h", d: 36 * time.Hour},
+		{s: "1h30m", d: 90 * time.Minute},
+		{s: "45s", d: 45 * time.Second},
+		{s: "", err: true},
+		{s: "d", err: true},
+		{s: "-1d", err: true},
+		{s: "1.5d", err: true},
+		{s: "10", err: true},
+		{s: "abc", err: true},
+	}
+
+	for _, test := range tests {
+		d, err := ParseDuration(test.s)
+		if test.err {
+			if err == nil {
+				t.Errorf("ParseDuration(%q): expected an error, got %s", test.s, d)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("ParseDuration(%q): %s", test.s, err)
+			continue
+		}
+
+		if d != test.d {
+			t.Errorf("ParseDuration(%q) = %s, want %s", test.s, d, test.d)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		s   string
+		t   time.Time
+		err bool
+	}{
+		{s: "2018-06-01", t: time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{s: "2018-06-01T12:30:00+02:00", t: time.Date(2018, 6, 1, 10, 30, 0, 0, time.UTC)},
+		{s: "90d", err: true},
+		{s: "2018-13-01", err: true},
+	}
+
+	for _, test := range tests {
+		tm, err := ParseDate(test.s)
+		if test.err {
+			if err == nil {
+				t.Errorf("ParseDate(%q): expected an error, got %s", test.s, tm)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("ParseDate(%q): %s", test.s, err)
+			continue
+		}
+
+		if !tm.Equal(test.t) {
+			t.Errorf("ParseDate(%q) = %s, want %s", test.s, tm, test.t)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		s   string
+		t   time.Time
+		err bool
+	}{
+		{s: "2018-06-01", t: time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{s: "2018-06-01T12:30:00Z", t: time.Date(2018, 6, 1, 12, 30, 0, 0, time.UTC)},
+		{s: "2018-06-01T12:30:00+02:00", t: time.Date(2018, 6, 1, 10, 30, 0, 0, time.UTC)},
+		{s: "", err: true},
+		{s: "2018-13-01", err: true},
+		{s: "06/01/2018", err: true},
+		{s: "-1d", err: true},
+	}
+
+	for _, test := range tests {
+		tm, err := ParseTime(test.s)
+		if test.err {
+			if err == nil {
+				t.Errorf("ParseTime(%q): expected an error, got %s", test.s, tm)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("ParseTime(%q): %s", test.s, err)
+			continue
+		}
+
+		if !tm.Equal(test.t) {
+			t.Errorf("ParseTime(%q) = %s, want %s", test.s, tm, test.t)
+		}
+	}
+
+	// durations are relative to now
+	for s, d := range map[string]time.Duration{"90d": 90 * 24 * time.Hour, "36h": 36 * time.Hour} {
+		before := time.Now()
+		tm, err := ParseTime(s)
+		after := time.Now()
+
+		if err != nil {
+			t.Errorf("ParseTime(%q): %s", s, err)
+			continue
+		}
+
+		if tm.Before(before.Add(d)) || tm.After(after.Add(d)) {
+			t.Errorf("ParseTime(%q) = %s, want %s from now", s, tm, d)
+		}
+	}
+}
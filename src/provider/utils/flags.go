@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"flag"
+	"strings"
+)
+
+// SplitFlags splits args into the given flags of the flag set, together with
+// their values, and the remaining arguments. The order of the arguments is
+// preserved. It's used to pass the flags of one command, i.e. list, through
+// another one, such as delete.
+func SplitFlags(fs *flag.FlagSet, args []string, names ...string) (matched, rest []string) {
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+
+		if len(arg) < 2 || arg[0] != '-' {
+			rest = append(rest, arg)
+			continue
+		}
+
+		name := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		hasValue := false
+		if i := strings.Index(name, "="); i >= 0 {
+			name, hasValue = name[:i], true
+		}
+
+		if !wanted[name] {
+			rest = append(rest, arg)
+			continue
+		}
+
+		matched = append(matched, arg)
+		if hasValue || isBoolFlag(fs, name) || i+1 == len(args) {
+			continue
+		}
+
+		i++
+		matched = append(matched, args[i])
+	}
+
+	return matched, rest
+}
+
+// isBoolFlag returns true if the flag with the given name doesn't need a
+// value.
+func isBoolFlag(fs *flag.FlagSet, name string) bool {
+	f := fs.Lookup(name)
+	if f == nil {
+		return false
+	}
+
+	b, ok := f.Value.(interface {
+		IsBoolFlag() bool
+	})
+	return ok && b.IsBoolFlag()
+}
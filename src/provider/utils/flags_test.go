@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+)
+
+func TestSplitFlags(t *testing.T) {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	fs.String("family", "", "")
+	fs.Bool("all", false, "")
+	fs.Bool("dry-run", false, "")
+
+	tests := []struct {
+		args    []string
+		matched []string
+		rest    []string
+	}{
+		{
+			args: nil,
+		},
+		{
+			args:    []string{"-family", "web", "-dry-run"},
+			matched: []string{"-family", "web"},
+			rest:    []string{"-dry-run"},
+		},
+		{
+			args:    []string{"-dry-run", "--family=web", "-all", "-create-tags", "a=b"},
+			matched: []string{"--family=web", "-all"},
+			rest:    []string{"-dry-run", "-create-tags", "a=b"},
+		},
+		{
+			args:    []string{"-all=false", "-family"},
+			matched: []string{"-all=false", "-family"},
+		},
+		{
+			args: []string{"-ids", "1", "--", "-family", "web"},
+			rest: []string{"-ids", "1", "--", "-family", "web"},
+		},
+	}
+
+	for _, test := range tests {
+		matched, rest := SplitFlags(fs, test.args, "family", "all")
+		if !reflect.DeepEqual(matched, test.matched) || !reflect.DeepEqual(rest, test.rest) {
+			t.Errorf("%q: got %q, %q, want %q, %q", test.args, matched, rest, test.matched, test.rest)
+		}
+	}
+}
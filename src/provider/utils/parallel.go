@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"fmt"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// DefaultParallelism is the maximum number of concurrent requests of an
+// operation on multiple items if neither the global nor the provider
+// "parallelism" setting is set.
+const DefaultParallelism = 10
+
+type parallelismKey struct{}
+
+// WithParallelism returns a copy of ctx which carries the global parallelism
+// setting to the providers.
+func WithParallelism(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, parallelismKey{}, n)
+}
+
+// Parallelism returns the parallelism of a provider. The provider setting is
+// used if it's set, otherwise the global setting carried by ctx or
+// DefaultParallelism.
+func Parallelism(ctx context.Context, n int) int {
+	if n > 0 {
+		return n
+	}
+
+	if n, ok := ctx.Value(parallelismKey{}).(int); ok && n > 0 {
+		return n
+	}
+
+	return DefaultParallelism
+}
+
+// ValidateParallelism checks the "parallelism" setting of a configuration.
+// Zero means the setting isn't set.
+func ValidateParallelism(n int) error {
+	if n < 0 {
+		return fmt.Errorf("invalid parallelism setting '%d': must not be negative", n)
+	}
+	return nil
+}
+
+// ForEach calls fn for each of the n items, with at most limit concurrent
+// calls, and waits until all calls are finished. Once ctx is done no further
+// calls are started, the remaining items are left untouched.
+func ForEach(ctx context.Context, limit, n int, fn func(i int)) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, limit)
+
+	for i := 0; i < n; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			fn(i)
+		}(i)
+	}
+
+	wg.Wait()
+}
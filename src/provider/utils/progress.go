@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/mattn/go-isatty"
+)
+
+// Progress shows the state of an operation on multiple items, such as copying
+// an image to multiple regions, with a line for each item. On a terminal the
+// lines are updated in place, otherwise a line is printed for each change.
+// It's safe for concurrent use.
+type Progress struct {
+	mu     sync.Mutex
+	w      io.Writer
+	live   bool
+	drawn  bool
+	width  int // width of the longest item
+	items  []string
+	states map[string]string
+}
+
+// NewProgress returns a new Progress for the given items, which writes to f.
+func NewProgress(f *os.File, items ...string) *Progress {
+	width := 0
+	for _, item := range items {
+		if len(item) > width {
+			width = len(item)
+		}
+	}
+
+	return &Progress{
+		w:      f,
+		live:   isatty.IsTerminal(f.Fd()),
+		width:  width,
+		items:  items,
+		states: make(map[string]string, len(items)),
+	}
+}
+
+// Set sets the state of the given item and shows the change.
+func (p *Progress) Set(item, format string, args ...interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	state := fmt.Sprintf(format, args...)
+	if p.states[item] == state {
+		return
+	}
+	p.states[item] = state
+
+	if !p.live {
+		p.line(item)
+		return
+	}
+
+	// move the cursor back to the first line and redraw all items
+	if p.drawn {
+		fmt.Fprintf(p.w, "\x1b[%dA", len(p.items))
+	}
+
+	for _, item := range p.items {
+		fmt.Fprint(p.w, "\r\x1b[2K")
+		p.line(item)
+	}
+	p.drawn = true
+}
+
+func (p *Progress) line(item string) {
+	fmt.Fprintf(p.w, "%-*s  %s\n", p.width, item, p.states[item])
+}
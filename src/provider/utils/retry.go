@@ -0,0 +1,222 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// retryBaseDelay is the maximum delay before the first retry. It's
+	// doubled for each further retry.
+	retryBaseDelay = 500 * time.Millisecond
+
+	// retryMaxDelay is the upper limit of the delay between two retries,
+	// unless the provider asks for a longer one.
+	retryMaxDelay = 30 * time.Second
+
+	// maxRetryAfter is the longest delay a provider can ask for. Requests
+	// which are rate limited for a longer time aren't retried.
+	maxRetryAfter = 5 * time.Minute
+)
+
+// Retry retries failed requests with exponential backoff and full jitter. It
+// honors the delays providers ask for, such as the Retry-After header, and
+// counts the retries, so they can be reported to the user. It's shared by all
+// requests of a provider and safe for concurrent use.
+type Retry struct {
+	// MaxRetries is the maximum number of retries of a single request. Zero
+	// disables retries.
+	MaxRetries int
+
+	mu        sync.Mutex // protects the fields below
+	rand      *rand.Rand
+	retries   int
+	throttled int
+}
+
+// NewRetry returns a new Retry for the "retries" setting of a provider
+// configuration, which is the maximum number of retries of a single request.
+func NewRetry(maxRetries int) (*Retry, error) {
+	if maxRetries < 0 {
+		return nil, fmt.Errorf("invalid retries setting '%d': must not be negative", maxRetries)
+	}
+
+	return &Retry{
+		MaxRetries: maxRetries,
+		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+// Delay returns the delay before the given retry, starting with 0, and counts
+// it. retryAfter is the delay the provider asked for, which is used if it's
+// longer than the backoff. throttled defines whether the request was rate
+// limited.
+func (r *Retry) Delay(retry int, retryAfter time.Duration, throttled bool) time.Duration {
+	backoff := retryMaxDelay
+	if retry < 16 && retryBaseDelay<<uint(retry) < retryMaxDelay {
+		backoff = retryBaseDelay << uint(retry)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.retries++
+	if throttled {
+		r.throttled++
+	}
+
+	// full jitter spreads the retries of concurrent requests, which are
+	// usually rate limited at the same time
+	d := time.Duration(r.rand.Int63n(int64(backoff)))
+	if retryAfter > d {
+		d = retryAfter
+	}
+
+	return d
+}
+
+// Retries returns the number of retried requests and how many of them were
+// rate limited.
+func (r *Retry) Retries() (total, throttled int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.retries, r.throttled
+}
+
+// RetryAfter returns the delay the provider asks for in the given response.
+// The "Retry-After" header and the "RateLimit-Reset" header of DigitalOcean
+// are supported. It returns zero if the response doesn't contain a delay.
+func RetryAfter(resp *http.Response) time.Duration {
+	if resp == nil {
+		return 0
+	}
+
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil {
+			return time.Duration(secs) * time.Second
+		}
+
+		if t, err := http.ParseTime(v); err == nil {
+			return t.Sub(time.Now())
+		}
+	}
+
+	// the reset time is only relevant once the limit is exhausted
+	if resp.Header.Get("RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get("RateLimit-Reset"), 10, 64); err == nil {
+			return time.Unix(reset, 0).Sub(time.Now())
+		}
+	}
+
+	return 0
+}
+
+// NewRetryClient returns a client which retries failed and rate limited
+// requests with the given policy. The timeout applies to each attempt.
+func NewRetryClient(timeout time.Duration, retry *Retry) *http.Client {
+	return &http.Client{
+		Transport: &retryTransport{
+			client: NewHTTPClient(timeout),
+			retry:  retry,
+		},
+	}
+}
+
+// retryTransport sends requests via client and retries them. Using a client
+// instead of a transport applies the timeout of the client to each attempt.
+type retryTransport struct {
+	client *http.Client
+	retry  *Retry
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for retry := 0; ; retry++ {
+		resp, err := t.client.Do(req)
+		if req.Context().Err() != nil || retry >= t.retry.MaxRetries {
+			return resp, err
+		}
+
+		throttled, ok := retryable(req, resp, err)
+		if !ok {
+			return resp, err
+		}
+
+		retryAfter := RetryAfter(resp)
+		if retryAfter > maxRetryAfter {
+			return resp, err
+		}
+
+		// the body was consumed by the failed attempt
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return resp, err
+			}
+
+			body, gerr := req.GetBody()
+			if gerr != nil {
+				return resp, err
+			}
+
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if err := Sleep(req.Context(), t.retry.Delay(retry, retryAfter, throttled)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryable returns whether the given request can be retried and whether it
+// was rate limited. Requests which might have been processed already are
+// only retried if they are idempotent.
+func retryable(req *http.Request, resp *http.Response, err error) (throttled, ok bool) {
+	idempotent := req.Method != "POST" && req.Method != "PATCH"
+
+	if err != nil {
+		return false, idempotent
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true, true
+	case http.StatusServiceUnavailable:
+		return false, true
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return false, idempotent
+	case http.StatusForbidden:
+		// GCE reports exceeded rate limits as forbidden
+		if isRateLimitError(resp) {
+			return true, true
+		}
+	}
+
+	return false, false
+}
+
+// isRateLimitError checks whether the body of the given response contains a
+// rate limit error of the Google APIs. The body can be read again afterwards.
+func isRateLimitError(resp *http.Response) bool {
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+
+	s := string(body)
+	return strings.Contains(s, "rateLimitExceeded") || strings.Contains(s, "userRateLimitExceeded")
+}
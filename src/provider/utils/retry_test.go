@@ -0,0 +1,259 @@
+package utils
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	r, err := NewRetry(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for retry := 0; retry < 20; retry++ {
+		backoff := retryMaxDelay
+		if retry < 6 {
+			backoff = retryBaseDelay << uint(retry)
+		}
+
+		if d := r.Delay(retry, 0, false); d < 0 || d >= backoff {
+			t.Errorf("delay of retry %d is %s, want less than %s", retry, d, backoff)
+		}
+	}
+
+	// the provider can ask for longer delays than the backoff
+	if d := r.Delay(0, time.Minute, true); d != time.Minute {
+		t.Errorf("delay is %s, want the requested %s", d, time.Minute)
+	}
+
+	if total, throttled := r.Retries(); total != 21 || throttled != 1 {
+		t.Errorf("retries are %d, %d throttled, want 21, 1 throttled", total, throttled)
+	}
+
+	if _, err := NewRetry(-1); err == nil {
+		t.Error("expected an error for negative retries")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	header := func(kv ...string) *http.Response {
+		resp := &http.Response{Header: make(http.Header)}
+		for i := 0; i+1 < len(kv); i += 2 {
+			resp.Header.Set(kv[i], kv[i+1])
+		}
+		return resp
+	}
+
+	reset := strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name string
+		resp *http.Response
+		min  time.Duration
+		max  time.Duration
+	}{
+		{name: "no response"},
+		{name: "no header", resp: header()},
+		{name: "seconds", resp: header("Retry-After", "120"), min: 2 * time.Minute, max: 2 * time.Minute},
+		{
+			name: "http date",
+			resp: header("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)),
+			min:  59 * time.Minute,
+			max:  time.Hour,
+		},
+		{name: "invalid", resp: header("Retry-After", "soon")},
+		{name: "exhausted", resp: header("RateLimit-Remaining", "0", "RateLimit-Reset", reset), min: 58 * time.Second, max: time.Minute},
+		{name: "not exhausted", resp: header("RateLimit-Remaining", "10", "RateLimit-Reset", reset)},
+	}
+
+	for _, test := range tests {
+		if d := RetryAfter(test.resp); d < test.min || d > test.max {
+			t.Errorf("%s: delay is %s, want between %s and %s", test.name, d, test.min, test.max)
+		}
+	}
+}
+
+// response is a scripted response of the test server
+type response struct {
+	status int
+	header map[string]string
+	body   string
+}
+
+func TestRetryTransport(t *testing.T) {
+	rateLimited := `{"error": {"errors": [{"reason": "rateLimitExceeded"}], "code": 403}}`
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		responses  []response
+		status     int
+		attempts   int
+		throttled  int
+		maxRetries int
+	}{
+		{
+			name:      "unavailable",
+			method:    "GET",
+			responses: []response{{status: 503}, {status: 200}},
+			status:    200,
+			attempts:  2,
+		},
+		{
+			name:      "too many requests",
+			method:    "GET",
+			responses: []response{{status: 429, header: map[string]string{"Retry-After": "0"}}, {status: 429}, {status: 200}},
+			status:    200,
+			attempts:  3,
+			throttled: 2,
+		},
+		{
+			name:      "too long retry after",
+			method:    "GET",
+			responses: []response{{status: 429, header: map[string]string{"Retry-After": "3600"}}, {status: 200}},
+			status:    429,
+			attempts:  1,
+		},
+		{
+			name:      "server error",
+			method:    "DELETE",
+			responses: []response{{status: 500}, {status: 502}, {status: 504}, {status: 200}},
+			status:    200,
+			attempts:  4,
+		},
+		{
+			name:       "max retries",
+			method:     "GET",
+			responses:  []response{{status: 500}, {status: 500}, {status: 500}, {status: 200}},
+			status:     500,
+			attempts:   2,
+			maxRetries: 1,
+		},
+		{
+			name:      "post server error",
+			method:    "POST",
+			body:      "payload",
+			responses: []response{{status: 500}, {status: 200}},
+			status:    500,
+			attempts:  1,
+		},
+		{
+			name:      "patch server error",
+			method:    "PATCH",
+			body:      "payload",
+			responses: []response{{status: 502}, {status: 200}},
+			status:    502,
+			attempts:  1,
+		},
+		{
+			name:      "post unavailable",
+			method:    "POST",
+			body:      "payload",
+			responses: []response{{status: 503}, {status: 429}, {status: 201}},
+			status:    201,
+			attempts:  3,
+			throttled: 1,
+		},
+		{
+			name:      "gce rate limit",
+			method:    "POST",
+			body:      "payload",
+			responses: []response{{status: 403, body: rateLimited}, {status: 200}},
+			status:    200,
+			attempts:  2,
+			throttled: 1,
+		},
+		{
+			name:      "forbidden",
+			method:    "GET",
+			responses: []response{{status: 403, body: "access denied"}, {status: 200}},
+			status:    403,
+			attempts:  1,
+		},
+		{
+			name:      "client error",
+			method:    "GET",
+			responses: []response{{status: 404}, {status: 200}},
+			status:    404,
+			attempts:  1,
+		},
+	}
+
+	for _, test := range tests {
+		var (
+			mu     sync.Mutex
+			bodies []string
+		)
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+
+			mu.Lock()
+			bodies = append(bodies, string(body))
+			resp := test.responses[len(bodies)-1]
+			mu.Unlock()
+
+			for key, val := range resp.header {
+				w.Header().Set(key, val)
+			}
+			w.WriteHeader(resp.status)
+			w.Write([]byte(resp.body))
+		}))
+
+		maxRetries := test.maxRetries
+		if maxRetries == 0 {
+			maxRetries = 5
+		}
+		retry, _ := NewRetry(maxRetries)
+		client := NewRetryClient(time.Second, retry)
+
+		var reqBody io.Reader
+		if test.body != "" {
+			reqBody = strings.NewReader(test.body)
+		}
+		req, _ := http.NewRequest(test.method, server.URL, reqBody)
+
+		resp, err := client.Do(req)
+		server.Close()
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+
+		// the body of the last response is passed through
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if want := test.responses[test.attempts-1].body; string(body) != want {
+			t.Errorf("%s: body is %q, want %q", test.name, body, want)
+		}
+
+		if resp.StatusCode != test.status {
+			t.Errorf("%s: status is %d, want %d", test.name, resp.StatusCode, test.status)
+		}
+
+		if len(bodies) != test.attempts {
+			t.Errorf("%s: sent %d requests, want %d", test.name, len(bodies), test.attempts)
+		}
+
+		// the request body is sent again with every attempt
+		for i, body := range bodies {
+			if body != test.body {
+				t.Errorf("%s: body of attempt %d is %q, want %q", test.name, i+1, body, test.body)
+			}
+		}
+
+		if total, throttled := retry.Retries(); total != test.attempts-1 || throttled != test.throttled {
+			t.Errorf("%s: retries are %d, %d throttled, want %d, %d throttled",
+				test.name, total, throttled, test.attempts-1, test.throttled)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"fmt"
 	"io"
 	"strings"
 	"text/tabwriter"
@@ -11,6 +12,10 @@ type OutputMode int
 const (
 	Simplified OutputMode = iota + 1
 	JSON
+	Table
+	CSV
+	YAML
+	NDJSON
 )
 
 func (o OutputMode) String() string {
@@ -19,6 +24,14 @@ func (o OutputMode) String() string {
 		return "simplified"
 	case JSON:
 		return "json"
+	case Table:
+		return "table"
+	case CSV:
+		return "csv"
+	case YAML:
+		return "yaml"
+	case NDJSON:
+		return "ndjson"
 	default:
 		return "unknown"
 	}
@@ -28,8 +41,15 @@ func (o OutputMode) String() string {
 var Outputs = map[string]OutputMode{
 	"simplified": Simplified,
 	"json":       JSON,
+	"table":      Table,
+	"csv":        CSV,
+	"yaml":       YAML,
+	"ndjson":     NDJSON,
 }
 
+// OutputsHelp is the list of the output modes, used in help messages
+const OutputsHelp = `"simplified", "table", "json", "ndjson", "csv" or "yaml"`
+
 type OutputValue OutputMode
 
 // NewOutputValue satisfies the flag.Value interface{}. Use it to plug into the
@@ -45,7 +65,12 @@ func (o *OutputValue) Set(val string) error {
 		return nil
 	}
 
-	*o = OutputValue(Outputs[strings.ToLower(val)])
+	mode, ok := Outputs[strings.ToLower(val)]
+	if !ok {
+		return fmt.Errorf("output mode '%s' is not valid. Available options: %s", val, OutputsHelp)
+	}
+
+	*o = OutputValue(mode)
 	return nil
 }
 
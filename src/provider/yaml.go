@@ -0,0 +1,117 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// printYAML prints the images as a YAML sequence. The images are converted via
+// their JSON representation, so both formats contain the same fields.
+func (i Images) printYAML(out io.Writer) error {
+	data, err := json.Marshal(i)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber() // don't convert large ids into floats
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if s, ok := yamlScalar(v); ok {
+		buf.WriteString(s + "\n")
+	} else {
+		writeYAML(&buf, v, "")
+	}
+
+	_, err = buf.WriteTo(out)
+	return err
+}
+
+// writeYAML writes the given map or slice, which is decoded from JSON, in
+// block style with the given indentation.
+func writeYAML(buf *bytes.Buffer, v interface{}, indent string) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for key := range val {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			buf.WriteString(indent + yamlKey(key) + ":")
+			if s, ok := yamlScalar(val[key]); ok {
+				buf.WriteString(" " + s + "\n")
+				continue
+			}
+
+			buf.WriteString("\n")
+			writeYAML(buf, val[key], indent+"  ")
+		}
+	case []interface{}:
+		for _, item := range val {
+			if s, ok := yamlScalar(item); ok {
+				buf.WriteString(indent + "- " + s + "\n")
+				continue
+			}
+
+			// render the item one level deeper and put the dash in place
+			// of the indentation of its first line
+			var child bytes.Buffer
+			writeYAML(&child, item, indent+"  ")
+			buf.WriteString(indent + "- " + strings.TrimPrefix(child.String(), indent+"  "))
+		}
+	}
+}
+
+// yamlScalar returns the YAML representation of v if it's a scalar or an
+// empty collection.
+func yamlScalar(v interface{}) (string, bool) {
+	switch val := v.(type) {
+	case nil:
+		return "null", true
+	case bool:
+		return strconv.FormatBool(val), true
+	case json.Number:
+		return val.String(), true
+	case string:
+		// Go's escape sequences are a subset of YAML's double quoted style
+		return strconv.Quote(val), true
+	case map[string]interface{}:
+		if len(val) == 0 {
+			return "{}", true
+		}
+	case []interface{}:
+		if len(val) == 0 {
+			return "[]", true
+		}
+	}
+
+	return "", false
+}
+
+var plainKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.\-/]*$`)
+
+// yamlKey quotes the key unless it's a plain word which can't be mistaken for
+// a boolean or null.
+func yamlKey(key string) string {
+	switch strings.ToLower(key) {
+	case "true", "false", "yes", "no", "on", "off", "y", "n", "null":
+		return strconv.Quote(key)
+	}
+
+	if plainKey.MatchString(key) {
+		return key
+	}
+	return strconv.Quote(key)
+}
@@ -0,0 +1,93 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestWriteYAML(t *testing.T) {
+	tests := []struct {
+		json string
+		yaml string
+	}{
+		{
+			json: `{"b": 1, "a": {"y": [1, "two", {"k": null}], "x": {}}, "c": []}`,
+			yaml: `a:
+  x: {}
+  "y":
+    - 1
+    - "two"
+    - k: null
+b: 1
+c: []
+`,
+		},
+		{
+			json: `[[1, 2], {"a": {"b": true}}]`,
+			yaml: `- - 1
+  - 2
+- a:
+    b: true
+`,
+		},
+		{
+			json: `{"yes": "no", "on": "", "1st": "line\nbreak\ttab", "key with space": "#comment: not", "a/b.c-d": "\u0001é"}`,
+			yaml: `"1st": "line\nbreak\ttab"
+a/b.c-d: "\x01é"
+"key with space": "#comment: not"
+"on": ""
+"yes": "no"
+`,
+		},
+		{
+			json: `{"id": 12345678901234567890, "size": 1.5, "neg": -3}`,
+			yaml: `id: 12345678901234567890
+neg: -3
+size: 1.5
+`,
+		},
+	}
+
+	for _, test := range tests {
+		dec := json.NewDecoder(bytes.NewReader([]byte(test.json)))
+		dec.UseNumber()
+
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		writeYAML(&buf, v, "")
+		if buf.String() != test.yaml {
+			t.Errorf("%s: yaml is\n%s\nwant\n%s", test.json, buf.String(), test.yaml)
+		}
+	}
+}
+
+func TestYAMLScalar(t *testing.T) {
+	tests := []struct {
+		v      interface{}
+		s      string
+		scalar bool
+	}{
+		{v: nil, s: "null", scalar: true},
+		{v: false, s: "false", scalar: true},
+		{v: json.Number("42"), s: "42", scalar: true},
+		{v: "", s: `""`, scalar: true},
+		{v: "null", s: `"null"`, scalar: true},
+		{v: `a "quoted" \ path`, s: `"a \"quoted\" \\ path"`, scalar: true},
+		{v: map[string]interface{}{}, s: "{}", scalar: true},
+		{v: []interface{}{}, s: "[]", scalar: true},
+		{v: map[string]interface{}{"a": 1}},
+		{v: []interface{}{1}},
+	}
+
+	for _, test := range tests {
+		s, scalar := yamlScalar(test.v)
+		if s != test.s || scalar != test.scalar {
+			t.Errorf("%#v: got %q, %t, want %q, %t", test.v, s, scalar, test.s, test.scalar)
+		}
+	}
+}